@@ -0,0 +1,89 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Analyze runs ANALYZE for tables, or for all tables if none are given, which gathers the statistics the query planner uses to choose indices.
+// The write lock is held while analyzing.
+func (db *DB) Analyze(ctx context.Context, tables ...string) error {
+	return db.mapError(db.analyze(ctx, tables))
+}
+
+func (db *DB) analyze(ctx context.Context, tables []string) error {
+	if !isSQLiteDriver(db.DriverName()) {
+		return errors.Errorf("analyzing isn't supported for driver %q", db.DriverName())
+	}
+	stmts := []string{"ANALYZE"}
+	if len(tables) > 0 {
+		stmts = stmts[:0]
+		for _, table := range tables {
+			if err := validTableName(table); err != nil {
+				return err
+			}
+			stmts = append(stmts, fmt.Sprintf("ANALYZE %s", quoteTable(table)))
+		}
+	}
+	if err := db.lockContext(ctx); err != nil {
+		return err
+	}
+	defer db.locker.Unlock()
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(labeled(ctx, db, "analyze", ""), stmt); err != nil {
+			return withStack(err)
+		}
+	}
+	return nil
+}
+
+// QueryPlanStep is a step of the plan SQLite chose for a query, like "SEARCH Users USING INDEX Users.Email (Email=?)".
+type QueryPlanStep struct {
+	ID     int    `db:"id"`
+	Parent int    `db:"parent"`
+	Detail string `db:"detail"`
+}
+
+// ExplainQueryPlan returns the steps of the plan SQLite chooses for query, using EXPLAIN QUERY PLAN, to verify which indices it uses.
+// The args are expanded like for GetSQL, and if querier is a *DB the query is explained in a Read transaction.
+func ExplainQueryPlan(ctx context.Context, querier sqlx.QueryerContext, query string, args ...any) ([]QueryPlanStep, error) {
+	if !isSQLiteDriver(driverNameOf(querier)) {
+		return nil, errors.Errorf("explaining query plans isn't supported for driver %q", driverNameOf(querier))
+	}
+	result := []QueryPlanStep{}
+	if err := readIn(ctx, querier, func(q sqlx.QueryerContext) error {
+		query, args, err := expand(q, query, args)
+		if err != nil {
+			return err
+		}
+		rows, err := q.QueryxContext(labeled(ctx, q, "explainQueryPlan", ""), "EXPLAIN QUERY PLAN "+query, args...)
+		if err != nil {
+			return withStack(err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			step := QueryPlanStep{}
+			unused := 0
+			if err := rows.Scan(&step.ID, &step.Parent, &unused, &step.Detail); err != nil {
+				return withStack(err)
+			}
+			result = append(result, step)
+		}
+		return withStack(rows.Err())
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ExplainQueryPlan works like the package level ExplainQueryPlan, explaining query in a Read of db.
+func (db *DB) ExplainQueryPlan(ctx context.Context, query string, args ...any) ([]QueryPlanStep, error) {
+	return ExplainQueryPlan(ctx, db, query, args...)
+}
+
+func (tx *Tx) ExplainQueryPlan(ctx context.Context, query string, args ...any) ([]QueryPlanStep, error) {
+	return ExplainQueryPlan(ctx, tx, query, args...)
+}
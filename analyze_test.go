@@ -0,0 +1,57 @@
+package sqly
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, namedTestStruct{}))
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			for i := 0; i < 100; i++ {
+				if err := tx.Upsert(ctx, &namedTestStruct{Id: i + 1, Email: strings.Repeat("a", i+1), First: "first", Last: "last"}, false); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+		noerr(t, db.Analyze(ctx, "namedTestStruct"))
+		stats := 0
+		noerr(t, db.Get(&stats, "SELECT COUNT(*) FROM sqlite_stat1 WHERE tbl = 'namedTestStruct'"))
+		if stats == 0 {
+			t.Errorf("got no statistics for namedTestStruct")
+		}
+		noerr(t, db.Get(&stats, "SELECT COUNT(*) FROM sqlite_stat1 WHERE tbl = 'sharedTestStruct'"))
+		if stats != 0 {
+			t.Errorf("got statistics for sharedTestStruct before analyzing all tables")
+		}
+		noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 1}, false))
+		noerr(t, db.Analyze(ctx))
+		noerr(t, db.Get(&stats, "SELECT COUNT(*) FROM sqlite_stat1 WHERE tbl = 'sharedTestStruct'"))
+		if stats == 0 {
+			t.Errorf("got no statistics for sharedTestStruct after analyzing all tables")
+		}
+		yeserr(t, db.Analyze(ctx, "bad`name"))
+
+		steps, err := db.ExplainQueryPlan(ctx, "SELECT * FROM namedTestStruct WHERE Email IN (?)", []string{"a", "aa"})
+		noerr(t, err)
+		if len(steps) == 0 || !strings.Contains(steps[len(steps)-1].Detail, "USING INDEX namedTestStruct.Email") {
+			t.Errorf("got %+v, wanted a search using the Email index", steps)
+		}
+		noerr(t, db.Read(ctx, func(tx *Tx) error {
+			_, err := ExplainQueryPlan(ctx, tx, "SELECT * FROM namedTestStruct")
+			return err
+		}))
+		_, err = db.ExplainQueryPlan(ctx, "SELECT * FROM missing")
+		yeserr(t, err)
+	})
+	other, err := Open("fakepostgres", filepath.Join(t.TempDir(), "other.db"))
+	noerr(t, err)
+	defer other.Close()
+	yeserr(t, other.Analyze(ctx))
+	_, err = other.ExplainQueryPlan(ctx, "SELECT 1")
+	yeserr(t, err)
+}
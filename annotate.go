@@ -0,0 +1,122 @@
+package sqly
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// subjectKeys are the comment keys of the subjects of labels that aren't tables.
+	subjectKeys = map[string]string{
+		"ensureIndex": "index",
+		"dropIndex":   "index",
+		"savepoint":   "savepoint",
+		"pragma":      "pragma",
+	}
+	// callerSkipPrefixes are the functions skipped when looking for the caller of a statement.
+	callerSkipPrefixes = []string{
+		"github.com/zond/sqly.",
+		"github.com/jmoiron/sqlx.",
+		"database/sql.",
+		"runtime.",
+	}
+)
+
+type annotationsKey struct{}
+
+type annotation struct {
+	key   string
+	value string
+}
+
+// WithQueryAnnotation returns a copy of ctx that adds key=value to the comments WithSQLComments appends to the statements run with it.
+func WithQueryAnnotation(ctx context.Context, key string, value string) context.Context {
+	existing, _ := ctx.Value(annotationsKey{}).([]annotation)
+	annotations := make([]annotation, len(existing), len(existing)+1)
+	copy(annotations, existing)
+	return context.WithValue(ctx, annotationsKey{}, append(annotations, annotation{key: key, value: value}))
+}
+
+// WithSQLComments makes the statements generated by sqly end with a comment like `/* sqly op=upsert table=User caller=store.SaveUser */`,
+// naming the helper, the table, the first function outside sqly that issued it, and the annotations added by WithQueryAnnotation.
+// The comments are added before any middlewares from WithMiddleware see the statements.
+func WithSQLComments() Option {
+	return func(db *DB) error {
+		db.sqlComments = true
+		return nil
+	}
+}
+
+// sanitizeComment removes all */ from s, so that it can't end the comment it's put in.
+func sanitizeComment(s string) string {
+	for strings.Contains(s, "*/") {
+		s = strings.ReplaceAll(s, "*/", "")
+	}
+	return s
+}
+
+// statementCaller returns the first function outside sqly, sqlx and database/sql on the stack, like "store.SaveUser".
+func statementCaller() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		skip := false
+		for _, prefix := range callerSkipPrefixes {
+			if strings.HasPrefix(frame.Function, prefix) && !strings.HasSuffix(frame.File, "_test.go") {
+				skip = true
+				break
+			}
+		}
+		if !skip && frame.Function != "" {
+			return frame.Function[strings.LastIndex(frame.Function, "/")+1:]
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// sqlComment returns the comment to append to statements labeled label run with ctx, or "" for unlabeled statements.
+func sqlComment(ctx context.Context, label string) string {
+	if label == "" {
+		return ""
+	}
+	comment := &strings.Builder{}
+	comment.WriteString(" /* sqly")
+	op, subject, _ := strings.Cut(label, ":")
+	fmt.Fprintf(comment, " op=%s", sanitizeComment(op))
+	if subject != "" {
+		key, found := subjectKeys[op]
+		if !found {
+			key = "table"
+		}
+		fmt.Fprintf(comment, " %s=%s", key, sanitizeComment(subject))
+	}
+	if caller := statementCaller(); caller != "" {
+		fmt.Fprintf(comment, " caller=%s", sanitizeComment(caller))
+	}
+	annotations, _ := ctx.Value(annotationsKey{}).([]annotation)
+	for _, annotation := range annotations {
+		fmt.Fprintf(comment, " %s=%s", sanitizeComment(annotation.key), sanitizeComment(annotation.value))
+	}
+	comment.WriteString(" */")
+	return comment.String()
+}
+
+// commentMiddleware appends sqlComment to the labeled statements.
+func commentMiddleware(next StatementHandler) StatementHandler {
+	return StatementHandlerFuncs{
+		Exec: func(ctx context.Context, label string, query string, args ...any) (sql.Result, error) {
+			return next.ExecContext(ctx, label, query+sqlComment(ctx, label), args...)
+		},
+		Query: func(ctx context.Context, label string, query string, args ...any) (*sqlx.Rows, error) {
+			return next.QueryxContext(ctx, label, query+sqlComment(ctx, label), args...)
+		},
+	}
+}
@@ -0,0 +1,76 @@
+package sqly
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type annotatedTestStruct struct {
+	Id   int `sqly:"pkey"`
+	Name string
+}
+
+func saveAnnotatedTestStruct(ctx context.Context, db *DB, val *annotatedTestStruct) error {
+	return db.Upsert(ctx, val, false)
+}
+
+func TestSQLComments(t *testing.T) {
+	logged := []string{}
+	logging := func(next StatementHandler) StatementHandler {
+		return StatementHandlerFuncs{
+			Exec: func(ctx context.Context, label string, query string, args ...any) (sql.Result, error) {
+				logged = append(logged, query)
+				return next.ExecContext(ctx, label, query, args...)
+			},
+			Query: func(ctx context.Context, label string, query string, args ...any) (*sqlx.Rows, error) {
+				logged = append(logged, query)
+				return next.QueryxContext(ctx, label, query, args...)
+			},
+		}
+	}
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, annotatedTestStruct{}))
+		logged = nil
+		annotated := WithQueryAnnotation(WithQueryAnnotation(ctx, "request", "abc*/def"), "user", "*/*/x")
+		noerr(t, saveAnnotatedTestStruct(annotated, db, &annotatedTestStruct{Id: 1}))
+		want := "/* sqly op=upsert table=annotatedTestStruct caller=sqly.saveAnnotatedTestStruct request=abcdef user=x */"
+		if len(logged) != 1 || !strings.HasSuffix(logged[0], want) {
+			t.Errorf("got %q, wanted a statement ending with %q", logged, want)
+		}
+
+		logged = nil
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "DELETE FROM annotatedTestStruct")
+			return err
+		}))
+		if len(logged) != 1 || strings.Contains(logged[0], "/*") {
+			t.Errorf("got %q, wanted statements run directly left as they are", logged)
+		}
+
+		logged = nil
+		noerr(t, db.EnsureIndex(ctx, IndexSpec{Table: "annotatedTestStruct", Columns: []string{"Name"}}))
+		if len(logged) != 1 || !strings.Contains(logged[0], "op=ensureIndex index=annotatedTestStruct") || !strings.Contains(logged[0], "caller=sqly.TestSQLComments") {
+			t.Errorf("got %q, wanted an ensureIndex comment", logged)
+		}
+	}, WithSQLComments(), WithMiddleware(logging))
+}
+
+func TestSanitizeComment(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+	}{
+		{in: "plain", want: "plain"},
+		{in: "a*/b", want: "ab"},
+		{in: "**//", want: ""},
+		{in: "*", want: "*"},
+	} {
+		if got := sanitizeComment(tc.in); got != tc.want {
+			t.Errorf("got %q for %q, wanted %q", got, tc.in, tc.want)
+		}
+	}
+}
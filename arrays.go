@@ -0,0 +1,71 @@
+package sqly
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+func isByteArray(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Array && typ.Elem().Kind() == reflect.Uint8
+}
+
+// byteArrayParam converts a (pointer to a) byte array to a []byte that drivers can bind, and nil pointers to NULL.
+func byteArrayParam(fieldVal reflect.Value) any {
+	for fieldVal.Kind() == reflect.Ptr {
+		if fieldVal.IsNil() {
+			return nil
+		}
+		fieldVal = fieldVal.Elem()
+	}
+	result := make([]byte, fieldVal.Len())
+	reflect.Copy(reflect.ValueOf(result), fieldVal)
+	return result
+}
+
+// byteArrayScanner scans BLOBs into a (pointer to a) byte array, failing if the length doesn't match.
+type byteArrayScanner struct {
+	field reflect.Value
+}
+
+func (b byteArrayScanner) Scan(src any) error {
+	if src == nil {
+		b.field.SetZero()
+		return nil
+	}
+	var raw []byte
+	switch typed := src.(type) {
+	case []byte:
+		raw = typed
+	case string:
+		raw = []byte(typed)
+	default:
+		return errors.Errorf("can't scan %T into %v", src, b.field.Type())
+	}
+	dst := b.field
+	for dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+	if len(raw) != dst.Len() {
+		return errors.Errorf("can't scan %d bytes into %v", len(raw), dst.Type())
+	}
+	reflect.Copy(dst, reflect.ValueOf(raw))
+	return nil
+}
+
+// checkPrimaryKey returns an error if the pkey of val is a byte array with only zero bytes, since it can't be generated like an integer pkey.
+func (meta *tableMeta) checkPrimaryKey(val reflect.Value) error {
+	if meta.pkey == nil || !isByteArray(meta.pkey.typ) {
+		return nil
+	}
+	if val.Field(meta.pkey.index).IsZero() {
+		return errors.Errorf("pkey %q of %v is all zero bytes", meta.pkey.name, meta.typ)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package sqly
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type hashedTestStruct struct {
+	Id       [16]byte `sqly:"pkey"`
+	Hash     [32]byte
+	Optional *[4]byte
+}
+
+type shortHashTestStruct struct {
+	Id   [16]byte `sqly:"pkey"`
+	Hash [8]byte
+}
+
+func TestByteArrays(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, hashedTestStruct{}))
+		info := getTableInfo(t, db, "hashedTestStruct")
+		if info[0].Type != "BLOB" || info[0].Pk != 1 || info[1].Type != "BLOB" || info[2].Type != "BLOB" {
+			t.Errorf("got %+v, wanted BLOB columns with a BLOB pkey", info)
+		}
+		first := &hashedTestStruct{Id: [16]byte{1}, Hash: [32]byte{1, 2, 3}, Optional: &[4]byte{4, 5, 6, 7}}
+		noerr(t, db.Upsert(ctx, first, false))
+		second := &hashedTestStruct{Id: [16]byte{2}, Hash: [32]byte{31: 9}}
+		noerr(t, db.UpsertAll(ctx, []*hashedTestStruct{second}, false))
+		yeserr(t, db.Upsert(ctx, first, false))
+		yeserr(t, db.Upsert(ctx, &hashedTestStruct{}, false))
+		yeserr(t, db.UpsertAll(ctx, []*hashedTestStruct{{}}, false))
+
+		found, err := GetSQL[hashedTestStruct](ctx, db, "SELECT * FROM hashedTestStruct WHERE Id = ?", first.Id[:])
+		noerr(t, err)
+		if !reflect.DeepEqual(found, *first) {
+			t.Errorf("got %+v, wanted %+v", found, *first)
+		}
+		examples, err := SelectByExample(ctx, db, hashedTestStruct{Hash: second.Hash})
+		noerr(t, err)
+		if len(examples) != 1 || !reflect.DeepEqual(examples[0], *second) {
+			t.Errorf("got %+v, wanted [%+v]", examples, *second)
+		}
+		_, err = GetSQL[hashedTestStruct](ctx, db, "SELECT * FROM hashedTestStruct WHERE Id = ?", []byte{3})
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("got %v, wanted ErrNotFound", err)
+		}
+
+		noerr(t, db.CreateTableIfNotExists(ctx, shortHashTestStruct{}))
+		_, err = db.Exec("INSERT INTO shortHashTestStruct (Id, Hash) VALUES (?, ?)", make([]byte, 16), []byte{1, 2, 3})
+		noerr(t, err)
+		_, err = GetSQL[shortHashTestStruct](ctx, db, "SELECT * FROM shortHashTestStruct")
+		yeserr(t, err)
+	})
+}
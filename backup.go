@@ -0,0 +1,28 @@
+package sqly
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Backup writes a consistent copy of the database to destPath using VACUUM INTO, which works while the database is in use, also in WAL mode.
+// destPath must not already exist.
+// The read lock is held while the copy is made, so Writes wait for it while Reads don't.
+func (db *DB) Backup(ctx context.Context, destPath string) error {
+	return db.mapError(db.backup(ctx, destPath))
+}
+
+func (db *DB) backup(ctx context.Context, destPath string) error {
+	if !isSQLiteDriver(db.DriverName()) {
+		return errors.Errorf("backing up isn't supported for driver %q", db.DriverName())
+	}
+	if err := db.rlockContext(ctx); err != nil {
+		return err
+	}
+	defer db.locker.RUnlock()
+	if _, err := db.ExecContext(labeled(ctx, db, "backup", ""), "VACUUM INTO ?", destPath); err != nil {
+		return withStack(errors.Wrapf(err, "backing up to %q", destPath))
+	}
+	return nil
+}
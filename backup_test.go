@@ -0,0 +1,43 @@
+package sqly
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestBackup(t *testing.T) {
+	withFileDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		for i := 0; i < 100; i++ {
+			noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: i + 1}, false))
+		}
+		stop := make(chan struct{})
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1000; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				noerr(t, db.Write(ctx, func(tx *Tx) error {
+					return tx.Upsert(ctx, &sharedTestStruct{Id: i}, false)
+				}))
+			}
+		}()
+		dest := filepath.Join(t.TempDir(), "backup.db")
+		noerr(t, db.Backup(ctx, dest))
+		close(stop)
+		wg.Wait()
+		backup, err := Open("sqlite", dest)
+		noerr(t, err)
+		defer backup.Close()
+		if count := countRows(t, backup, "sharedTestStruct"); count < 100 {
+			t.Errorf("got %v rows in the backup, wanted at least 100", count)
+		}
+		yeserr(t, db.Backup(ctx, dest))
+	})
+}
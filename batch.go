@@ -0,0 +1,352 @@
+package sqly
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const (
+	maxBatchParams = 999
+)
+
+type batchGroup struct {
+	meta *tableMeta
+	vals []reflect.Value
+	// positions has the index in the upserted slice of each of vals.
+	positions []int
+	// untouches, if not nil, collects the funcs restoring the updated fields of the stored vals, in case the transaction is rolled back.
+	untouches *[]func()
+}
+
+// UpsertAllResult is the outcome of UpsertAllWithResult.
+type UpsertAllResult struct {
+	// Inserted has whether each struct was stored, in the order given, or is nil for drivers other than SQLite.
+	Inserted []bool
+	// Affected is the number of rows stored.
+	Affected int64
+}
+
+func (db *DB) UpsertAll(ctx context.Context, structPointers any, overwrite bool) error {
+	return UpsertAll(ctx, db, structPointers, overwrite)
+}
+
+func (tx *Tx) UpsertAll(ctx context.Context, structPointers any, overwrite bool) error {
+	return tx.db.mapError(UpsertAll(ctx, tx, structPointers, overwrite))
+}
+
+func (db *DB) UpsertAllWithResult(ctx context.Context, structPointers any, conflict ConflictMode) (UpsertAllResult, error) {
+	return UpsertAllWithResult(ctx, db, structPointers, conflict)
+}
+
+func (tx *Tx) UpsertAllWithResult(ctx context.Context, structPointers any, conflict ConflictMode) (UpsertAllResult, error) {
+	result, err := UpsertAllWithResult(ctx, tx, structPointers, conflict)
+	return result, tx.db.mapError(err)
+}
+
+// UpsertAll inserts a slice of struct pointers, which may be a []any or a slice of an interface type holding pointers to different struct types.
+// The elements are grouped by concrete type, and each group is inserted using as few multi row INSERT statements as possible.
+// Elements with an unset autoinc pkey are inserted one by one to be able to back-fill their pkeys,
+// and so are elements with omitted omitempty fields, since their INSERTs have different columns.
+// When execer is a *DB all groups are inserted inside one Write transaction, so either all elements are stored or none of them are.
+// When execer is a *Tx the caller's transaction provides the same guarantee.
+func UpsertAll(ctx context.Context, execer sqlx.ExecerContext, structPointers any, overwrite bool) error {
+	_, err := upsertAll(ctx, execer, structPointers, func(meta *tableMeta) string {
+		return meta.conflictClause(overwrite)
+	}, nil)
+	return err
+}
+
+// UpsertAllWithResult works like UpsertAll, but resolves conflicts according to conflict, and reports which of the elements were stored,
+// so that for example ConflictIgnore can tell new rows from duplicates.
+// For SQLite the multi row INSERTs use RETURNING to find the stored pkeys, which requires SQLite 3.35 or later, and elements of tables without
+// pkeys are inserted one by one. Other drivers only get the total number of rows affected.
+// Autoinc pkeys generated by the database are back-filled into the elements, like for Upsert.
+func UpsertAllWithResult(ctx context.Context, execer sqlx.ExecerContext, structPointers any, conflict ConflictMode) (UpsertAllResult, error) {
+	clause, err := conflict.clause()
+	if err != nil {
+		return UpsertAllResult{}, err
+	}
+	return upsertAll(ctx, execer, structPointers, func(*tableMeta) string {
+		return clause
+	}, nil)
+}
+
+func upsertAll(ctx context.Context, execer sqlx.ExecerContext, structPointers any, conflict func(*tableMeta) string, untouches *[]func()) (UpsertAllResult, error) {
+	if db, ok := execer.(*DB); ok {
+		var result UpsertAllResult
+		stored := []func(){}
+		err := db.Write(ctx, func(tx *Tx) error {
+			var err error
+			result, err = upsertAll(ctx, tx, structPointers, conflict, &stored)
+			return err
+		})
+		if err != nil {
+			// The rolled back Write didn't store any of the rows.
+			for _, untouch := range stored {
+				untouch()
+			}
+		}
+		return result, err
+	}
+	slice := reflect.ValueOf(structPointers)
+	if slice.Kind() != reflect.Slice {
+		return UpsertAllResult{}, errors.Errorf("%v is not a reflect.Slice", structPointers)
+	}
+	metas := metasFor(execer)
+	groups := map[reflect.Type]*batchGroup{}
+	order := []reflect.Type{}
+	for elemIndex := 0; elemIndex < slice.Len(); elemIndex++ {
+		elem := slice.Index(elemIndex)
+		for elem.Kind() == reflect.Interface {
+			elem = elem.Elem()
+		}
+		if elem.Kind() != reflect.Ptr || elem.IsNil() || elem.Elem().Kind() != reflect.Struct {
+			return UpsertAllResult{}, errors.Errorf("element %v (%v) is not a non nil pointer to a reflect.Struct", elemIndex, elem)
+		}
+		val := elem.Elem()
+		group, found := groups[val.Type()]
+		if !found {
+			meta, err := metas.get(val.Type())
+			if err != nil {
+				return UpsertAllResult{}, err
+			}
+			group = &batchGroup{meta: meta, untouches: untouches}
+			groups[val.Type()] = group
+			order = append(order, val.Type())
+		}
+		group.vals = append(group.vals, val)
+		group.positions = append(group.positions, elemIndex)
+	}
+	result := UpsertAllResult{}
+	if isSQLiteDriver(driverNameOf(execer)) {
+		result.Inserted = make([]bool, slice.Len())
+	}
+	for _, typ := range order {
+		group := groups[typ]
+		if err := group.upsert(ctx, execer, conflict(group.meta), &result); err != nil {
+			return UpsertAllResult{}, err
+		}
+	}
+	return result, nil
+}
+
+// UpsertStream reads structs from ch and upserts them using UpsertAll, batchSize at a time, each batch in its own Write.
+// A partial batch is upserted when ch is closed. If ctx is done before that the batch being collected is dropped, and the error of ctx returned.
+// Returns the number of structs upserted by the batches that were committed.
+func UpsertStream[T any](ctx context.Context, db *DB, ch <-chan T, overwrite bool, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		return 0, errors.Errorf("batch size %v isn't positive", batchSize)
+	}
+	upserted := int64(0)
+	batch := make([]*T, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := UpsertAll(ctx, db, batch, overwrite); err != nil {
+			return err
+		}
+		upserted += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return upserted, withStack(ctx.Err())
+		case element, ok := <-ch:
+			if !ok {
+				return upserted, flush()
+			}
+			batch = append(batch, &element)
+			if len(batch) == batchSize {
+				if err := flush(); err != nil {
+					return upserted, err
+				}
+			}
+		}
+	}
+}
+
+func (g *batchGroup) upsert(ctx context.Context, execer sqlx.ExecerContext, conflict string, result *UpsertAllResult) error {
+	// Without pkeys RETURNING can't tell which elements were stored.
+	oneByOne := result.Inserted != nil && len(g.meta.pkeys) == 0
+	batch := []reflect.Value{}
+	positions := []int{}
+	// untouches restores the updated fields of the vals in batch.
+	untouches := []func(){}
+	for valIndex, val := range g.vals {
+		if err := g.meta.checkPrimaryKey(val); err != nil {
+			return err
+		}
+		if oneByOne || g.meta.needsPrimaryKey(val) || g.meta.omittedFields(val) != 0 {
+			untouch := g.meta.touchUpdated(val)
+			inserted, err := insertRow(labeled(ctx, execer, "upsert", g.meta.typ.Name()), execer, g.meta, val, conflict, g.meta.needsPrimaryKey(val))
+			if err != nil {
+				untouch()
+				return err
+			}
+			if !inserted {
+				untouch()
+				continue
+			}
+			g.stored(untouch)
+			result.Affected++
+			if result.Inserted != nil {
+				result.Inserted[g.positions[valIndex]] = true
+			}
+			continue
+		}
+		untouch := g.meta.touchUpdated(val)
+		untouches = append(untouches, untouch)
+		batch = append(batch, val)
+		positions = append(positions, g.positions[valIndex])
+		if err := g.meta.setContentHash(val); err != nil {
+			untouchAll(untouches)
+			return err
+		}
+	}
+	rowsPerStatement := max(1, maxBatchParams/max(1, len(g.meta.fields)))
+	for len(batch) > 0 {
+		count := min(rowsPerStatement, len(batch))
+		if err := g.insert(ctx, execer, batch[:count], positions[:count], conflict, result); err != nil {
+			untouchAll(untouches)
+			return err
+		}
+		for index, untouch := range untouches[:count] {
+			if result.Inserted != nil && !result.Inserted[positions[index]] {
+				untouch()
+			} else {
+				g.stored(untouch)
+			}
+		}
+		batch = batch[count:]
+		positions = positions[count:]
+		untouches = untouches[count:]
+	}
+	return nil
+}
+
+// stored records the func restoring the updated field of a stored val, if the group collects them.
+func (g *batchGroup) stored(untouch func()) {
+	if g.untouches != nil {
+		*g.untouches = append(*g.untouches, untouch)
+	}
+}
+
+func untouchAll(untouches []func()) {
+	for _, untouch := range untouches {
+		untouch()
+	}
+}
+
+// pkeyKey returns a key identifying the encoded pkey params, converted to the values drivers are given.
+func pkeyKey(params []any) (string, error) {
+	values := make([]any, len(params))
+	for index, param := range params {
+		value, err := driver.DefaultParameterConverter.ConvertValue(param)
+		if err != nil {
+			return "", withStack(err)
+		}
+		values[index] = value
+	}
+	return fmt.Sprintf("%#v", values), nil
+}
+
+func (g *batchGroup) insert(ctx context.Context, execer sqlx.ExecerContext, vals []reflect.Value, positions []int, conflict string, result *UpsertAllResult) error {
+	cols := make([]string, len(g.meta.fields))
+	qmarks := make([]string, len(g.meta.fields))
+	for fieldIndex, field := range g.meta.fields {
+		cols[fieldIndex] = fmt.Sprintf("`%s`", field.col)
+		qmarks[fieldIndex] = "?"
+	}
+	row := fmt.Sprintf("(%s)", strings.Join(qmarks, ","))
+	rows := make([]string, len(vals))
+	params := make([]any, 0, len(vals)*len(g.meta.fields))
+	// pending has the positions of the elements with each pkey, in the order they were inserted.
+	pending := map[string][]int{}
+	for valIndex, val := range vals {
+		rows[valIndex] = row
+		pkey := []any{}
+		for _, field := range g.meta.fields {
+			param, err := field.encode(val.Field(field.index))
+			if err != nil {
+				return err
+			}
+			params = append(params, param)
+			if field.pkey {
+				pkey = append(pkey, param)
+			}
+		}
+		key, err := pkeyKey(pkey)
+		if err != nil {
+			return err
+		}
+		pending[key] = append(pending[key], positions[valIndex])
+	}
+	ctx = labeled(ctx, execer, "upsertAll", g.meta.typ.Name())
+	query := fmt.Sprintf("INSERT %sINTO %s (%s) VALUES %s", conflict, quoteTable(g.meta.table), strings.Join(cols, ","), strings.Join(rows, ","))
+	queryer, ok := execer.(sqlx.QueryerContext)
+	if result.Inserted == nil || !ok {
+		res, err := execer.ExecContext(ctx, query, params...)
+		if err != nil {
+			return viewError(ctx, execer, g.meta, withStack(err))
+		}
+		if affected, err := res.RowsAffected(); err == nil {
+			result.Affected += affected
+		}
+		return nil
+	}
+	pkeyCols := make([]string, len(g.meta.pkeys))
+	for index, field := range g.meta.pkeys {
+		pkeyCols[index] = fmt.Sprintf("`%s`", field.col)
+	}
+	returned, err := queryer.QueryxContext(ctx, fmt.Sprintf("%s RETURNING %s", query, strings.Join(pkeyCols, ",")), params...)
+	if err != nil {
+		return viewError(ctx, execer, g.meta, withStack(err))
+	}
+	defer returned.Close()
+	// The returned pkeys are scanned into their fields and encoded like the inserted ones, so that types the driver returns differently,
+	// like bools, times and driver.Valuers, get the same keys.
+	returnedVal := reflect.New(g.meta.typ).Elem()
+	dests := make([]any, len(g.meta.pkeys))
+	for index, field := range g.meta.pkeys {
+		dests[index] = field.scanDest(returnedVal.Field(field.index))
+	}
+	stored := int64(0)
+	for returned.Next() {
+		if err := returned.Scan(dests...); err != nil {
+			return withStack(err)
+		}
+		pkey := make([]any, len(g.meta.pkeys))
+		for index, field := range g.meta.pkeys {
+			if pkey[index], err = field.encode(returnedVal.Field(field.index)); err != nil {
+				return err
+			}
+		}
+		key, err := pkeyKey(pkey)
+		if err != nil {
+			return err
+		}
+		if waiting := pending[key]; len(waiting) > 0 {
+			result.Inserted[waiting[0]] = true
+			pending[key] = waiting[1:]
+		}
+		stored++
+	}
+	if err := returned.Err(); err != nil {
+		return withStack(err)
+	}
+	result.Affected += stored
+	if tx, ok := execer.(*Tx); ok {
+		// Rows returned by queries aren't tallied by ExecContext.
+		tx.affected += stored
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package sqly
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type event interface {
+	isEvent()
+}
+
+type createdEvent struct {
+	Id   int `sqly:"pkey,autoinc"`
+	Name string
+}
+
+func (c *createdEvent) isEvent() {}
+
+type deletedEvent struct {
+	Id     int `sqly:"pkey"`
+	Reason string
+}
+
+func (d *deletedEvent) isEvent() {}
+
+type dayFlag struct {
+	Day  SQLTimeText `sqly:"pkey"`
+	Flag bool        `sqly:"pkey"`
+}
+
+func (dayFlag) SQLYWithoutRowID() bool {
+	return true
+}
+
+func countRows(t *testing.T, db *DB, table string) int {
+	t.Helper()
+	count := 0
+	noerr(t, db.Get(&count, "SELECT COUNT(*) FROM `"+table+"`"))
+	return count
+}
+
+func TestUpsertAll(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, createdEvent{}))
+		noerr(t, db.CreateTableIfNotExists(ctx, deletedEvent{}))
+		events := []event{}
+		for i := 0; i < 600; i++ {
+			events = append(events, &deletedEvent{Id: i + 1, Reason: "gone"})
+		}
+		created := &createdEvent{Name: "a"}
+		events = append(events, created, &createdEvent{Id: 100, Name: "b"})
+		noerr(t, db.UpsertAll(ctx, events, false))
+		if created.Id == 0 {
+			t.Errorf("wanted a new primary key, got 0")
+		}
+		if got := countRows(t, db, "deletedEvent"); got != 600 {
+			t.Errorf("got %v deletedEvents, wanted 600", got)
+		}
+		if got := countRows(t, db, "createdEvent"); got != 2 {
+			t.Errorf("got %v createdEvents, wanted 2", got)
+		}
+
+		yeserr(t, db.UpsertAll(ctx, []any{&createdEvent{Name: "c"}, &deletedEvent{Id: 1}}, false))
+		if got := countRows(t, db, "createdEvent"); got != 2 {
+			t.Errorf("got %v createdEvents after failed UpsertAll, wanted 2", got)
+		}
+		noerr(t, db.UpsertAll(ctx, []any{&createdEvent{Name: "c"}, &deletedEvent{Id: 1, Reason: "replaced"}}, true))
+		reason := ""
+		noerr(t, db.Get(&reason, "SELECT Reason FROM deletedEvent WHERE Id = 1"))
+		if reason != "replaced" {
+			t.Errorf("got %q, wanted \"replaced\"", reason)
+		}
+
+		yeserr(t, db.UpsertAll(ctx, []any{createdEvent{}}, false))
+		yeserr(t, db.UpsertAll(ctx, &createdEvent{}, false))
+	})
+}
+
+func TestUpsertAllWithResult(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, createdEvent{}))
+		noerr(t, db.CreateTableIfNotExists(ctx, deletedEvent{}))
+		noerr(t, db.UpsertAll(ctx, []any{&deletedEvent{Id: 1, Reason: "old"}}, false))
+		created := &createdEvent{Name: "a"}
+		events := []event{&deletedEvent{Id: 1, Reason: "dup"}, &deletedEvent{Id: 2}, created, &deletedEvent{Id: 3}, &deletedEvent{Id: 2, Reason: "dup"}}
+		var result UpsertAllResult
+		affected, err := db.WriteAffected(ctx, func(tx *Tx) error {
+			var err error
+			result, err = tx.UpsertAllWithResult(ctx, events, ConflictIgnore)
+			return err
+		})
+		noerr(t, err)
+		if want := []bool{false, true, true, true, false}; !reflect.DeepEqual(result.Inserted, want) {
+			t.Errorf("got %v, wanted %v", result.Inserted, want)
+		}
+		if result.Affected != 3 || affected != 3 {
+			t.Errorf("got %v and %v affected, wanted 3", result.Affected, affected)
+		}
+		if created.Id == 0 {
+			t.Errorf("wanted a new primary key, got 0")
+		}
+		reason := ""
+		noerr(t, db.Get(&reason, "SELECT Reason FROM deletedEvent WHERE Id = 1"))
+		if reason != "old" {
+			t.Errorf("got %q, wanted the duplicate to be ignored", reason)
+		}
+
+		result, err = db.UpsertAllWithResult(ctx, []any{&deletedEvent{Id: 1, Reason: "new"}}, ConflictReplace)
+		noerr(t, err)
+		if !reflect.DeepEqual(result.Inserted, []bool{true}) {
+			t.Errorf("got %v, wanted the replacement to be stored", result.Inserted)
+		}
+		_, err = db.UpsertAllWithResult(ctx, []any{&deletedEvent{Id: 1}}, ConflictFail)
+		yeserr(t, err)
+
+		noerr(t, db.CreateTableIfNotExists(ctx, dayFlag{}))
+		day := ToSQLTimeText(time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC))
+		noerr(t, db.UpsertAll(ctx, []any{&dayFlag{Day: day}}, false))
+		result, err = db.UpsertAllWithResult(ctx, []any{&dayFlag{Day: day}, &dayFlag{Day: day, Flag: true}}, ConflictIgnore)
+		noerr(t, err)
+		if want := []bool{false, true}; !reflect.DeepEqual(result.Inserted, want) {
+			t.Errorf("got %v, wanted %v", result.Inserted, want)
+		}
+	})
+}
+
+func TestUpsertStream(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, deletedEvent{}))
+		ch := make(chan deletedEvent)
+		go func() {
+			defer close(ch)
+			for i := 0; i < 25; i++ {
+				ch <- deletedEvent{Id: i + 1, Reason: "streamed"}
+			}
+		}()
+		upserted, err := UpsertStream(ctx, db, ch, false, 10)
+		noerr(t, err)
+		if upserted != 25 || countRows(t, db, "deletedEvent") != 25 {
+			t.Errorf("got %v upserted and %v rows, wanted 25", upserted, countRows(t, db, "deletedEvent"))
+		}
+
+		cancelCtx, cancel := context.WithCancel(ctx)
+		ch = make(chan deletedEvent)
+		go func() {
+			for i := 0; i < 15; i++ {
+				ch <- deletedEvent{Id: i + 100}
+			}
+			cancel()
+		}()
+		upserted, err = UpsertStream(cancelCtx, db, ch, false, 10)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, wanted context.Canceled", err)
+		}
+		if upserted != 10 || countRows(t, db, "deletedEvent") != 35 {
+			t.Errorf("got %v upserted and %v rows, wanted the 10 of the first batch", upserted, countRows(t, db, "deletedEvent"))
+		}
+
+		ch = make(chan deletedEvent, 3)
+		ch <- deletedEvent{Id: 200}
+		ch <- deletedEvent{Id: 201}
+		ch <- deletedEvent{Id: 1}
+		close(ch)
+		upserted, err = UpsertStream(ctx, db, ch, false, 2)
+		yeserr(t, err)
+		if upserted != 2 || countRows(t, db, "deletedEvent") != 37 {
+			t.Errorf("got %v upserted and %v rows, wanted the 2 of the first batch", upserted, countRows(t, db, "deletedEvent"))
+		}
+		_, err = UpsertStream(ctx, db, ch, false, 0)
+		yeserr(t, err)
+	})
+}
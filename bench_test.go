@@ -0,0 +1,146 @@
+package sqly
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type discardResult struct{}
+
+func (discardResult) LastInsertId() (int64, error) {
+	return 1, nil
+}
+
+func (discardResult) RowsAffected() (int64, error) {
+	return 1, nil
+}
+
+type discardExecer struct{}
+
+func (discardExecer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return discardResult{}, nil
+}
+
+// BenchmarkUpsert measures the overhead of Upsert itself, without any database.
+// Caching the INSERT per type and pooling the params took it from 15 to 2 allocs/op:
+//
+//	before: BenchmarkUpsert  1000000  1174 ns/op  288 B/op  15 allocs/op
+//	after:  BenchmarkUpsert  2972781   392 ns/op   24 B/op   2 allocs/op
+func BenchmarkUpsert(b *testing.B) {
+	execer := discardExecer{}
+	val := &sharedTestStruct{Id: 1, Name: "a"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := Upsert(context.Background(), execer, val, true); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkUpsertSQLite and BenchmarkUpsertSQLiteCommented show that WithSQLComments costs nothing unless enabled,
+// where BenchmarkUpsertSQLite stays at 8 allocs/op like before comments existed:
+//
+//	BenchmarkUpsertSQLite           184 B/op   8 allocs/op
+//	BenchmarkUpsertSQLiteCommented 1200 B/op  23 allocs/op
+func BenchmarkUpsertSQLite(b *testing.B) {
+	benchmarkUpsertSQLite(b)
+}
+
+func BenchmarkUpsertSQLiteCommented(b *testing.B) {
+	benchmarkUpsertSQLite(b, WithSQLComments())
+}
+
+func benchmarkUpsertSQLite(b *testing.B, opts ...Option) {
+	db, err := Open("sqlite", ":memory:", opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if err := db.CreateTableIfNotExists(context.Background(), sharedTestStruct{}); err != nil {
+		b.Fatal(err)
+	}
+	val := &sharedTestStruct{Id: 1, Name: "a"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := db.Upsert(context.Background(), val, true); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkUpsertInWrite measures upserting 10k rows of the same type inside a single Write.
+// Reusing the INSERT statement prepared on the Tx saves about 10k allocs/op, while the time is dominated by SQLite and stays within the noise:
+//
+//	before: BenchmarkUpsertInWrite  2318750 B/op  109758 allocs/op
+//	after:  BenchmarkUpsertInWrite  2159497 B/op   99766 allocs/op
+func BenchmarkUpsertInWrite(b *testing.B) {
+	db, err := Open("sqlite", ":memory:")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if err := db.CreateTableIfNotExists(context.Background(), sharedTestStruct{}); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := db.Write(context.Background(), func(tx *Tx) error {
+			for j := 0; j < 10000; j++ {
+				if err := tx.Upsert(context.Background(), &sharedTestStruct{Id: j + 1, Name: "a"}, true); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkSmallWrites runs single row upserts from many goroutines against a file database, either in their own Writes or batched.
+func benchmarkSmallWrites(b *testing.B, batched bool, opts ...Option) {
+	db, err := Open("sqlite", filepath.Join(b.TempDir(), "bench.db"), opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.CreateTableIfNotExists(context.Background(), sharedTestStruct{}); err != nil {
+		b.Fatal(err)
+	}
+	write := db.Write
+	if batched {
+		write = db.WriteBatched
+	}
+	id := atomic.Int64{}
+	b.ReportAllocs()
+	b.SetParallelism(8)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := write(context.Background(), func(tx *Tx) error {
+				return tx.Upsert(context.Background(), &sharedTestStruct{Id: int(id.Add(1)), Name: "a"}, true)
+			}); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkSmallWrites and BenchmarkSmallWritesBatched compare the throughput of single row upserts,
+// where batching pays for one commit per batch instead of one per upsert:
+//
+//	BenchmarkSmallWrites         592975 ns/op
+//	BenchmarkSmallWritesBatched  264088 ns/op
+func BenchmarkSmallWrites(b *testing.B) {
+	benchmarkSmallWrites(b, false)
+}
+
+func BenchmarkSmallWritesBatched(b *testing.B) {
+	benchmarkSmallWrites(b, true, WithWriteBatching(64, time.Millisecond, BatchFailIsolated))
+}
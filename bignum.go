@@ -0,0 +1,87 @@
+package sqly
+
+import (
+	"math/big"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	bigIntType = reflect.TypeOf(big.Int{})
+	bigRatType = reflect.TypeOf(big.Rat{})
+)
+
+func isBigNum(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ == bigIntType || typ == bigRatType
+}
+
+// bigNumParam converts a (pointer to a) big.Int or big.Rat to its String form, and nil pointers to NULL.
+// Since the values are stored as TEXT they sort lexicographically, not numerically.
+func bigNumParam(fieldVal reflect.Value) any {
+	for fieldVal.Kind() == reflect.Ptr {
+		if fieldVal.IsNil() {
+			return nil
+		}
+		fieldVal = fieldVal.Elem()
+	}
+	if !fieldVal.CanAddr() {
+		addressable := reflect.New(fieldVal.Type()).Elem()
+		addressable.Set(fieldVal)
+		fieldVal = addressable
+	}
+	return fieldVal.Addr().Interface().(interface{ String() string }).String()
+}
+
+// bigNumScanner scans TEXT, and the INTEGER or REAL values NUMERIC columns can convert them to, into a (pointer to a) big.Int or big.Rat.
+type bigNumScanner struct {
+	field reflect.Value
+}
+
+func (b bigNumScanner) Scan(src any) error {
+	if src == nil {
+		b.field.SetZero()
+		return nil
+	}
+	dst := b.field
+	for dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+	text := ""
+	switch typed := src.(type) {
+	case []byte:
+		text = string(typed)
+	case string:
+		text = typed
+	case int64:
+		text = big.NewInt(typed).String()
+	case float64:
+		rat := &big.Rat{}
+		if rat.SetFloat64(typed) == nil {
+			return errors.Errorf("can't scan %v into %v", typed, dst.Type())
+		}
+		text = rat.String()
+		if rat.IsInt() {
+			text = rat.Num().String()
+		}
+	default:
+		return errors.Errorf("can't scan %T into %v", src, dst.Type())
+	}
+	var ok bool
+	switch num := dst.Addr().Interface().(type) {
+	case *big.Int:
+		_, ok = num.SetString(text, 10)
+	case *big.Rat:
+		_, ok = num.SetString(text)
+	}
+	if !ok {
+		return errors.Errorf("can't parse %q as %v", text, dst.Type())
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package sqly
+
+import (
+	"math/big"
+	"testing"
+)
+
+type bigNumTestStruct struct {
+	Id       int64 `sqly:"pkey"`
+	Int      big.Int
+	Rat      big.Rat
+	IntPtr   *big.Int
+	RatPtr   *big.Rat
+	Numeric  big.Int  `sqly:"type(NUMERIC)"`
+	NumericR *big.Rat `sqly:"type(numeric)"`
+}
+
+func TestBigNums(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	third := big.NewRat(-1, 3)
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, bigNumTestStruct{}))
+		info := getTableInfo(t, db, "bigNumTestStruct")
+		for _, col := range info[1:5] {
+			if col.Type != "TEXT" {
+				t.Errorf("got %+v, wanted TEXT", col)
+			}
+		}
+		if info[5].Type != "NUMERIC" || info[6].Type != "NUMERIC" {
+			t.Errorf("got %+v and %+v, wanted NUMERIC", info[5], info[6])
+		}
+		stored := &bigNumTestStruct{
+			Id:       1,
+			IntPtr:   new(big.Int).Mul(huge, huge),
+			RatPtr:   new(big.Rat).Set(third),
+			NumericR: big.NewRat(5, 2),
+		}
+		stored.Int.Set(huge)
+		stored.Rat.SetFrac(huge, big.NewInt(7))
+		stored.Numeric.SetInt64(42)
+		noerr(t, db.Upsert(ctx, stored, false))
+		noerr(t, db.Upsert(ctx, &bigNumTestStruct{Id: 2}, false))
+		found, err := GetSQL[bigNumTestStruct](ctx, db, "SELECT * FROM bigNumTestStruct WHERE Id = 1")
+		noerr(t, err)
+		if found.Int.Cmp(&stored.Int) != 0 || found.Rat.Cmp(&stored.Rat) != 0 || found.IntPtr.Cmp(stored.IntPtr) != 0 || found.RatPtr.Cmp(stored.RatPtr) != 0 {
+			t.Errorf("got %+v, wanted %+v", found, stored)
+		}
+		if found.Numeric.Cmp(&stored.Numeric) != 0 || found.NumericR.Cmp(stored.NumericR) != 0 {
+			t.Errorf("got %v and %v, wanted %v and %v", &found.Numeric, found.NumericR, &stored.Numeric, stored.NumericR)
+		}
+		nulls, err := GetSQL[bigNumTestStruct](ctx, db, "SELECT * FROM bigNumTestStruct WHERE Id = 2")
+		noerr(t, err)
+		if nulls.IntPtr != nil || nulls.RatPtr != nil || nulls.Int.Sign() != 0 {
+			t.Errorf("got %+v, wanted zero and nil values", nulls)
+		}
+		nullCount := 0
+		noerr(t, db.Get(&nullCount, "SELECT COUNT(*) FROM bigNumTestStruct WHERE IntPtr IS NULL"))
+		if nullCount != 1 {
+			t.Errorf("got %v NULL IntPtrs, wanted 1", nullCount)
+		}
+		example := bigNumTestStruct{}
+		example.Int.Set(huge)
+		byExample, err := SelectByExample(ctx, db, example)
+		noerr(t, err)
+		if len(byExample) != 1 || byExample[0].Id != 1 {
+			t.Errorf("got %+v, wanted the row with Id 1", byExample)
+		}
+		_, err = db.Exec("UPDATE bigNumTestStruct SET Int = 'not a number' WHERE Id = 2")
+		noerr(t, err)
+		_, err = GetSQL[bigNumTestStruct](ctx, db, "SELECT * FROM bigNumTestStruct WHERE Id = 2")
+		yeserr(t, err)
+	})
+}
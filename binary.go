@@ -0,0 +1,83 @@
+package sqly
+
+import (
+	"database/sql/driver"
+	"encoding"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+	valuerType            = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	timeType              = reflect.TypeOf(time.Time{})
+)
+
+// isBinary returns whether typ, or what it points to, is stored as the BLOB from MarshalBinary and scanned using UnmarshalBinary.
+// Byte arrays, big numbers and driver.Valuers keep their own handling, and time.Time is rejected by sqlTypeOf in favor of SQLTime and SQLTimeText.
+func isBinary(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if isByteArray(typ) || isBigNum(typ) || typ == timeType {
+		return false
+	}
+	ptr := reflect.PointerTo(typ)
+	return ptr.Implements(binaryMarshalerType) && ptr.Implements(binaryUnmarshalerType) && !ptr.Implements(valuerType)
+}
+
+// binaryParam converts a (pointer to a) BinaryMarshaler to its MarshalBinary bytes, and nil pointers to NULL.
+// Zero values are marshaled like any other value, and empty results are stored as empty BLOBs, not NULL.
+func binaryParam(fieldVal reflect.Value) (any, error) {
+	for fieldVal.Kind() == reflect.Ptr {
+		if fieldVal.IsNil() {
+			return nil, nil
+		}
+		fieldVal = fieldVal.Elem()
+	}
+	if !fieldVal.CanAddr() {
+		addressable := reflect.New(fieldVal.Type()).Elem()
+		addressable.Set(fieldVal)
+		fieldVal = addressable
+	}
+	result, err := fieldVal.Addr().Interface().(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, withStack(err)
+	}
+	if result == nil {
+		result = []byte{}
+	}
+	return result, nil
+}
+
+// binaryScanner scans BLOBs into a (pointer to a) BinaryUnmarshaler.
+type binaryScanner struct {
+	field reflect.Value
+}
+
+func (b binaryScanner) Scan(src any) error {
+	if src == nil {
+		b.field.SetZero()
+		return nil
+	}
+	var raw []byte
+	switch typed := src.(type) {
+	case []byte:
+		raw = append([]byte{}, typed...)
+	case string:
+		raw = []byte(typed)
+	default:
+		return errors.Errorf("can't scan %T into %v", src, b.field.Type())
+	}
+	dst := b.field
+	for dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+	return withStack(dst.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(raw))
+}
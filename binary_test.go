@@ -0,0 +1,86 @@
+package sqly
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type binaryPoint struct {
+	x, y int32
+}
+
+func (p binaryPoint) MarshalBinary() ([]byte, error) {
+	return binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, uint32(p.x)), uint32(p.y)), nil
+}
+
+func (p *binaryPoint) UnmarshalBinary(b []byte) error {
+	if len(b) != 8 {
+		return errors.Errorf("got %v bytes, wanted 8", len(b))
+	}
+	p.x, p.y = int32(binary.BigEndian.Uint32(b)), int32(binary.BigEndian.Uint32(b[4:]))
+	return nil
+}
+
+type binaryTestStruct struct {
+	Id       int `sqly:"pkey"`
+	Point    binaryPoint
+	PointPtr *binaryPoint
+}
+
+func TestBinaryMarshalers(t *testing.T) {
+	withDB(t, func(db *DB) {
+		executed, err := db.CreateTableIfNotExistsVerbose(ctx, binaryTestStruct{})
+		noerr(t, err)
+		if want := "CREATE TABLE IF NOT EXISTS `binaryTestStruct` (`Id` INTEGER PRIMARY KEY, `Point` BLOB, `PointPtr` BLOB)"; executed[0] != want {
+			t.Errorf("got %q, wanted %q", executed[0], want)
+		}
+		want := []binaryTestStruct{
+			{Id: 1, Point: binaryPoint{x: -1, y: 2}, PointPtr: &binaryPoint{x: 3, y: 4}},
+			{Id: 2},
+		}
+		for index := range want {
+			noerr(t, db.Upsert(ctx, &want[index], false))
+		}
+		stored := []struct {
+			Point    []byte
+			PointPtr []byte
+		}{}
+		noerr(t, db.Select(&stored, "SELECT Point, PointPtr FROM binaryTestStruct ORDER BY Id"))
+		if len(stored) != 2 || len(stored[0].Point) != 8 || len(stored[1].Point) != 8 || stored[1].PointPtr != nil {
+			t.Errorf("got %+v, wanted the zero point stored as 8 bytes and the nil pointer as NULL", stored)
+		}
+		got, err := SelectSQL[binaryTestStruct](ctx, db, "SELECT * FROM binaryTestStruct ORDER BY Id")
+		noerr(t, err)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, wanted %+v", got, want)
+		}
+		found, err := SelectByExample(ctx, db, binaryTestStruct{PointPtr: &binaryPoint{x: 3, y: 4}})
+		noerr(t, err)
+		if !reflect.DeepEqual(found, want[:1]) {
+			t.Errorf("got %+v, wanted %+v", found, want[:1])
+		}
+		_, err = db.Exec("UPDATE binaryTestStruct SET Point = x'00' WHERE Id = 2")
+		noerr(t, err)
+		_, err = GetSQL[binaryTestStruct](ctx, db, "SELECT * FROM binaryTestStruct WHERE Id = 2")
+		yeserr(t, err)
+	})
+}
+
+type timeTestStruct struct {
+	Id   int `sqly:"pkey"`
+	When time.Time
+}
+
+func TestBinaryMarshalersExcludeTime(t *testing.T) {
+	withDB(t, func(db *DB) {
+		err := db.CreateTableIfNotExists(ctx, timeTestStruct{})
+		if err == nil || !strings.Contains(err.Error(), "use SQLTime or SQLTimeText") {
+			t.Errorf("got %v, wanted time.Time to be rejected in favor of SQLTime or SQLTimeText", err)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CheckpointMode is the mode of a WAL checkpoint, see https://www.sqlite.org/pragma.html#pragma_wal_checkpoint.
+type CheckpointMode string
+
+const (
+	// CheckpointPassive checkpoints as many frames as possible without waiting for readers or writers.
+	CheckpointPassive CheckpointMode = "PASSIVE"
+	// CheckpointFull waits for writers, and checkpoints all frames.
+	CheckpointFull CheckpointMode = "FULL"
+	// CheckpointRestart works like CheckpointFull, and also waits for readers so that the next writer restarts the WAL from the beginning.
+	CheckpointRestart CheckpointMode = "RESTART"
+	// CheckpointTruncate works like CheckpointRestart, and also truncates the WAL to zero bytes.
+	CheckpointTruncate CheckpointMode = "TRUNCATE"
+)
+
+func (mode CheckpointMode) validate() error {
+	switch mode {
+	case CheckpointPassive, CheckpointFull, CheckpointRestart, CheckpointTruncate:
+		return nil
+	}
+	return errors.Errorf("unknown checkpoint mode %q", mode)
+}
+
+// CheckpointResult is the outcome of a checkpoint.
+// LogFrames and CheckpointedFrames are -1 if the database isn't in WAL mode.
+type CheckpointResult struct {
+	// Busy is whether the checkpoint couldn't complete because of other connections.
+	Busy               bool
+	LogFrames          int
+	CheckpointedFrames int
+}
+
+// Checkpoint runs a WAL checkpoint using PRAGMA wal_checkpoint.
+// All modes except CheckpointPassive hold the write lock while checkpointing, since they need the Writes, and for RESTART and TRUNCATE also the Reads,
+// to finish before they can complete.
+func (db *DB) Checkpoint(ctx context.Context, mode CheckpointMode) (CheckpointResult, error) {
+	result, err := db.checkpoint(ctx, mode)
+	return result, db.mapError(err)
+}
+
+func (db *DB) checkpoint(ctx context.Context, mode CheckpointMode) (CheckpointResult, error) {
+	if !isSQLiteDriver(db.DriverName()) {
+		return CheckpointResult{}, errors.Errorf("checkpointing isn't supported for driver %q", db.DriverName())
+	}
+	if err := mode.validate(); err != nil {
+		return CheckpointResult{}, err
+	}
+	if mode != CheckpointPassive {
+		if err := db.lockContext(ctx); err != nil {
+			return CheckpointResult{}, err
+		}
+		defer db.locker.Unlock()
+	}
+	rows, err := db.QueryxContext(labeled(ctx, db, "checkpoint", ""), fmt.Sprintf("PRAGMA wal_checkpoint(%s)", mode))
+	if err != nil {
+		return CheckpointResult{}, withStack(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return CheckpointResult{}, withStack(err)
+		}
+		return CheckpointResult{}, errors.Errorf("wal_checkpoint returned no rows")
+	}
+	busy := 0
+	result := CheckpointResult{}
+	if err := rows.Scan(&busy, &result.LogFrames, &result.CheckpointedFrames); err != nil {
+		return CheckpointResult{}, withStack(err)
+	}
+	result.Busy = busy != 0
+	return result, nil
+}
+
+// WithCheckpointing makes the DB run Checkpoint with mode every interval, until it's closed.
+// callback, if not nil, is called with the outcome of each checkpoint.
+func WithCheckpointing(interval time.Duration, mode CheckpointMode, callback func(CheckpointResult, error)) Option {
+	return func(db *DB) error {
+		if !isSQLiteDriver(db.DriverName()) {
+			return errors.Errorf("checkpointing isn't supported for driver %q", db.DriverName())
+		}
+		if interval <= 0 {
+			return errors.Errorf("checkpoint interval %v isn't positive", interval)
+		}
+		if err := mode.validate(); err != nil {
+			return err
+		}
+		db.checkpointing = &periodic{
+			interval: interval,
+			run: func(ctx context.Context) {
+				result, err := db.Checkpoint(ctx, mode)
+				if ctx.Err() == nil && callback != nil {
+					callback(result, err)
+				}
+			},
+		}
+		return nil
+	}
+}
@@ -0,0 +1,80 @@
+package sqly
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func walSize(t *testing.T, dbPath string) int64 {
+	t.Helper()
+	info, err := os.Stat(dbPath + "-wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestCheckpoint(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open("sqlite", dbPath+"?_pragma=journal_mode(WAL)")
+	noerr(t, err)
+	defer db.Close()
+	noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+	noerr(t, db.Write(ctx, func(tx *Tx) error {
+		for i := 0; i < 1000; i++ {
+			if err := tx.Upsert(ctx, &sharedTestStruct{Id: i + 1, Name: strings.Repeat("a", 100)}, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	before := walSize(t, dbPath)
+	if before == 0 {
+		t.Fatalf("got an empty WAL after writing")
+	}
+	result, err := db.Checkpoint(ctx, CheckpointTruncate)
+	noerr(t, err)
+	if result.Busy {
+		t.Errorf("got a busy checkpoint")
+	}
+	if after := walSize(t, dbPath); after >= before {
+		t.Errorf("got WAL size %v after checkpointing, wanted less than %v", after, before)
+	}
+	result, err = db.Checkpoint(ctx, CheckpointPassive)
+	noerr(t, err)
+	if result.LogFrames != 0 || result.CheckpointedFrames != 0 {
+		t.Errorf("got %+v after truncating, wanted no frames", result)
+	}
+	_, err = db.Checkpoint(ctx, CheckpointMode("SOMETIMES"))
+	yeserr(t, err)
+
+	other, err := Open("fakepostgres", filepath.Join(t.TempDir(), "other.db"))
+	noerr(t, err)
+	defer other.Close()
+	_, err = other.Checkpoint(ctx, CheckpointPassive)
+	yeserr(t, err)
+	_, err = Open("fakepostgres", filepath.Join(t.TempDir(), "other.db"), WithCheckpointing(time.Second, CheckpointPassive, nil))
+	yeserr(t, err)
+}
+
+func TestCheckpointing(t *testing.T) {
+	results := make(chan error, 100)
+	withFileDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 1}, false))
+		select {
+		case err := <-results:
+			noerr(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got no checkpoint within 5s")
+		}
+	}, WithCheckpointing(10*time.Millisecond, CheckpointTruncate, func(result CheckpointResult, err error) {
+		select {
+		case results <- err:
+		default:
+		}
+	}))
+}
@@ -0,0 +1,195 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BatchFailure decides what happens to a coalesced batch when one of its callbacks fails.
+type BatchFailure int
+
+const (
+	// BatchFailIsolated runs each callback in its own savepoint, so a failing callback is rolled back and fails alone.
+	BatchFailIsolated BatchFailure = iota
+	// BatchFailAbort rolls back the whole batch when a callback fails, failing the other callbacks with ErrBatchAborted.
+	BatchFailAbort
+)
+
+// coalescer collects WriteBatched callbacks, and runs them in as few Write transactions as possible.
+type coalescer struct {
+	db        *DB
+	maxSize   int
+	window    time.Duration
+	onFailure BatchFailure
+
+	jobs     chan *writeJob
+	lock     sync.RWMutex
+	isClosed bool
+	closed   chan struct{}
+}
+
+// WithWriteBatching enables WriteBatched, which runs up to maxSize callbacks in one transaction,
+// or as many as arrive within window after the first one.
+func WithWriteBatching(maxSize int, window time.Duration, onFailure BatchFailure) Option {
+	return func(db *DB) error {
+		if maxSize < 1 {
+			return errors.Errorf("write batch size %v is less than 1", maxSize)
+		}
+		if onFailure != BatchFailIsolated && onFailure != BatchFailAbort {
+			return errors.Errorf("unknown BatchFailure %v", onFailure)
+		}
+		db.batching = &coalescer{
+			maxSize:   maxSize,
+			window:    window,
+			onFailure: onFailure,
+		}
+		return nil
+	}
+}
+
+func (c *coalescer) start(db *DB) {
+	c.db = db
+	c.jobs = make(chan *writeJob, c.maxSize)
+	c.closed = make(chan struct{})
+	go c.run()
+}
+
+func (c *coalescer) run() {
+	defer close(c.closed)
+	for first := range c.jobs {
+		batch := []*writeJob{first}
+		timer := time.NewTimer(c.window)
+	collect:
+		for len(batch) < c.maxSize {
+			select {
+			case job, ok := <-c.jobs:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, job)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+		c.flush(batch)
+	}
+}
+
+// flush runs the still queued jobs of batch in one Write, and completes all of them.
+func (c *coalescer) flush(batch []*writeJob) {
+	running := []*writeJob{}
+	for _, job := range batch {
+		if job.state.CompareAndSwap(writeJobQueued, writeJobRunning) {
+			running = append(running, job)
+		}
+	}
+	if len(running) == 0 {
+		return
+	}
+	results := make([]writeResult, len(running))
+	failed := -1
+	ctx := context.Background()
+	err := c.db.Write(ctx, func(tx *Tx) error {
+		for index, job := range running {
+			if err := job.ctx.Err(); err != nil {
+				results[index] = writeResult{err: withStack(err)}
+				continue
+			}
+			if c.onFailure == BatchFailAbort {
+				if results[index] = runBatched(tx, job.f); results[index].failed() {
+					failed = index
+					return results[index].err
+				}
+				continue
+			}
+			savepointErr := tx.SavepointExec(ctx, fmt.Sprintf("sqly_batch_%d", index), func(tx *Tx) error {
+				results[index] = runBatched(tx, job.f)
+				if results[index].failed() {
+					return results[index].err
+				}
+				return nil
+			})
+			if savepointErr != nil && !results[index].failed() {
+				results[index] = writeResult{err: savepointErr}
+			}
+		}
+		return nil
+	})
+	for index, job := range running {
+		result := results[index]
+		if err != nil && index != failed {
+			result = writeResult{err: errors.Wrapf(ErrBatchAborted, "%v", err)}
+		}
+		job.done <- result
+	}
+}
+
+// runBatched runs f, and returns its error or panic as a writeResult.
+func runBatched(tx *Tx, f func(*Tx) error) (result writeResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = writeResult{err: errors.Errorf("panic: %v", r), panicked: true, panicValue: r, stack: debug.Stack()}
+		}
+	}()
+	return writeResult{err: withStack(f(tx))}
+}
+
+func (r writeResult) failed() bool {
+	return r.err != nil || r.panicked
+}
+
+func (c *coalescer) submit(ctx context.Context, f func(*Tx) error) error {
+	job := &writeJob{ctx: ctx, f: f, done: make(chan writeResult, 1)}
+	if err := func() error {
+		c.lock.RLock()
+		defer c.lock.RUnlock()
+		if c.isClosed {
+			return withStack(ErrClosed)
+		}
+		select {
+		case c.jobs <- job:
+			return nil
+		case <-ctx.Done():
+			return withStack(ctx.Err())
+		}
+	}(); err != nil {
+		return err
+	}
+	select {
+	case result := <-job.done:
+		return result.get()
+	case <-ctx.Done():
+		if job.state.CompareAndSwap(writeJobQueued, writeJobCancelled) {
+			return withStack(ctx.Err())
+		}
+		return (<-job.done).get()
+	}
+}
+
+// close makes all future WriteBatched calls fail with ErrClosed, and waits for the queued callbacks to be flushed.
+func (c *coalescer) close() {
+	c.lock.Lock()
+	if !c.isClosed {
+		c.isClosed = true
+		close(c.jobs)
+	}
+	c.lock.Unlock()
+	<-c.closed
+}
+
+// WriteBatched queues f to run in a transaction shared with other WriteBatched callbacks, and waits for the transaction to commit.
+// How a failing f affects the rest of its batch is decided by the BatchFailure given to WithWriteBatching.
+// Since all callbacks of a batch share the transaction, f should use its own ctx for the statements it runs.
+// Panics in f are propagated to the goroutine calling WriteBatched.
+func (db *DB) WriteBatched(ctx context.Context, f func(*Tx) error) error {
+	if db.batching == nil {
+		return errors.Errorf("write batching isn't enabled, see WithWriteBatching")
+	}
+	return db.batching.submit(ctx, f)
+}
@@ -0,0 +1,130 @@
+package sqly
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// writeBatch runs fs concurrently with WriteBatched, and returns their errors in the order of fs.
+func writeBatch(db *DB, fs ...func(*Tx) error) []error {
+	errs := make([]error, len(fs))
+	wg := sync.WaitGroup{}
+	for index, f := range fs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[index] = db.WriteBatched(ctx, f)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestWriteBatchedIsolated(t *testing.T) {
+	withFileDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		lock := sync.Mutex{}
+		txs := map[*Tx]bool{}
+		upsert := func(id int) func(*Tx) error {
+			return func(tx *Tx) error {
+				lock.Lock()
+				txs[tx] = true
+				lock.Unlock()
+				return tx.Upsert(ctx, &sharedTestStruct{Id: id}, false)
+			}
+		}
+		errs := writeBatch(db, upsert(1), func(tx *Tx) error {
+			noerr(t, tx.Upsert(ctx, &sharedTestStruct{Id: 2}, false))
+			return errors.New("failed")
+		}, upsert(3))
+		noerr(t, errs[0])
+		if errs[1] == nil || errors.Cause(errs[1]).Error() != "failed" {
+			t.Errorf("got %v, wanted the error of the failing callback", errs[1])
+		}
+		noerr(t, errs[2])
+		if len(txs) != 1 {
+			t.Errorf("got %v transactions, wanted the batch to share one", len(txs))
+		}
+		ids := []int{}
+		noerr(t, db.Select(&ids, "SELECT Id FROM sharedTestStruct ORDER BY Id"))
+		if len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+			t.Errorf("got %v, wanted only the rows of the succeeding callbacks", ids)
+		}
+
+		errs = writeBatch(db, upsert(4), upsert(4), upsert(5))
+		failures := 0
+		for _, err := range errs {
+			if err != nil {
+				failures++
+			}
+		}
+		if failures != 1 {
+			t.Errorf("got %v, wanted only the duplicate upsert to fail", errs)
+		}
+		if count := countRows(t, db, "sharedTestStruct"); count != 4 {
+			t.Errorf("got %v rows, wanted 4", count)
+		}
+
+		func() {
+			defer func() {
+				if r := recover(); r != "boom" {
+					t.Errorf("got %v, wanted the panic to propagate", r)
+				}
+			}()
+			db.WriteBatched(ctx, func(tx *Tx) error {
+				noerr(t, tx.Upsert(ctx, &sharedTestStruct{Id: 100}, false))
+				panic("boom")
+			})
+		}()
+		if count := countRows(t, db, "sharedTestStruct"); count != 4 {
+			t.Errorf("got %v rows, wanted the panicking callback rolled back", count)
+		}
+	}, WithWriteBatching(3, time.Second, BatchFailIsolated))
+}
+
+func TestWriteBatchedAbort(t *testing.T) {
+	withFileDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		errs := writeBatch(db, func(tx *Tx) error {
+			return tx.Upsert(ctx, &sharedTestStruct{Id: 1}, false)
+		}, func(tx *Tx) error {
+			return errors.New("failed")
+		}, func(tx *Tx) error {
+			return tx.Upsert(ctx, &sharedTestStruct{Id: 3}, false)
+		})
+		if errs[1] == nil || errors.Is(errs[1], ErrBatchAborted) {
+			t.Errorf("got %v, wanted the error of the failing callback", errs[1])
+		}
+		for _, err := range []error{errs[0], errs[2]} {
+			if !errors.Is(err, ErrBatchAborted) {
+				t.Errorf("got %v, wanted ErrBatchAborted", err)
+			}
+		}
+		if count := countRows(t, db, "sharedTestStruct"); count != 0 {
+			t.Errorf("got %v rows, wanted the whole batch rolled back", count)
+		}
+	}, WithWriteBatching(3, time.Second, BatchFailAbort))
+}
+
+func TestWriteBatchedWindow(t *testing.T) {
+	db, err := Open("sqlite", ":memory:", WithWriteBatching(100, 10*time.Millisecond, BatchFailIsolated))
+	noerr(t, err)
+	start := time.Now()
+	noerr(t, db.WriteBatched(ctx, func(tx *Tx) error { return nil }))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("got %v, wanted the window to flush a partial batch", elapsed)
+	}
+	noerr(t, db.Close())
+	if err := db.WriteBatched(ctx, func(tx *Tx) error { return nil }); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, wanted ErrClosed", err)
+	}
+	plain, err := Open("sqlite", ":memory:")
+	noerr(t, err)
+	defer plain.Close()
+	if err := plain.WriteBatched(ctx, func(tx *Tx) error { return nil }); err == nil {
+		t.Errorf("wanted an error without WithWriteBatching")
+	}
+}
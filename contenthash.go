@@ -0,0 +1,108 @@
+package sqly
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WithContentHash replaces SHA-256 as the hash computed for fields tagged `sqly:"contenthash"`.
+func WithContentHash(newHash func() hash.Hash) Option {
+	return func(db *DB) error {
+		db.contentHash = newHash
+		return nil
+	}
+}
+
+// nullHashLength is written instead of the length of NULL values, to distinguish NULL from empty values.
+const nullHashLength = ^uint64(0)
+
+// setContentHash stores the hash of the fields of val tagged `sqly:"hashed"` in the field tagged `sqly:"contenthash"`, if any.
+//
+// The hash is computed by writing, for each hashed field in declaration order, the column name and the value, each as a big endian uint64 length followed by the bytes.
+// Values are hashed as they are stored, before any transformer is applied, with numbers, bools and times formatted in their canonical text form.
+// String contenthash fields get the hex encoded hash, and []byte fields get the raw hash.
+func (meta *tableMeta) setContentHash(val reflect.Value) error {
+	if meta.contentHash == nil {
+		return nil
+	}
+	hash := meta.newHash()
+	write := func(b []byte, null bool) {
+		length := uint64(len(b))
+		if null {
+			length = nullHashLength
+		}
+		binary.Write(hash, binary.BigEndian, length)
+		hash.Write(b)
+	}
+	for _, field := range meta.fields {
+		if !field.hashed {
+			continue
+		}
+		b, null, err := field.hashBytes(val.Field(field.index))
+		if err != nil {
+			return err
+		}
+		write([]byte(field.col), false)
+		write(b, null)
+	}
+	sum := hash.Sum(nil)
+	fieldVal := val.Field(meta.contentHash.index)
+	if fieldVal.Kind() == reflect.String {
+		fieldVal.SetString(hex.EncodeToString(sum))
+	} else {
+		fieldVal.SetBytes(sum)
+	}
+	return nil
+}
+
+func (field *fieldMeta) hashBytes(fieldVal reflect.Value) ([]byte, bool, error) {
+	if field.transformer != nil {
+		if fieldVal.Kind() == reflect.String {
+			return []byte(fieldVal.String()), false, nil
+		}
+		return fieldVal.Bytes(), fieldVal.IsNil(), nil
+	}
+	param, err := field.encode(fieldVal)
+	if err != nil {
+		return nil, false, err
+	}
+	value, err := driver.DefaultParameterConverter.ConvertValue(param)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "hashing %q", field.name)
+	}
+	switch typed := value.(type) {
+	case nil:
+		return nil, true, nil
+	case []byte:
+		return typed, false, nil
+	case string:
+		return []byte(typed), false, nil
+	case time.Time:
+		return []byte(typed.UTC().Format(time.RFC3339Nano)), false, nil
+	}
+	return []byte(fmt.Sprint(value)), false, nil
+}
+
+// conflictClause returns the conflict clause of the INSERT statements of meta.
+// Tables with a content hash ignore conflicts unless overwriting, so that storing the same content twice is a no-op.
+func (meta *tableMeta) conflictClause(overwrite bool) string {
+	switch {
+	case overwrite:
+		return "OR REPLACE "
+	case meta.contentHash != nil:
+		return "OR IGNORE "
+	}
+	return ""
+}
+
+var (
+	defaultContentHash = sha256.New
+)
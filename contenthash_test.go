@@ -0,0 +1,72 @@
+package sqly
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+type blobTestStruct struct {
+	Id      int64  `sqly:"pkey,autoinc"`
+	Hash    string `sqly:"contenthash"`
+	Kind    string `sqly:"hashed"`
+	Size    int    `sqly:"hashed"`
+	Payload []byte `sqly:"hashed,transform=gzip"`
+	Seen    int
+}
+
+type rawHashTestStruct struct {
+	Id   int64  `sqly:"pkey"`
+	Hash []byte `sqly:"contenthash"`
+	Name string `sqly:"hashed"`
+}
+
+type unhashedTestStruct struct {
+	Id   int64  `sqly:"pkey"`
+	Hash string `sqly:"contenthash"`
+}
+
+func TestContentHash(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, blobTestStruct{}))
+		first := &blobTestStruct{Kind: "text", Size: 5, Payload: []byte("hello"), Seen: 1}
+		noerr(t, db.Upsert(ctx, first, false))
+		hash := sha256.New()
+		for _, b := range [][]byte{[]byte("Kind"), []byte("text"), []byte("Size"), []byte("5"), []byte("Payload"), []byte("hello")} {
+			binary.Write(hash, binary.BigEndian, uint64(len(b)))
+			hash.Write(b)
+		}
+		if want := hex.EncodeToString(hash.Sum(nil)); first.Hash != want {
+			t.Errorf("got %q, wanted %q", first.Hash, want)
+		}
+		noerr(t, db.Upsert(ctx, &blobTestStruct{Kind: "text", Size: 5, Payload: []byte("hello"), Seen: 2}, false))
+		noerr(t, db.UpsertAll(ctx, []*blobTestStruct{{Id: 10, Kind: "text", Size: 5, Payload: []byte("hello")}, {Id: 11, Kind: "text", Size: 6, Payload: []byte("hello!")}}, false))
+		if count := countRows(t, db, "blobTestStruct"); count != 2 {
+			t.Errorf("got %v rows, wanted 2", count)
+		}
+		again := &blobTestStruct{Kind: "text", Size: 5, Payload: []byte("hello"), Seen: 3}
+		created, err := db.GetOrCreate(ctx, again)
+		noerr(t, err)
+		if created || again.Id != first.Id || again.Seen != 1 {
+			t.Errorf("got %v and %+v, wanted the first row", created, again)
+		}
+
+		noerr(t, db.CreateTableIfNotExists(ctx, rawHashTestStruct{}))
+		raw := &rawHashTestStruct{Id: 1, Name: "a"}
+		noerr(t, db.Upsert(ctx, raw, false))
+		if len(raw.Hash) != sha256.Size {
+			t.Errorf("got %x, wanted a raw SHA-256 hash", raw.Hash)
+		}
+		yeserr(t, db.CreateTableIfNotExists(ctx, unhashedTestStruct{}))
+	})
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, rawHashTestStruct{}))
+		raw := &rawHashTestStruct{Id: 1, Name: "a"}
+		noerr(t, db.Upsert(ctx, raw, false))
+		if len(raw.Hash) != md5.Size {
+			t.Errorf("got %x, wanted a raw MD5 hash", raw.Hash)
+		}
+	}, WithContentHash(md5.New))
+}
@@ -0,0 +1,237 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TableCopy describes the copying of one table by CopyAll.
+type TableCopy struct {
+	Table    string
+	Rows     int64
+	Duration time.Duration
+}
+
+// CopyReport describes what CopyAll copied, in the order of the prototypes.
+type CopyReport struct {
+	Tables []TableCopy
+}
+
+// CopyAll copies the rows of the tables of prototypes from src to dst, creating the tables in dst if they don't exist.
+// The rows are read in pkey order in a Read of src, scanned into their structs, and inserted with their pkeys into dst 1000 at a time,
+// each batch in its own Write, so it works across dialects. Rows conflicting with rows already in dst fail the copy.
+// The report lists the tables copied so far, also when an error is returned.
+func CopyAll(ctx context.Context, src *DB, dst *DB, prototypes ...any) (CopyReport, error) {
+	report := CopyReport{}
+	if src == dst {
+		return report, errors.Errorf("can't copy a DB to itself")
+	}
+	for _, prototype := range prototypes {
+		srcMeta, err := metaOf(src, prototype)
+		if err != nil {
+			return report, err
+		}
+		if err := srcMeta.requirePrimaryKey(); err != nil {
+			return report, err
+		}
+		dstMeta, err := metaOf(dst, prototype)
+		if err != nil {
+			return report, err
+		}
+		if err := dst.CreateTableIfNotExists(ctx, prototype); err != nil {
+			return report, err
+		}
+		start := time.Now()
+		rows, err := copyTable(ctx, src, dst, srcMeta, "", nil, func(ctx context.Context, tx *Tx, val reflect.Value) (bool, error) {
+			return insertRow(ctx, tx, dstMeta, val, "", false)
+		})
+		report.Tables = append(report.Tables, TableCopy{Table: dstMeta.table, Rows: rows, Duration: time.Since(start)})
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// copyTable copies the rows of srcMeta in src matching where, or all rows if where is empty, to dst using insert, and returns the number of rows copied.
+// insert returns whether it inserted the row.
+func copyTable(ctx context.Context, src *DB, dst *DB, srcMeta *tableMeta, where string, args []any, insert func(context.Context, *Tx, reflect.Value) (bool, error)) (int64, error) {
+	copied := int64(0)
+	batch := make([]reflect.Value, 0, defaultImportBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		batchCopied := int64(0)
+		if err := dst.Write(ctx, func(tx *Tx) error {
+			ctx := labeled(ctx, tx, "copy", srcMeta.typ.Name())
+			for _, val := range batch {
+				inserted, err := insert(ctx, tx, val)
+				if err != nil {
+					return err
+				}
+				if inserted {
+					batchCopied++
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		copied += batchCopied
+		batch = batch[:0]
+		return nil
+	}
+	orderBy := make([]string, len(srcMeta.pkeys))
+	for index, field := range srcMeta.pkeys {
+		orderBy[index] = fmt.Sprintf("`%s`", field.col)
+	}
+	if where != "" {
+		where = fmt.Sprintf(" WHERE %s", where)
+	}
+	err := src.Read(ctx, func(tx *Tx) error {
+		query, args, err := expand(tx, fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s", srcMeta.columnsSQL(), quoteTable(srcMeta.table), where, strings.Join(orderBy, ",")), args)
+		if err != nil {
+			return err
+		}
+		rows, err := tx.QueryxContext(labeled(ctx, tx, "copy", srcMeta.typ.Name()), query, args...)
+		if err != nil {
+			return withStack(err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			val := reflect.New(srcMeta.typ).Elem()
+			if err := srcMeta.scanRow(rows, val); err != nil {
+				return err
+			}
+			if err := srcMeta.afterScan(val); err != nil {
+				return err
+			}
+			if batch = append(batch, val); len(batch) == cap(batch) {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return withStack(err)
+		}
+		return flush()
+	})
+	return copied, err
+}
+
+// CopyOptions configures CopyTable.
+type CopyOptions struct {
+	// SkipErrors makes rows that dst refuses, like rows violating its constraints, be skipped instead of failing the copy.
+	SkipErrors bool
+	// OnSkip, if not nil, is called with the pkey values in src, and the error, of each skipped row.
+	OnSkip func(pkey []any, err error)
+}
+
+// CopyTable copies the rows of the table of T in src matching where, or all rows if where is empty, to dst, and returns the number of rows copied.
+// The args are expanded like for GetSQL. The table is created in dst if it doesn't exist, and an existing table isn't changed.
+// remap renames source columns to the destination columns they're inserted into, which must all exist. Otherwise it copies like CopyAll.
+func CopyTable[T any](ctx context.Context, src *DB, dst *DB, where string, args []any, remap map[string]string, opts CopyOptions) (int64, error) {
+	if src == dst {
+		return 0, errors.Errorf("can't copy a DB to itself")
+	}
+	typ := reflect.TypeFor[T]()
+	srcMeta, err := metasFor(src).get(typ)
+	if err != nil {
+		return 0, err
+	}
+	if err := srcMeta.requirePrimaryKey(); err != nil {
+		return 0, err
+	}
+	dstMeta, err := metasFor(dst).get(typ)
+	if err != nil {
+		return 0, err
+	}
+	var existing map[string]string
+	if err := dst.Write(ctx, func(tx *Tx) error {
+		// An existing table is left as it is, since evolving it would add the columns remap renames away from.
+		exists, err := tableExists(ctx, tx, dst.DriverName(), dstMeta.table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if err := tx.CreateTableIfNotExists(ctx, reflect.New(typ).Elem().Interface()); err != nil {
+				return err
+			}
+		}
+		existing, err = existingColumns(ctx, tx, dst.DriverName(), dstMeta.table)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+	srcFields := map[string]bool{}
+	for _, field := range srcMeta.fields {
+		srcFields[field.col] = true
+	}
+	for from := range remap {
+		if !srcFields[from] {
+			return 0, errors.Errorf("%v has no column %q to remap", typ, from)
+		}
+	}
+	cols := make([]string, len(dstMeta.fields))
+	qmarks := make([]string, len(dstMeta.fields))
+	for index, field := range dstMeta.fields {
+		col := field.col
+		if to, found := remap[srcMeta.fields[index].col]; found {
+			col = to
+		}
+		if _, found := existing[col]; !found {
+			return 0, errors.Errorf("table %q in the destination has no column %q", dstMeta.table, col)
+		}
+		cols[index] = fmt.Sprintf("`%s`", col)
+		qmarks[index] = "?"
+	}
+	query := rebind(dst, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteTable(dstMeta.table), strings.Join(cols, ","), strings.Join(qmarks, ",")))
+	insert := func(ctx context.Context, tx *Tx, val reflect.Value) error {
+		if err := dstMeta.setContentHash(val); err != nil {
+			return err
+		}
+		// Omitted omitempty fields are inserted as the NULL they'd get by being left out of the INSERT.
+		omitted := dstMeta.omittedFields(val)
+		params := make([]any, len(dstMeta.fields))
+		for index, field := range dstMeta.fields {
+			if omitted&field.omitBit != 0 {
+				continue
+			}
+			param, err := field.encode(val.Field(field.index))
+			if err != nil {
+				return err
+			}
+			params[index] = param
+		}
+		_, err := tx.ExecContext(ctx, query, params...)
+		return withStack(err)
+	}
+	return copyTable(ctx, src, dst, srcMeta, where, args, func(ctx context.Context, tx *Tx, val reflect.Value) (bool, error) {
+		if !opts.SkipErrors {
+			return true, insert(ctx, tx, val)
+		}
+		if err := ctx.Err(); err != nil {
+			return false, withStack(err)
+		}
+		if err := tx.savepointExec(ctx, "sqly_copy_row", func(tx *Tx) error {
+			return insert(ctx, tx, val)
+		}); err != nil {
+			if opts.OnSkip != nil {
+				pkey := make([]any, len(srcMeta.pkeys))
+				for index, field := range srcMeta.pkeys {
+					pkey[index] = val.Field(field.index).Interface()
+				}
+				opts.OnSkip(pkey, err)
+			}
+			return false, nil
+		}
+		return true, nil
+	})
+}
@@ -0,0 +1,121 @@
+package sqly
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCopyAll(t *testing.T) {
+	dir := t.TempDir()
+	src, err := Open("sqlite", filepath.Join(dir, "src.db"))
+	noerr(t, err)
+	defer src.Close()
+	dst, err := Open("sqlite", filepath.Join(dir, "dst.db"))
+	noerr(t, err)
+	defer dst.Close()
+
+	noerr(t, src.CreateTableIfNotExists(ctx, createdEvent{}))
+	noerr(t, src.CreateTableIfNotExists(ctx, bigNumTestStruct{}))
+	noerr(t, src.Write(ctx, func(tx *Tx) error {
+		for i := 0; i < 2500; i++ {
+			if err := tx.Upsert(ctx, &createdEvent{Id: 3 * (i + 1), Name: fmt.Sprint(i)}, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	bigNum := bigNumTestStruct{Id: 1}
+	bigNum.Int.SetInt64(-42)
+	noerr(t, src.Upsert(ctx, &bigNum, false))
+
+	report, err := CopyAll(ctx, src, dst, createdEvent{}, bigNumTestStruct{})
+	noerr(t, err)
+	if len(report.Tables) != 2 || report.Tables[0].Table != "createdEvent" || report.Tables[0].Rows != 2500 || report.Tables[1].Table != "bigNumTestStruct" || report.Tables[1].Rows != 1 {
+		t.Errorf("got %+v, wanted 2500 createdEvents and 1 bigNumTestStruct", report)
+	}
+	wantEvents, err := SelectSQL[createdEvent](ctx, src, "SELECT * FROM createdEvent ORDER BY Id")
+	noerr(t, err)
+	gotEvents, err := SelectSQL[createdEvent](ctx, dst, "SELECT * FROM createdEvent ORDER BY Id")
+	noerr(t, err)
+	if !reflect.DeepEqual(gotEvents, wantEvents) {
+		t.Errorf("got %v copied events, wanted the %v events with their ids", len(gotEvents), len(wantEvents))
+	}
+	gotBigNum, err := GetSQL[bigNumTestStruct](ctx, dst, "SELECT * FROM bigNumTestStruct")
+	noerr(t, err)
+	if gotBigNum.Int.Int64() != -42 || gotBigNum.IntPtr != nil {
+		t.Errorf("got %+v, wanted %+v", gotBigNum, bigNum)
+	}
+
+	report, err = CopyAll(ctx, src, dst, createdEvent{})
+	yeserr(t, err)
+	if len(report.Tables) != 1 || report.Tables[0].Rows != 0 {
+		t.Errorf("got %+v, wanted no rows copied over existing rows", report)
+	}
+	_, err = CopyAll(ctx, src, src, createdEvent{})
+	yeserr(t, err)
+}
+
+type copiedTenantTestStruct struct {
+	Id     int `sqly:"pkey"`
+	Tenant int
+	Name   string
+}
+
+func TestCopyTable(t *testing.T) {
+	dir := t.TempDir()
+	open := func(name string) *DB {
+		db, err := Open("sqlite", filepath.Join(dir, name))
+		noerr(t, err)
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	src, filtered, remapped := open("src.db"), open("filtered.db"), open("remapped.db")
+	noerr(t, src.CreateTableIfNotExists(ctx, copiedTenantTestStruct{}))
+	for i, tenant := range []int{42, 7, 42, 42, 9} {
+		noerr(t, src.Upsert(ctx, &copiedTenantTestStruct{Id: i + 1, Tenant: tenant, Name: fmt.Sprint("name", i%2)}, false))
+	}
+
+	copied, err := CopyTable[copiedTenantTestStruct](ctx, src, filtered, "Tenant IN (?) AND Id > ?", []any{[]int{42, 9}, 1}, nil, CopyOptions{})
+	noerr(t, err)
+	if copied != 3 {
+		t.Errorf("got %v rows copied, wanted 3", copied)
+	}
+	got, err := SelectSQL[copiedTenantTestStruct](ctx, filtered, "SELECT * FROM copiedTenantTestStruct ORDER BY Id")
+	noerr(t, err)
+	if want := []copiedTenantTestStruct{{Id: 3, Tenant: 42, Name: "name0"}, {Id: 4, Tenant: 42, Name: "name1"}, {Id: 5, Tenant: 9, Name: "name0"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, wanted %+v", got, want)
+	}
+
+	_, err = remapped.Exec("CREATE TABLE copiedTenantTestStruct (Id INTEGER PRIMARY KEY, Tenant INTEGER, Title TEXT UNIQUE)")
+	noerr(t, err)
+	_, err = CopyTable[copiedTenantTestStruct](ctx, src, remapped, "", nil, map[string]string{"Title": "Name"}, CopyOptions{})
+	yeserr(t, err)
+	_, err = CopyTable[copiedTenantTestStruct](ctx, src, remapped, "", nil, map[string]string{"Name": "Label"}, CopyOptions{})
+	yeserr(t, err)
+	_, err = CopyTable[copiedTenantTestStruct](ctx, src, remapped, "", nil, map[string]string{"Name": "Title"}, CopyOptions{})
+	yeserr(t, err)
+	skipped := map[int]error{}
+	copied, err = CopyTable[copiedTenantTestStruct](ctx, src, remapped, "Tenant = ?", []any{42}, map[string]string{"Name": "Title"}, CopyOptions{
+		SkipErrors: true,
+		OnSkip: func(pkey []any, err error) {
+			skipped[pkey[0].(int)] = err
+		},
+	})
+	noerr(t, err)
+	if copied != 2 || len(skipped) != 1 || skipped[3] == nil || !strings.Contains(skipped[3].Error(), "UNIQUE") {
+		t.Errorf("got %v rows copied and %v skipped, wanted 2 copied and 3 skipped for violating UNIQUE", copied, skipped)
+	}
+	titles := []string{}
+	noerr(t, remapped.Select(&titles, "SELECT Title FROM copiedTenantTestStruct ORDER BY Id"))
+	if !reflect.DeepEqual(titles, []string{"name0", "name1"}) {
+		t.Errorf("got %q, wanted the names of rows 1 and 4", titles)
+	}
+	cols := []string{}
+	noerr(t, remapped.Select(&cols, "SELECT name FROM pragma_table_info('copiedTenantTestStruct') ORDER BY cid"))
+	if !reflect.DeepEqual(cols, []string{"Id", "Tenant", "Title"}) {
+		t.Errorf("got %q, wanted the existing destination table unchanged", cols)
+	}
+}
@@ -0,0 +1,293 @@
+package sqly
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CSVOptions configures ExportCSV and ImportCSV.
+type CSVOptions struct {
+	// Null is written for NULL values, and defaults to the empty string.
+	// When importing, fields with this value are left at their zero value.
+	Null string
+	// RFC3339Times makes SQLTime columns be written as RFC3339Nano UTC times instead of nanoseconds since the epoch.
+	// When importing, SQLTime columns are accepted in both formats.
+	RFC3339Times bool
+	// SkipUnknownColumns makes ImportCSV ignore the columns of the header that T doesn't have, instead of failing.
+	SkipUnknownColumns bool
+	// BatchSize is the number of rows ImportCSV inserts per Write, and defaults to 1000, like for ImportJSON.
+	BatchSize int
+}
+
+var (
+	sqlTimeType = reflect.TypeOf(SQLTime(0))
+)
+
+const (
+	defaultImportBatchSize = 1000
+)
+
+// ExportCSV writes the rows of the table of prototype to w as CSV, with a header row of the column names, and returns the number of rows written.
+// The rows are streamed one at a time, BLOBs are written as standard base64, and the export stops when ctx is done.
+// If q is a *DB the rows are read in a Read transaction.
+func ExportCSV(ctx context.Context, q Querier, prototype any, w io.Writer, opts CSVOptions) (int64, error) {
+	meta, err := metaOf(q, prototype)
+	if err != nil {
+		return 0, err
+	}
+	rows := int64(0)
+	err = readIn(ctx, q, func(q Querier) error {
+		rows, err = exportCSV(labeled(ctx, q, "exportCSV", meta.typ.Name()), q, meta, w, opts)
+		return err
+	})
+	return rows, err
+}
+
+func exportCSV(ctx context.Context, q Querier, meta *tableMeta, w io.Writer, opts CSVOptions) (int64, error) {
+	times := make([]bool, len(meta.fields))
+	record := make([]string, len(meta.fields))
+	for index, field := range meta.fields {
+		typ := field.typ
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		times[index] = opts.RFC3339Times && typ == sqlTimeType
+		record[index] = field.col
+	}
+	writer := csv.NewWriter(w)
+	if err := writer.Write(record); err != nil {
+		return 0, withStack(err)
+	}
+	rows, err := q.QueryxContext(ctx, fmt.Sprintf("SELECT %s FROM %s", meta.columnsSQL(), quoteTable(meta.table)))
+	if err != nil {
+		return 0, withStack(err)
+	}
+	defer rows.Close()
+	values := make([]any, len(meta.fields))
+	pointers := make([]any, len(meta.fields))
+	for index := range values {
+		pointers[index] = &values[index]
+	}
+	written := int64(0)
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return written, withStack(err)
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return written, withStack(err)
+		}
+		for index, value := range values {
+			record[index] = csvValue(value, times[index], opts)
+		}
+		if err := writer.Write(record); err != nil {
+			return written, withStack(err)
+		}
+		written++
+	}
+	if err := rows.Err(); err != nil {
+		return written, withStack(err)
+	}
+	writer.Flush()
+	return written, withStack(writer.Error())
+}
+
+// csvValue formats a value scanned from the database, formatting integers as times if isTime is set.
+func csvValue(value any, isTime bool, opts CSVOptions) string {
+	switch v := value.(type) {
+	case nil:
+		return opts.Null
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case string:
+		return v
+	case int64:
+		if isTime {
+			return SQLTime(v).Time().UTC().Format(time.RFC3339Nano)
+		}
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value)
+}
+
+// ImportCSV inserts the rows of the CSV in r, as written by ExportCSV, into the table of T, and returns the number of rows inserted.
+// The header row names the columns of the following rows, and columns of T missing from it are left at their zero value, so an absent autoinc
+// primary key gets generated. The rows are inserted BatchSize at a time, each batch in its own Write, so a failing row leaves the previous batches in place.
+func ImportCSV[T any](ctx context.Context, db *DB, r io.Reader, opts CSVOptions) (int64, error) {
+	meta, err := metasFor(db).get(reflect.TypeFor[T]())
+	if err != nil {
+		return 0, err
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return 0, errors.Errorf("no CSV header")
+	} else if err != nil {
+		return 0, withStack(err)
+	}
+	// The reader reuses the slice for the next record.
+	header = append([]string{}, header...)
+	fieldsByCol := map[string]*fieldMeta{}
+	for _, field := range meta.fields {
+		fieldsByCol[field.col] = field
+	}
+	fields := make([]*fieldMeta, len(header))
+	for index, col := range header {
+		field, found := fieldsByCol[col]
+		if !found && !opts.SkipUnknownColumns {
+			return 0, errors.Errorf("%v has no column %q", meta.typ, col)
+		}
+		fields[index] = field
+	}
+	inserted := int64(0)
+	for done := false; !done; {
+		batch := int64(0)
+		if err := db.Write(ctx, func(tx *Tx) error {
+			ctx := labeled(ctx, tx, "importCSV", meta.typ.Name())
+			for read := 0; read < batchSize; read++ {
+				if err := ctx.Err(); err != nil {
+					return withStack(err)
+				}
+				record, err := reader.Read()
+				if err == io.EOF {
+					done = true
+					return nil
+				} else if err != nil {
+					return withStack(err)
+				}
+				var element T
+				val := reflect.ValueOf(&element).Elem()
+				for index, text := range record {
+					if fields[index] == nil || text == opts.Null {
+						continue
+					}
+					if err := fields[index].parseCSV(val.Field(fields[index].index), text); err != nil {
+						line, _ := reader.FieldPos(index)
+						return errors.Wrapf(err, "line %d, column %q", line, header[index])
+					}
+				}
+				rowInserted, err := insertStruct(ctx, tx, meta, val, meta.conflictClause(false))
+				if err != nil {
+					line, _ := reader.FieldPos(0)
+					return errors.Wrapf(err, "line %d", line)
+				}
+				if rowInserted {
+					batch++
+				}
+			}
+			return nil
+		}); err != nil {
+			return inserted, err
+		}
+		inserted += batch
+	}
+	return inserted, nil
+}
+
+// parseCSV sets fieldVal from text formatted like ExportCSV formats the values of field.
+func (field *fieldMeta) parseCSV(fieldVal reflect.Value, text string) error {
+	switch {
+	case field.transformer != nil:
+		// Transformed values are stored, and exported, as encoded BLOBs.
+		encoded, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return withStack(err)
+		}
+		decoded, err := field.transformer.Decode(encoded)
+		if err != nil {
+			return errors.Wrapf(err, "decoding %q", field.name)
+		}
+		if fieldVal.Kind() == reflect.String {
+			fieldVal.SetString(string(decoded))
+		} else {
+			fieldVal.SetBytes(decoded)
+		}
+		return nil
+	case isBigNum(field.typ):
+		return bigNumScanner{field: fieldVal}.Scan(text)
+	case isByteArray(field.typ):
+		decoded, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return withStack(err)
+		}
+		return byteArrayScanner{field: fieldVal}.Scan(decoded)
+	case isBinary(field.typ):
+		decoded, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return withStack(err)
+		}
+		return binaryScanner{field: fieldVal}.Scan(decoded)
+	}
+	for fieldVal.Kind() == reflect.Ptr {
+		if fieldVal.IsNil() {
+			fieldVal.Set(reflect.New(fieldVal.Type().Elem()))
+		}
+		fieldVal = fieldVal.Elem()
+	}
+	if scanner, ok := fieldVal.Addr().Interface().(sql.Scanner); ok {
+		return withStack(scanner.Scan(text))
+	}
+	switch fieldVal.Kind() {
+	case reflect.String:
+		fieldVal.SetString(text)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(text, 10, fieldVal.Type().Bits())
+		if err != nil && fieldVal.Type() == sqlTimeType {
+			t, timeErr := time.Parse(time.RFC3339Nano, text)
+			if timeErr != nil {
+				return withStack(timeErr)
+			}
+			parsed, err = int64(ToSQLTime(t)), nil
+		}
+		if err != nil {
+			return withStack(err)
+		}
+		fieldVal.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(text, 10, fieldVal.Type().Bits())
+		if err != nil {
+			return withStack(err)
+		}
+		fieldVal.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(text, fieldVal.Type().Bits())
+		if err != nil {
+			return withStack(err)
+		}
+		fieldVal.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(text)
+		if err != nil {
+			return withStack(err)
+		}
+		fieldVal.SetBool(parsed)
+	case reflect.Slice:
+		decoded, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return withStack(err)
+		}
+		fieldVal.SetBytes(decoded)
+	default:
+		return errors.Errorf("can't parse %q into a %v", text, fieldVal.Type())
+	}
+	return nil
+}
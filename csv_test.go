@@ -0,0 +1,189 @@
+package sqly
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type csvTestStruct struct {
+	Id       int `sqly:"pkey"`
+	Name     string
+	Score    float64
+	Active   bool
+	Data     []byte
+	Created  SQLTime
+	Updated  SQLTimeText
+	Nickname *string
+}
+
+func TestExportCSV(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, csvTestStruct{}))
+		created := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+		nickname := "bob"
+		noerr(t, db.Upsert(ctx, &csvTestStruct{Id: 1, Name: "a, \"b\"", Score: 1.5, Active: true, Data: []byte{0, 1, 2}, Created: ToSQLTime(created), Updated: ToSQLTimeText(created), Nickname: &nickname}, false))
+		noerr(t, db.Upsert(ctx, &csvTestStruct{Id: 2}, false))
+
+		buf := &bytes.Buffer{}
+		rows, err := ExportCSV(ctx, db, csvTestStruct{}, buf, CSVOptions{})
+		noerr(t, err)
+		if rows != 2 {
+			t.Errorf("got %v rows, wanted 2", rows)
+		}
+		want := strings.Join([]string{
+			"Id,Name,Score,Active,Data,Created,Updated,Nickname",
+			"1,\"a, \"\"b\"\"\",1.5,1,AAEC,1704164645000000006,2024-01-02T03:04:05.000000006Z,bob",
+			"2,,0,0,,0,,",
+			"",
+		}, "\n")
+		if buf.String() != want {
+			t.Errorf("got\n%s\nwanted\n%s", buf.String(), want)
+		}
+
+		buf.Reset()
+		_, err = ExportCSV(ctx, db, csvTestStruct{}, buf, CSVOptions{Null: "NULL", RFC3339Times: true})
+		noerr(t, err)
+		lines := strings.Split(buf.String(), "\n")
+		if want := []string{
+			"1,\"a, \"\"b\"\"\",1.5,1,AAEC,2024-01-02T03:04:05.000000006Z,2024-01-02T03:04:05.000000006Z,bob",
+			"2,,0,0,NULL,1970-01-01T00:00:00Z,NULL,NULL",
+		}; !reflect.DeepEqual(lines[1:3], want) {
+			t.Errorf("got %q, wanted %q", lines[1:3], want)
+		}
+
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			rows, err := ExportCSV(ctx, tx, csvTestStruct{}, &bytes.Buffer{}, CSVOptions{})
+			if rows != 2 {
+				t.Errorf("got %v rows in a Tx, wanted 2", rows)
+			}
+			return err
+		}))
+	})
+}
+
+func TestExportCSVCancel(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, csvTestStruct{}))
+		for i := 0; i < 10; i++ {
+			noerr(t, db.Upsert(ctx, &csvTestStruct{Id: i + 1, Data: make([]byte, 8192)}, false))
+		}
+		cancelCtx, cancel := context.WithCancel(ctx)
+		writer := writerFunc(func(b []byte) (int, error) {
+			cancel()
+			return len(b), nil
+		})
+		rows, err := ExportCSV(cancelCtx, db, csvTestStruct{}, writer, CSVOptions{})
+		yeserr(t, err)
+		if rows >= 10 {
+			t.Errorf("got %v rows written after cancelling, wanted fewer than 10", rows)
+		}
+	})
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) {
+	return f(b)
+}
+
+func TestImportCSV(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	nickname := "bob"
+	empty := ""
+	for _, opts := range []CSVOptions{{}, {Null: "NULL", RFC3339Times: true, BatchSize: 2}} {
+		buf := &bytes.Buffer{}
+		withDB(t, func(db *DB) {
+			noerr(t, db.CreateTableIfNotExists(ctx, csvTestStruct{}))
+			noerr(t, db.Upsert(ctx, &csvTestStruct{Id: 1, Name: "a, \"b\"\nc", Score: 1.5, Active: true, Data: []byte{0, 1, 2}, Created: ToSQLTime(created), Updated: ToSQLTimeText(created), Nickname: &nickname}, false))
+			noerr(t, db.Upsert(ctx, &csvTestStruct{Id: 2, Score: -2e-10, Nickname: &empty}, false))
+			noerr(t, db.Upsert(ctx, &csvTestStruct{Id: 3}, false))
+			_, err := ExportCSV(ctx, db, csvTestStruct{}, buf, opts)
+			noerr(t, err)
+		})
+		withDB(t, func(db *DB) {
+			noerr(t, db.CreateTableIfNotExists(ctx, csvTestStruct{}))
+			inserted, err := ImportCSV[csvTestStruct](ctx, db, bytes.NewReader(buf.Bytes()), opts)
+			noerr(t, err)
+			if inserted != 3 {
+				t.Errorf("got %v rows inserted, wanted 3", inserted)
+			}
+			got, err := SelectSQL[csvTestStruct](ctx, db, "SELECT * FROM csvTestStruct ORDER BY Id")
+			noerr(t, err)
+			want := []csvTestStruct{
+				{Id: 1, Name: "a, \"b\"\nc", Score: 1.5, Active: true, Data: []byte{0, 1, 2}, Created: ToSQLTime(created), Updated: ToSQLTimeText(created), Nickname: &nickname},
+				{Id: 2, Score: -2e-10},
+				{Id: 3},
+			}
+			if opts.Null != "" {
+				want[1].Nickname = &empty
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, wanted %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestImportCSVContentHash(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, rawHashTestStruct{}))
+		imported := "Id,Name\n1,a\n2,b\n3,a\n"
+		inserted, err := ImportCSV[rawHashTestStruct](ctx, db, strings.NewReader(imported), CSVOptions{BatchSize: 2})
+		noerr(t, err)
+		if inserted != 2 {
+			t.Errorf("got %v rows inserted, wanted the duplicate content to be skipped", inserted)
+		}
+		inserted, err = ImportCSV[rawHashTestStruct](ctx, db, strings.NewReader(imported), CSVOptions{BatchSize: 2})
+		noerr(t, err)
+		if inserted != 0 {
+			t.Errorf("got %v rows inserted, wanted the re-import to be skipped", inserted)
+		}
+		if count := countRows(t, db, "rawHashTestStruct"); count != 2 {
+			t.Errorf("got %v rows, wanted 2", count)
+		}
+	})
+}
+
+func TestImportCSVErrors(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, createdEvent{}))
+		inserted, err := ImportCSV[createdEvent](ctx, db, strings.NewReader("Name,Extra\na,1\nb,2\n"), CSVOptions{SkipUnknownColumns: true})
+		noerr(t, err)
+		if inserted != 2 {
+			t.Errorf("got %v rows inserted, wanted 2", inserted)
+		}
+		got, err := SelectSQL[createdEvent](ctx, db, "SELECT * FROM createdEvent ORDER BY Id")
+		noerr(t, err)
+		if !reflect.DeepEqual(got, []createdEvent{{Id: 1, Name: "a"}, {Id: 2, Name: "b"}}) {
+			t.Errorf("got %+v, wanted generated ids", got)
+		}
+
+		_, err = ImportCSV[createdEvent](ctx, db, strings.NewReader("Name,Extra\nc,1\n"), CSVOptions{})
+		yeserr(t, err)
+		_, err = ImportCSV[createdEvent](ctx, db, strings.NewReader(""), CSVOptions{})
+		yeserr(t, err)
+
+		inserted, err = ImportCSV[createdEvent](ctx, db, strings.NewReader("Id,Name\n10,c\n11,d\n12,e\nx,f\n"), CSVOptions{BatchSize: 2})
+		if err == nil || !strings.Contains(err.Error(), `line 5, column "Id"`) {
+			t.Errorf("got %v, wanted an error on line 5", err)
+		}
+		if inserted != 2 {
+			t.Errorf("got %v rows inserted before the malformed batch, wanted 2", inserted)
+		}
+		_, err = ImportCSV[createdEvent](ctx, db, strings.NewReader("Id,Name\n1,duplicate\n"), CSVOptions{})
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("got %v, wanted an error on line 2", err)
+		}
+		_, err = ImportCSV[createdEvent](ctx, db, strings.NewReader("Id,Name\n13,g,extra\n"), CSVOptions{})
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("got %v, wanted an error on line 2", err)
+		}
+		if count, err := GetSQL[int](ctx, db, "SELECT COUNT(*) FROM createdEvent"); err != nil || count != 4 {
+			t.Errorf("got %v, %v, wanted 4 rows", count, err)
+		}
+	})
+}
@@ -0,0 +1,325 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type driverNamer interface {
+	DriverName() string
+}
+
+func isPostgresDriver(driverName string) bool {
+	return driverName == "postgres" || driverName == "pgx"
+}
+
+func driverNameOf(x any) string {
+	if namer, ok := x.(driverNamer); ok {
+		return namer.DriverName()
+	}
+	return ""
+}
+
+func (meta *tableMeta) pkeyColumnSQL() string {
+	pkeyAutoInc := ""
+	if meta.pkey.autoinc {
+		pkeyAutoInc = " AUTOINCREMENT"
+	}
+	return fmt.Sprintf("`%s` %s%s PRIMARY KEY%s", meta.pkey.col, meta.pkey.sqlType, meta.pkey.collateSQL(), pkeyAutoInc)
+}
+
+// collateSQL returns the COLLATE clause of the field, which SQLite also uses for indices on the column unless they override it.
+func (field *fieldMeta) collateSQL() string {
+	if field.collate == "" {
+		return ""
+	}
+	return fmt.Sprintf(" COLLATE %s", field.collate)
+}
+
+func (field *fieldMeta) columnSQL() string {
+	return fmt.Sprintf("`%s` %s%s", field.col, field.sqlType, field.collateSQL())
+}
+
+// pkeyDefs returns the definitions of the pkey columns, followed by a PRIMARY KEY constraint if the pkey is composite.
+func (meta *tableMeta) pkeyDefs() ([]string, string) {
+	if len(meta.pkeys) < 2 {
+		return []string{meta.pkeyColumnSQL()}, ""
+	}
+	defs := []string{}
+	cols := []string{}
+	for _, field := range meta.pkeys {
+		defs = append(defs, field.columnSQL())
+		cols = append(cols, fmt.Sprintf("`%s`", field.col))
+	}
+	return defs, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(cols, ","))
+}
+
+// tableOptionsSQL returns the STRICT and WITHOUT ROWID options of the table, which can only be set when it is created.
+func (meta *tableMeta) tableOptionsSQL() string {
+	options := []string{}
+	if meta.strict {
+		options = append(options, "STRICT")
+	}
+	if meta.withoutRowID {
+		options = append(options, "WITHOUT ROWID")
+	}
+	if len(options) == 0 {
+		return ""
+	}
+	return " " + strings.Join(options, ", ")
+}
+
+func (meta *tableMeta) createSkeletonSQL() string {
+	defs, constraint := meta.pkeyDefs()
+	if constraint != "" {
+		defs = append(defs, constraint)
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)%s", quoteTable(meta.table), strings.Join(defs, ", "), meta.tableOptionsSQL())
+}
+
+// createTableSQL returns a CREATE TABLE statement with all columns, the pkey column first like in the ALTER TABLE based path.
+func (meta *tableMeta) createTableSQL() string {
+	defs, constraint := meta.pkeyDefs()
+	for _, field := range meta.fields {
+		if !field.pkey {
+			defs = append(defs, field.columnSQL())
+		}
+	}
+	if constraint != "" {
+		defs = append(defs, constraint)
+	}
+	for _, check := range meta.checks {
+		defs = append(defs, checkSQL(check))
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)%s", quoteTable(meta.table), strings.Join(defs, ", "), meta.tableOptionsSQL())
+}
+
+func checkSQL(check string) string {
+	return fmt.Sprintf("CHECK (%s)", check)
+}
+
+// quoteFieldNames returns expr with the identifiers outside of string literals and quoted identifiers that name a field, or the column of a field,
+// of meta replaced by the quoted column.
+func (meta *tableMeta) quoteFieldNames(expr string) string {
+	cols := map[string]string{}
+	for _, field := range meta.fields {
+		cols[field.col] = field.col
+		cols[field.name] = field.col
+	}
+	result := &strings.Builder{}
+	for index := 0; index < len(expr); {
+		switch c := expr[index]; {
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			end := c
+			if c == '[' {
+				end = ']'
+			}
+			stop := strings.IndexByte(expr[index+1:], end)
+			if stop == -1 {
+				result.WriteString(expr[index:])
+				return result.String()
+			}
+			result.WriteString(expr[index : index+stop+2])
+			index += stop + 2
+		case c == '_' || unicode.IsLetter(rune(c)):
+			stop := index + 1
+			for stop < len(expr) && (expr[stop] == '_' || unicode.IsLetter(rune(expr[stop])) || unicode.IsDigit(rune(expr[stop]))) {
+				stop++
+			}
+			if col, found := cols[expr[index:stop]]; found {
+				fmt.Fprintf(result, "`%s`", col)
+			} else {
+				result.WriteString(expr[index:stop])
+			}
+			index = stop
+		case unicode.IsDigit(rune(c)):
+			// Skip numbers, so that the exponent of 1e5 isn't taken for an identifier.
+			stop := index + 1
+			for stop < len(expr) && (expr[stop] == '.' || unicode.IsLetter(rune(expr[stop])) || unicode.IsDigit(rune(expr[stop]))) {
+				stop++
+			}
+			result.WriteString(expr[index:stop])
+			index = stop
+		default:
+			result.WriteByte(c)
+			index++
+		}
+	}
+	return result.String()
+}
+
+// missingChecks returns the table checks of meta that the existing table doesn't have, which is all of them if it doesn't exist.
+// Only SQLite keeps the CREATE TABLE statement around, so other drivers are assumed to have all checks.
+func (meta *tableMeta) missingChecks(ctx context.Context, queryer sqlx.QueryerContext, driverName string) ([]string, error) {
+	if len(meta.checks) == 0 || !isSQLiteDriver(driverName) {
+		return nil, nil
+	}
+	schema, name := splitTable(meta.table)
+	stmts := []string{}
+	if err := sqlx.SelectContext(ctx, queryer, &stmts, fmt.Sprintf("SELECT sql FROM %s WHERE type = 'table' AND name = ?", sqliteMaster(schema)), name); err != nil {
+		return nil, withStack(err)
+	}
+	missing := []string{}
+	for _, check := range meta.checks {
+		if len(stmts) == 0 || !strings.Contains(stmts[0], checkSQL(check)) {
+			missing = append(missing, check)
+		}
+	}
+	return missing, nil
+}
+
+// splitTable returns the schema and the name of table, which is qualified like "archive.Events" if it's in an attached database.
+func splitTable(table string) (string, string) {
+	if schema, name, found := strings.Cut(table, "."); found {
+		return schema, name
+	}
+	return "", table
+}
+
+// quoteQualified returns name quoted for SQL, qualified by schema unless it's empty, like `archive`.`Events`.
+func quoteQualified(schema string, name string) string {
+	if schema == "" {
+		return fmt.Sprintf("`%s`", name)
+	}
+	return fmt.Sprintf("`%s`.`%s`", schema, name)
+}
+
+// quoteTable returns table quoted for SQL, with the schema and name of a qualified table quoted separately.
+func quoteTable(table string) string {
+	return quoteQualified(splitTable(table))
+}
+
+// extraDDL returns the statements of meta's ExtraDDLer for the driver named dialect, if any.
+func (meta *tableMeta) extraDDL(dialect string) []string {
+	if extraDDLer, ok := reflect.New(meta.typ).Interface().(ExtraDDLer); ok {
+		return extraDDLer.SQLYExtraDDL(dialect)
+	}
+	return nil
+}
+
+func (meta *tableMeta) createTriggerSQL(trigger Trigger) string {
+	body := strings.TrimSpace(trigger.Body)
+	if !strings.HasSuffix(body, ";") {
+		body += ";"
+	}
+	when := ""
+	if trigger.When != "" {
+		when = fmt.Sprintf(" WHEN %s", trigger.When)
+	}
+	schema, table := splitTable(meta.table)
+	return fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s %s %s ON `%s` FOR EACH ROW%s BEGIN %s END", quoteQualified(schema, table+"."+trigger.Name), trigger.Timing, trigger.Event, table, when, body)
+}
+
+func (meta *tableMeta) addColumnSQL(field *fieldMeta) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", quoteTable(meta.table), field.columnSQL())
+}
+
+// sqliteMaster returns the sqlite_master table of schema, or of the main database if schema is empty.
+func sqliteMaster(schema string) string {
+	if schema == "" {
+		return "sqlite_master"
+	}
+	return quoteQualified(schema, "sqlite_master")
+}
+
+// existingColumns returns the declared types of the existing columns of table, which is empty if the table doesn't exist.
+func existingColumns(ctx context.Context, queryer sqlx.QueryerContext, driverName string, table string) (map[string]string, error) {
+	schema, name := splitTable(table)
+	query := "SELECT column_name, data_type FROM information_schema.columns WHERE table_name = ?"
+	args := []any{name}
+	switch {
+	case isSQLiteDriver(driverName) && schema != "":
+		query = "SELECT name, type FROM pragma_table_info(?, ?)"
+		args = append(args, schema)
+	case isSQLiteDriver(driverName):
+		query = "SELECT name, type FROM pragma_table_info(?)"
+	case schema != "":
+		query += " AND table_schema = ?"
+		args = append(args, schema)
+	}
+	rows, err := queryer.QueryxContext(ctx, sqlx.Rebind(sqlx.BindType(driverName), query), args...)
+	if err != nil {
+		return nil, withStack(err)
+	}
+	defer rows.Close()
+	result := map[string]string{}
+	for rows.Next() {
+		name, typ := "", ""
+		if err := rows.Scan(&name, &typ); err != nil {
+			return nil, withStack(err)
+		}
+		result[name] = typ
+	}
+	return result, withStack(rows.Err())
+}
+
+// existingIndices returns the names of the explicitly created indices of table, qualified by the schema of table if it has one.
+func existingIndices(ctx context.Context, queryer sqlx.QueryerContext, driverName string, table string) ([]string, error) {
+	schema, name := splitTable(table)
+	query := "SELECT DISTINCT index_name FROM information_schema.statistics WHERE table_name = ?"
+	schemaCondition := " AND table_schema = ?"
+	switch {
+	case isSQLiteDriver(driverName):
+		query = fmt.Sprintf("SELECT name FROM %s WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", sqliteMaster(schema))
+		schemaCondition = ""
+	case isPostgresDriver(driverName):
+		query = "SELECT indexname FROM pg_indexes WHERE tablename = ?"
+		schemaCondition = " AND schemaname = ?"
+	}
+	args := []any{name}
+	if schema != "" && schemaCondition != "" {
+		query += schemaCondition
+		args = append(args, schema)
+	}
+	result := []string{}
+	if err := sqlx.SelectContext(ctx, queryer, &result, sqlx.Rebind(sqlx.BindType(driverName), query+" ORDER BY 1"), args...); err != nil {
+		return nil, withStack(err)
+	}
+	if schema != "" {
+		for index := range result {
+			result[index] = schema + "." + result[index]
+		}
+	}
+	return result, nil
+}
+
+// existingTriggers returns the names of the triggers of table, which are only known for SQLite.
+func existingTriggers(ctx context.Context, queryer sqlx.QueryerContext, driverName string, table string) (map[string]bool, error) {
+	result := map[string]bool{}
+	if !isSQLiteDriver(driverName) {
+		return result, nil
+	}
+	schema, name := splitTable(table)
+	names := []string{}
+	if err := sqlx.SelectContext(ctx, queryer, &names, fmt.Sprintf("SELECT name FROM %s WHERE type = 'trigger' AND tbl_name = ?", sqliteMaster(schema)), name); err != nil {
+		return nil, withStack(err)
+	}
+	for _, name := range names {
+		result[name] = true
+	}
+	return result, nil
+}
+
+// tableExists returns whether table exists.
+func tableExists(ctx context.Context, queryer sqlx.QueryerContext, driverName string, table string) (bool, error) {
+	schema, name := splitTable(table)
+	query := "SELECT COUNT(*) FROM information_schema.tables WHERE table_name = ?"
+	args := []any{name}
+	switch {
+	case isSQLiteDriver(driverName):
+		query = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE type = 'table' AND name = ?", sqliteMaster(schema))
+	case schema != "":
+		query += " AND table_schema = ?"
+		args = append(args, schema)
+	}
+	count := 0
+	if err := getContext(ctx, queryer, &count, sqlx.Rebind(sqlx.BindType(driverName), query), args...); err != nil {
+		return false, withStack(err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,277 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type Querier = sqlx.QueryerContext
+
+type DiffColumn struct {
+	Table  string
+	Column string
+	Type   string
+
+	statement string
+}
+
+type DiffTypeMismatch struct {
+	Table    string
+	Column   string
+	Declared string
+	Existing string
+}
+
+type DiffIndex struct {
+	Table string
+	Name  string
+
+	statements []string
+}
+
+// DiffDDL is a statement declared by an ExtraDDLer.
+type DiffDDL struct {
+	Table     string
+	Statement string
+}
+
+// DiffCheck is a table check declared by a TableChecker that the existing table lacks.
+type DiffCheck struct {
+	Table string
+	Check string
+}
+
+type DiffTable struct {
+	Table string
+
+	statements []string
+}
+
+// Diff describes the differences between a set of structs and the tables of a live database.
+// Entries are ordered by the order of the prototypes, and then by field declaration or name order within each table.
+// ExtraDDL lists the statements of prototypes implementing ExtraDDLer, which can't be compared with the database,
+// so they're always listed and rendered, but don't make the diff non empty.
+type Diff struct {
+	MissingTables  []DiffTable
+	MissingColumns []DiffColumn
+	ExtraColumns   []DiffColumn
+	TypeMismatches []DiffTypeMismatch
+	MissingIndices []DiffIndex
+	ExtraIndices   []DiffIndex
+	MissingChecks  []DiffCheck
+	ExtraDDL       []DiffDDL
+}
+
+func (d Diff) Empty() bool {
+	return len(d.MissingTables) == 0 && len(d.MissingColumns) == 0 && len(d.ExtraColumns) == 0 && len(d.TypeMismatches) == 0 && len(d.MissingIndices) == 0 && len(d.ExtraIndices) == 0 && len(d.MissingChecks) == 0
+}
+
+// Statements renders the additive part of the diff, missing tables, columns and indices followed by the extra DDL, as executable SQL.
+// Extra columns, extra indices, missing checks and type mismatches require manual migration and aren't rendered.
+func (d Diff) Statements(dialect string) ([]string, error) {
+	if !isSQLiteDriver(dialect) {
+		return nil, errors.Errorf("dialect %q is not supported", dialect)
+	}
+	result := []string{}
+	for _, table := range d.MissingTables {
+		result = append(result, table.statements...)
+	}
+	for _, column := range d.MissingColumns {
+		result = append(result, column.statement)
+	}
+	for _, index := range d.MissingIndices {
+		result = append(result, index.statements...)
+	}
+	for _, ddl := range d.ExtraDDL {
+		result = append(result, ddl.Statement)
+	}
+	return result, nil
+}
+
+// SchemaDiff compares the tables the prototypes would create with the tables in the database q is connected to, without changing anything.
+func SchemaDiff(ctx context.Context, q Querier, prototypes ...any) (Diff, error) {
+	diff := Diff{}
+	driverName := driverNameOf(q)
+	for _, prototype := range prototypes {
+		meta, err := metaOf(q, prototype)
+		if err != nil {
+			return Diff{}, err
+		}
+		if err := meta.requirePrimaryKey(); err != nil {
+			return Diff{}, err
+		}
+		for _, stmt := range meta.extraDDL(driverName) {
+			diff.ExtraDDL = append(diff.ExtraDDL, DiffDDL{Table: meta.table, Statement: stmt})
+		}
+		existing, err := existingColumns(ctx, q, driverName, meta.table)
+		if err != nil {
+			return Diff{}, err
+		}
+		if len(existing) == 0 {
+			table := DiffTable{Table: meta.table, statements: []string{meta.createTableSQL()}}
+			for _, index := range meta.indices {
+				table.statements = append(table.statements, index.createSQL())
+			}
+			diff.MissingTables = append(diff.MissingTables, table)
+			continue
+		}
+		missingChecks, err := meta.missingChecks(ctx, q, driverName)
+		if err != nil {
+			return Diff{}, err
+		}
+		for _, check := range missingChecks {
+			diff.MissingChecks = append(diff.MissingChecks, DiffCheck{Table: meta.table, Check: check})
+		}
+		declared := map[string]bool{}
+		for _, field := range meta.fields {
+			declared[field.col] = true
+			existingType, found := existing[field.col]
+			if !found {
+				diff.MissingColumns = append(diff.MissingColumns, DiffColumn{
+					Table:     meta.table,
+					Column:    field.col,
+					Type:      field.sqlType,
+					statement: meta.addColumnSQL(field),
+				})
+			} else if !strings.EqualFold(existingType, field.sqlType) {
+				diff.TypeMismatches = append(diff.TypeMismatches, DiffTypeMismatch{
+					Table:    meta.table,
+					Column:   field.col,
+					Declared: field.sqlType,
+					Existing: existingType,
+				})
+			}
+		}
+		extraColumns := []string{}
+		for col := range existing {
+			if !declared[col] {
+				extraColumns = append(extraColumns, col)
+			}
+		}
+		sort.Strings(extraColumns)
+		for _, col := range extraColumns {
+			diff.ExtraColumns = append(diff.ExtraColumns, DiffColumn{
+				Table:  meta.table,
+				Column: col,
+				Type:   existing[col],
+			})
+		}
+		existingIndexNames, err := existingIndices(ctx, q, driverName, meta.table)
+		if err != nil {
+			return Diff{}, err
+		}
+		existingIndexSet := map[string]bool{}
+		for _, name := range existingIndexNames {
+			existingIndexSet[name] = true
+		}
+		declaredIndices := map[string]bool{}
+		for _, index := range meta.indices {
+			name := index.IndexName()
+			declaredIndices[name] = true
+			if !existingIndexSet[name] {
+				diff.MissingIndices = append(diff.MissingIndices, DiffIndex{
+					Table:      meta.table,
+					Name:       name,
+					statements: []string{index.createSQL()},
+				})
+			}
+		}
+		for _, name := range existingIndexNames {
+			if metasFor(q).naming.OwnsIndex(meta.table, name) && !declaredIndices[name] {
+				diff.ExtraIndices = append(diff.ExtraIndices, DiffIndex{
+					Table: meta.table,
+					Name:  name,
+				})
+			}
+		}
+	}
+	return diff, nil
+}
+
+// ValidateSchema compares the tables the prototypes would create with the database q is connected to, like SchemaDiff, and returns an error,
+// a *MultiError if there are several, describing every missing table, missing or extra column, type mismatch, missing or extra index and
+// missing table check, or nil if the schema matches.
+// The database is never changed, and if q is a *DB it's inspected in a Read transaction.
+func ValidateSchema(ctx context.Context, q Querier, prototypes ...any) error {
+	diff := Diff{}
+	if err := readIn(ctx, q, func(q Querier) error {
+		var err error
+		diff, err = SchemaDiff(labeled(ctx, q, "validateSchema", ""), q, prototypes...)
+		return err
+	}); err != nil {
+		return err
+	}
+	problems := []error{}
+	for _, table := range diff.MissingTables {
+		problems = append(problems, errors.Errorf("table %q is missing", table.Table))
+	}
+	for _, column := range diff.MissingColumns {
+		problems = append(problems, errors.Errorf("col %q of %q is missing", column.Column, column.Table))
+	}
+	for _, column := range diff.ExtraColumns {
+		problems = append(problems, errors.Errorf("col %q of %q isn't declared", column.Column, column.Table))
+	}
+	for _, mismatch := range diff.TypeMismatches {
+		problems = append(problems, errors.Errorf("col %q of %q is %s, but declared %s", mismatch.Column, mismatch.Table, mismatch.Existing, mismatch.Declared))
+	}
+	for _, index := range diff.MissingIndices {
+		problems = append(problems, errors.Errorf("index %q of %q is missing", index.Name, index.Table))
+	}
+	for _, index := range diff.ExtraIndices {
+		problems = append(problems, errors.Errorf("index %q of %q isn't declared", index.Name, index.Table))
+	}
+	for _, check := range diff.MissingChecks {
+		problems = append(problems, errors.Errorf("table check %q of %q is missing", check.Check, check.Table))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return newMultiError(problems)
+}
+
+func (db *DB) ValidateSchema(ctx context.Context, prototypes ...any) error {
+	return ValidateSchema(ctx, db, prototypes...)
+}
+
+// DumpSchema returns the schema of the database as SQLite sees it, the SQL of all tables, indices, triggers and views, each terminated by ";\n".
+func (db *DB) DumpSchema(ctx context.Context) (string, error) {
+	if !isSQLiteDriver(db.DriverName()) {
+		return "", errors.Errorf("dumping the schema isn't supported for driver %q", db.DriverName())
+	}
+	result := ""
+	err := db.Read(ctx, func(tx *Tx) error {
+		var err error
+		result, err = dumpSchema(ctx, tx)
+		return err
+	})
+	return result, err
+}
+
+// DumpSchema works like DB.DumpSchema, but includes the uncommitted changes of the transaction.
+func (tx *Tx) DumpSchema(ctx context.Context) (string, error) {
+	if !isSQLiteDriver(tx.DriverName()) {
+		return "", errors.Errorf("dumping the schema isn't supported for driver %q", tx.DriverName())
+	}
+	result, err := dumpSchema(ctx, tx)
+	return result, tx.db.mapError(err)
+}
+
+func dumpSchema(ctx context.Context, tx *Tx) (string, error) {
+	statements := []string{}
+	if err := sqlx.SelectContext(labeled(ctx, tx, "dumpSchema", ""), tx, &statements, `
+SELECT sql FROM sqlite_master
+WHERE sql IS NOT NULL
+ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 WHEN 'trigger' THEN 2 ELSE 3 END, tbl_name, name`); err != nil {
+		return "", withStack(err)
+	}
+	result := &strings.Builder{}
+	for _, statement := range statements {
+		fmt.Fprintf(result, "%s;\n", statement)
+	}
+	return result.String(), nil
+}
@@ -0,0 +1,122 @@
+package sqly
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestSchemaDiff(t *testing.T) {
+	withDB(t, func(db *DB) {
+		_, err := db.Exec("CREATE TABLE indexedTestStruct (`Id` INTEGER PRIMARY KEY, `Indexed` TEXT, `Unique` INTEGER, `Old` INTEGER, `ThreeIndexed1` INTEGER, `ThreeIndexed2` INTEGER, `ThreeIndexed3` INTEGER, `ThreeUnique1` INTEGER, `ThreeUnique2` INTEGER)")
+		noerr(t, err)
+		_, err = db.Exec("CREATE INDEX `indexedTestStruct.Indexed` ON indexedTestStruct (`Indexed`)")
+		noerr(t, err)
+		_, err = db.Exec("CREATE INDEX `indexedTestStruct.Old` ON indexedTestStruct (`Old`)")
+		noerr(t, err)
+		_, err = db.Exec("CREATE INDEX `custom` ON indexedTestStruct (`Old`)")
+		noerr(t, err)
+		diff, err := SchemaDiff(ctx, db, indexedTestStruct{}, sharedTestStruct{})
+		noerr(t, err)
+		want := Diff{
+			MissingTables: []DiffTable{
+				{Table: "sharedTestStruct", statements: []string{"CREATE TABLE IF NOT EXISTS `sharedTestStruct` (`Id` INTEGER PRIMARY KEY, `Name` TEXT)"}},
+			},
+			MissingColumns: []DiffColumn{
+				{Table: "indexedTestStruct", Column: "ThreeUnique3", Type: "INTEGER", statement: "ALTER TABLE `indexedTestStruct` ADD COLUMN `ThreeUnique3` INTEGER"},
+			},
+			ExtraColumns: []DiffColumn{
+				{Table: "indexedTestStruct", Column: "Old", Type: "INTEGER"},
+			},
+			TypeMismatches: []DiffTypeMismatch{
+				{Table: "indexedTestStruct", Column: "Indexed", Declared: "INTEGER", Existing: "TEXT"},
+			},
+			MissingIndices: []DiffIndex{
+				{Table: "indexedTestStruct", Name: "indexedTestStruct.Unique", statements: []string{"CREATE UNIQUE INDEX IF NOT EXISTS `indexedTestStruct.Unique` ON `indexedTestStruct` (`Unique`)"}},
+				{Table: "indexedTestStruct", Name: "indexedTestStruct.ThreeIndexed3,ThreeIndexed1,ThreeIndexed2", statements: []string{"CREATE INDEX IF NOT EXISTS `indexedTestStruct.ThreeIndexed3,ThreeIndexed1,ThreeIndexed2` ON `indexedTestStruct` (`ThreeIndexed3`,`ThreeIndexed1`,`ThreeIndexed2`)"}},
+				{Table: "indexedTestStruct", Name: "indexedTestStruct.ThreeUnique3,ThreeUnique1,ThreeUnique2", statements: []string{"CREATE UNIQUE INDEX IF NOT EXISTS `indexedTestStruct.ThreeUnique3,ThreeUnique1,ThreeUnique2` ON `indexedTestStruct` (`ThreeUnique3`,`ThreeUnique1`,`ThreeUnique2`)"}},
+			},
+			ExtraIndices: []DiffIndex{
+				{Table: "indexedTestStruct", Name: "indexedTestStruct.Old"},
+			},
+		}
+		if !reflect.DeepEqual(diff, want) {
+			t.Errorf("got\n%+v\nwanted\n%+v", diff, want)
+		}
+		statements, err := diff.Statements("sqlite")
+		noerr(t, err)
+		for _, statement := range statements {
+			_, err := db.Exec(statement)
+			noerr(t, err)
+		}
+		diff, err = SchemaDiff(ctx, db, indexedTestStruct{}, sharedTestStruct{})
+		noerr(t, err)
+		if len(diff.MissingTables) != 0 || len(diff.MissingColumns) != 0 || len(diff.MissingIndices) != 0 {
+			t.Errorf("got %+v, wanted only the non additive differences left", diff)
+		}
+		if diff.Empty() {
+			t.Errorf("got empty diff, wanted the extra column, extra index and type mismatch left")
+		}
+		_, err = diff.Statements("oracle")
+		yeserr(t, err)
+	})
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, indexedTestStruct{}))
+		diff, err := SchemaDiff(ctx, db, indexedTestStruct{})
+		noerr(t, err)
+		if !diff.Empty() {
+			t.Errorf("got %+v, wanted empty diff", diff)
+		}
+	})
+}
+
+func TestValidateSchema(t *testing.T) {
+	withDB(t, func(db *DB) {
+		_, err := db.Exec("CREATE TABLE indexedTestStruct (`Id` INTEGER PRIMARY KEY, `Indexed` TEXT, `Unique` INTEGER, `Old` INTEGER, `ThreeIndexed1` INTEGER, `ThreeIndexed2` INTEGER, `ThreeIndexed3` INTEGER, `ThreeUnique1` INTEGER, `ThreeUnique2` INTEGER)")
+		noerr(t, err)
+		err = db.ValidateSchema(ctx, indexedTestStruct{}, sharedTestStruct{})
+		multi := &MultiError{}
+		if !errors.As(err, &multi) {
+			t.Fatalf("got %v, wanted a MultiError", err)
+		}
+		for _, want := range []string{
+			`table "sharedTestStruct" is missing`,
+			`col "ThreeUnique3" of "indexedTestStruct" is missing`,
+			`col "Old" of "indexedTestStruct" isn't declared`,
+			`col "Indexed" of "indexedTestStruct" is TEXT, but declared INTEGER`,
+			`index "indexedTestStruct.Unique" of "indexedTestStruct" is missing`,
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("got %v, wanted it to contain %q", err, want)
+			}
+		}
+		tables := 0
+		noerr(t, db.Get(&tables, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'"))
+		if tables != 1 {
+			t.Errorf("got %v tables, wanted the validation to not change anything", tables)
+		}
+	})
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, indexedTestStruct{}))
+		noerr(t, ValidateSchema(ctx, db, indexedTestStruct{}))
+	})
+}
+
+func TestDumpSchema(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		noerr(t, db.EnsureIndex(ctx, IndexSpec{Table: "sharedTestStruct", Columns: []string{"Name"}}))
+		_, err := db.Exec("CREATE TRIGGER sharedTestStructTrigger AFTER INSERT ON sharedTestStruct BEGIN SELECT 1; END")
+		noerr(t, err)
+		schema, err := db.DumpSchema(ctx)
+		noerr(t, err)
+		want := "CREATE TABLE `sharedTestStruct` (`Id` INTEGER PRIMARY KEY, `Name` TEXT);\n" +
+			"CREATE INDEX `sharedTestStruct.Name` ON `sharedTestStruct` (`Name`);\n" +
+			"CREATE TRIGGER sharedTestStructTrigger AFTER INSERT ON sharedTestStruct BEGIN SELECT 1; END;\n"
+		if schema != want {
+			t.Errorf("got %q, wanted %q", schema, want)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package sqly
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const (
+	sqliteNotADB = 26
+)
+
+// WithEncryptionKey makes every connection run PRAGMA key with key before anything else, for SQLite drivers built with SQLCipher or another
+// encryption extension, which then decrypt the database with it. Drivers without encryption support ignore the pragma.
+// Open verifies the key by reading the schema, and returns an error matching ErrBadEncryptionKey if the database can't be read with it.
+func WithEncryptionKey(key string) Option {
+	return func(db *DB) error {
+		if !isSQLiteDriver(db.DriverName()) {
+			return errors.Errorf("encryption isn't supported for driver %q", db.DriverName())
+		}
+		if key == "" {
+			return errors.Errorf("encryption key is empty")
+		}
+		db.encryptionKey = key
+		return nil
+	}
+}
+
+// WithRekey makes Open change the encryption key of the database from the key of WithEncryptionKey to key, using PRAGMA rekey,
+// and makes the connections opened afterwards use key.
+func WithRekey(key string) Option {
+	return func(db *DB) error {
+		if key == "" {
+			return errors.Errorf("encryption key is empty")
+		}
+		db.rekey = key
+		return nil
+	}
+}
+
+// keyedConnector runs PRAGMA key on every connection it opens.
+type keyedConnector struct {
+	driver.Connector
+
+	lock sync.Mutex
+	key  string
+}
+
+func (k *keyedConnector) setKey(key string) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.key = key
+}
+
+func (k *keyedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := k.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	k.lock.Lock()
+	key := k.key
+	k.lock.Unlock()
+	if err := execConn(ctx, conn, "PRAGMA key = "+quoteString(key)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// dsnConnector is the driver.Connector of drivers that aren't driver.DriverContexts.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (d dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return d.driver.Open(d.dsn)
+}
+
+func (d dsnConnector) Driver() driver.Driver {
+	return d.driver
+}
+
+// execConn runs query on conn, which hasn't been handed to database/sql yet.
+func execConn(ctx context.Context, conn driver.Conn, query string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, query, nil)
+		return withStack(err)
+	}
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return withStack(err)
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(nil)
+	return withStack(err)
+}
+
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// setupEncryption reopens the DB using a keyedConnector, and verifies the key, and rekeys the database if WithRekey was used.
+func (db *DB) setupEncryption(ctx context.Context, dataSourceName string) error {
+	if db.encryptionKey == "" {
+		if db.rekey != "" {
+			return errors.Errorf("WithRekey requires WithEncryptionKey")
+		}
+		return nil
+	}
+	var connector driver.Connector = dsnConnector{dsn: dataSourceName, driver: db.Driver()}
+	if driverContext, ok := db.Driver().(driver.DriverContext); ok {
+		var err error
+		if connector, err = driverContext.OpenConnector(dataSourceName); err != nil {
+			return withStack(err)
+		}
+	}
+	keyed := &keyedConnector{Connector: connector, key: db.encryptionKey}
+	unkeyed := db.DB
+	db.DB = *sqlx.NewDb(sql.OpenDB(keyed), unkeyed.DriverName())
+	if err := unkeyed.Close(); err != nil {
+		return withStack(err)
+	}
+	conn, err := db.DB.Conn(ctx)
+	if err != nil {
+		return badEncryptionKey(err)
+	}
+	defer conn.Close()
+	tables := 0
+	if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master").Scan(&tables); err != nil {
+		return badEncryptionKey(err)
+	}
+	if db.rekey != "" {
+		if _, err := conn.ExecContext(ctx, "PRAGMA rekey = "+quoteString(db.rekey)); err != nil {
+			return withStack(err)
+		}
+		keyed.setKey(db.rekey)
+	}
+	return nil
+}
+
+// badEncryptionKey makes err match ErrBadEncryptionKey if it's caused by the database not being readable with the key.
+func badEncryptionKey(err error) error {
+	var coder sqliteCoder
+	if (errors.As(err, &coder) && coder.Code()&0xff == sqliteNotADB) || strings.Contains(err.Error(), "file is not a database") {
+		return &classifiedError{error: withStack(err), sentinel: ErrBadEncryptionKey}
+	}
+	return withStack(err)
+}
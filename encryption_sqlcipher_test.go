@@ -0,0 +1,38 @@
+//go:build sqlcipher
+
+package sqly
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mutecomm/go-sqlcipher/v4"
+)
+
+// TestSQLCipher needs a SQLCipher driver registered as sqlite3, and runs with `go get github.com/mutecomm/go-sqlcipher/v4 && go test -tags sqlcipher`.
+func TestSQLCipher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open("sqlite3", path, WithEncryptionKey("secret"))
+	noerr(t, err)
+	noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+	noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 1}, false))
+	noerr(t, db.Close())
+
+	if _, err := Open("sqlite3", path, WithEncryptionKey("wrong")); !errors.Is(err, ErrBadEncryptionKey) {
+		t.Errorf("got %v for the wrong key, wanted ErrBadEncryptionKey", err)
+	}
+	db, err = Open("sqlite3", path, WithEncryptionKey("secret"), WithRekey("other"))
+	noerr(t, err)
+	noerr(t, db.Close())
+	_, err = Open("sqlite3", path, WithEncryptionKey("secret"))
+	if !errors.Is(err, ErrBadEncryptionKey) {
+		t.Errorf("got %v for the old key, wanted ErrBadEncryptionKey", err)
+	}
+	db, err = Open("sqlite3", path, WithEncryptionKey("other"))
+	noerr(t, err)
+	defer db.Close()
+	if _, err := GetSQL[sharedTestStruct](ctx, db, "SELECT * FROM sharedTestStruct"); err != nil {
+		t.Errorf("got %v, wanted the row", err)
+	}
+}
@@ -0,0 +1,72 @@
+package sqly
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type recordingConn struct {
+	driver.Conn
+	executed *[]string
+}
+
+func (r recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	*r.executed = append(*r.executed, query)
+	return driver.RowsAffected(0), nil
+}
+
+type recordingConnector struct {
+	driver.Connector
+	executed []string
+}
+
+func (r *recordingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return recordingConn{executed: &r.executed}, nil
+}
+
+func TestKeyedConnector(t *testing.T) {
+	recording := &recordingConnector{}
+	keyed := &keyedConnector{Connector: recording, key: "it's secret"}
+	_, err := keyed.Connect(ctx)
+	noerr(t, err)
+	keyed.setKey("new")
+	_, err = keyed.Connect(ctx)
+	noerr(t, err)
+	if want := []string{"PRAGMA key = 'it''s secret'", "PRAGMA key = 'new'"}; !reflect.DeepEqual(recording.executed, want) {
+		t.Errorf("got %q, wanted %q", recording.executed, want)
+	}
+}
+
+func TestEncryptionKey(t *testing.T) {
+	// Without an encryption extension PRAGMA key is ignored, which still exercises the reopening and verification.
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open("sqlite", path, WithEncryptionKey("secret"), WithRekey("other"))
+	noerr(t, err)
+	noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+	noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 1}, false))
+	noerr(t, db.Close())
+	db, err = Open("sqlite", path, WithEncryptionKey("other"))
+	noerr(t, err)
+	if _, err := GetSQL[sharedTestStruct](ctx, db, "SELECT * FROM sharedTestStruct"); err != nil {
+		t.Errorf("got %v, wanted the row", err)
+	}
+	noerr(t, db.Close())
+
+	garbage := filepath.Join(t.TempDir(), "garbage.db")
+	noerr(t, os.WriteFile(garbage, []byte(string(make([]byte, 100))+"this is not a database, maybe it's encrypted"), 0600))
+	_, err = Open("sqlite", garbage, WithEncryptionKey("secret"))
+	if !errors.Is(err, ErrBadEncryptionKey) {
+		t.Errorf("got %v, wanted ErrBadEncryptionKey", err)
+	}
+	_, err = Open("sqlite", path, WithRekey("other"))
+	yeserr(t, err)
+	_, err = Open("sqlite", path, WithEncryptionKey(""))
+	yeserr(t, err)
+	_, err = Open("fakepostgres", path, WithEncryptionKey("secret"))
+	yeserr(t, err)
+}
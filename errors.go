@@ -0,0 +1,117 @@
+package sqly
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrBusy is matched by errors.Is for errors caused by SQLITE_BUSY, or any of its extended result codes.
+	ErrBusy = errors.New("database is busy")
+	// ErrLocked is matched by errors.Is for errors caused by SQLITE_LOCKED, or any of its extended result codes.
+	ErrLocked = errors.New("database table is locked")
+	// ErrClosed is returned by Writes queued by WithWriteQueue when the DB is closed.
+	ErrClosed = errors.New("database is closed")
+	// ErrQueueFull is returned by WriteAsync when the queue configured by WithAsyncWrites is full.
+	ErrQueueFull = errors.New("write queue is full")
+	// ErrBatchAborted is returned by WriteBatched when another callback made a BatchFailAbort batch roll back.
+	ErrBatchAborted = errors.New("write batch was aborted")
+	// ErrLockTimeout is matched by errors.Is when a Read or Write gave up waiting for the lock because its context was done.
+	// The errors also match the error of the context.
+	ErrLockTimeout = errors.New("timed out waiting for the lock")
+	// ErrBadEncryptionKey is matched by errors.Is when Open couldn't read the database with the key of WithEncryptionKey.
+	ErrBadEncryptionKey = errors.New("bad encryption key")
+	// ErrUserVersionMismatch is matched by errors.Is when BumpUserVersion found another user version than the one it was told to bump from.
+	ErrUserVersionMismatch = errors.New("user version mismatch")
+	// ErrZeroPrimaryKey is matched by errors.Is when Refresh is given a struct whose pkey fields are all zero.
+	ErrZeroPrimaryKey = errors.New("zero primary key")
+)
+
+// ErrorMapper translates the errors returned by sqly into domain errors, like a UNIQUE violation into an ErrDuplicate of the application.
+type ErrorMapper func(error) error
+
+// WithErrorMapper makes the DB call mapper with every non nil error, already wrapped with a stack, before returning it from Write, Read,
+// the helper methods of the DB and its Txs, and the query helpers.
+// Since helpers may return errors of other helpers, mapper can be called with errors it already mapped, so it should return errors it doesn't recognize unchanged.
+// Errors caused by a busy or locked database match ErrBusy or ErrLocked, and Writes and Reads that gave up waiting for the lock match ErrLockTimeout,
+// so mapper can recognize them using errors.Is.
+func WithErrorMapper(mapper ErrorMapper) Option {
+	return func(db *DB) error {
+		db.errorMapper = mapper
+		return nil
+	}
+}
+
+func (db *DB) mapError(err error) error {
+	if err == nil || db == nil || db.errorMapper == nil {
+		return err
+	}
+	return db.errorMapper(classify(err))
+}
+
+// mapErrorOf maps err with the ErrorMapper of the DB of x, if any.
+func mapErrorOf(x any, err error) error {
+	switch typed := x.(type) {
+	case *DB:
+		return typed.mapError(err)
+	case *Tx:
+		return typed.db.mapError(err)
+	}
+	return err
+}
+
+type sqliteCoder interface {
+	Code() int
+}
+
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+// ClassifyError returns ErrBusy or ErrLocked if err is caused by a busy or locked database, and nil otherwise.
+// It looks at the numeric result code of the driver error, so it doesn't depend on the error messages of any particular driver version.
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, sentinel := range []error{ErrBusy, ErrLocked} {
+		if errors.Is(err, sentinel) {
+			return sentinel
+		}
+	}
+	var coder sqliteCoder
+	if !errors.As(err, &coder) {
+		return nil
+	}
+	switch coder.Code() & 0xff {
+	case sqliteBusy:
+		return ErrBusy
+	case sqliteLocked:
+		return ErrLocked
+	}
+	return nil
+}
+
+type classifiedError struct {
+	error
+	sentinel error
+}
+
+func (c *classifiedError) Unwrap() error {
+	return c.error
+}
+
+func (c *classifiedError) Is(target error) bool {
+	return target == c.sentinel
+}
+
+func classify(err error) error {
+	if sentinel := ClassifyError(err); sentinel != nil && !errors.Is(err, sentinel) {
+		return &classifiedError{error: err, sentinel: sentinel}
+	}
+	return err
+}
+
+func isSQLiteBusyOrLocked(err error) bool {
+	return ClassifyError(err) != nil
+}
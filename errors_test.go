@@ -0,0 +1,139 @@
+package sqly
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestClassifyError(t *testing.T) {
+	withFileDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		tx1, err := db.Beginy(ctx)
+		noerr(t, err)
+		defer tx1.Rollback()
+		noerr(t, tx1.Upsert(ctx, &sharedTestStruct{Id: 1, Name: "a"}, false))
+		tx2, err := db.Beginy(ctx)
+		noerr(t, err)
+		defer tx2.Rollback()
+		err = tx2.Upsert(ctx, &sharedTestStruct{Id: 2, Name: "b"}, false)
+		if !errors.Is(err, ErrBusy) {
+			t.Errorf("got %v, wanted ErrBusy", err)
+		}
+		if errors.Is(err, ErrLocked) {
+			t.Errorf("got %v, didn't want ErrLocked", err)
+		}
+		if ClassifyError(err) != ErrBusy {
+			t.Errorf("got %v, wanted ErrBusy", ClassifyError(err))
+		}
+		if ClassifyError(errors.Wrap(err, "wrapped")) != ErrBusy {
+			t.Errorf("wrapped error wasn't classified as ErrBusy")
+		}
+	}, WithLocking(false))
+	if ClassifyError(errors.New("database is locked")) != nil {
+		t.Errorf("classified an error without a result code")
+	}
+	if ClassifyError(nil) != nil {
+		t.Errorf("classified nil")
+	}
+}
+
+var errTestDuplicate = errors.New("duplicate")
+
+func TestErrorMapper(t *testing.T) {
+	mapper := func(err error) error {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return errTestDuplicate
+		}
+		return err
+	}
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 1}, false))
+		if err := db.Upsert(ctx, &sharedTestStruct{Id: 1}, false); err != errTestDuplicate {
+			t.Errorf("got %v, wanted the mapped error", err)
+		}
+		err := db.Write(ctx, func(tx *Tx) error {
+			err := tx.Upsert(ctx, &sharedTestStruct{Id: 1}, false)
+			if err != errTestDuplicate {
+				t.Errorf("got %v, wanted the mapped error inside the transaction", err)
+			}
+			return err
+		})
+		if err != errTestDuplicate {
+			t.Errorf("got %v, wanted the mapped error from Write", err)
+		}
+		if err := db.UpsertAll(ctx, []any{&sharedTestStruct{Id: 1}}, false); err != errTestDuplicate {
+			t.Errorf("got %v, wanted the mapped error from UpsertAll", err)
+		}
+		if _, err := GetSQL[sharedTestStruct](ctx, db, "SELECT * FROM sharedTestStruct WHERE Id = 2"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got %v, wanted unrecognized errors unchanged", err)
+		}
+	}, WithErrorMapper(mapper))
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 1}, false))
+		if err := db.Upsert(ctx, &sharedTestStruct{Id: 1}, false); err == nil || err == errTestDuplicate {
+			t.Errorf("got %v, wanted the unmapped error without a mapper", err)
+		}
+	})
+}
+
+var (
+	errTestTimeout = errors.New("timeout")
+	errTestBusy    = errors.New("busy")
+)
+
+func TestErrorMapperLockErrors(t *testing.T) {
+	mapper := func(err error) error {
+		switch {
+		case errors.Is(err, ErrLockTimeout):
+			return errTestTimeout
+		case errors.Is(err, ErrBusy):
+			return errTestBusy
+		}
+		return err
+	}
+	withFileDB(t, func(db *DB) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			done <- db.Write(ctx, func(tx *Tx) error {
+				close(started)
+				<-release
+				return nil
+			})
+		}()
+		<-started
+		for _, do := range []func(context.Context) error{
+			func(ctx context.Context) error { return db.Write(ctx, func(tx *Tx) error { return nil }) },
+			func(ctx context.Context) error { return db.Read(ctx, func(tx *Tx) error { return nil }) },
+			func(ctx context.Context) error { return db.Backup(ctx, filepath.Join(t.TempDir(), "backup.db")) },
+			db.HealthCheck,
+		} {
+			short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			err := do(short)
+			cancel()
+			if err != errTestTimeout {
+				t.Errorf("got %v, wanted the mapped lock timeout", err)
+			}
+		}
+		close(release)
+		noerr(t, <-done)
+	}, WithErrorMapper(mapper))
+	withFileDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		tx, err := db.Beginy(ctx)
+		noerr(t, err)
+		defer tx.Rollback()
+		noerr(t, tx.Upsert(ctx, &sharedTestStruct{Id: 1, Name: "a"}, false))
+		if err := db.Upsert(ctx, &sharedTestStruct{Id: 2, Name: "b"}, false); err != errTestBusy {
+			t.Errorf("got %v, wanted the mapped busy error", err)
+		}
+	}, WithLocking(false), WithErrorMapper(mapper))
+}
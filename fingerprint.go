@@ -0,0 +1,74 @@
+package sqly
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const (
+	fingerprintTable = "sqly_schema"
+)
+
+// WithSchemaFingerprints makes CreateTableIfNotExists store a fingerprint of the DDL of each table in a sqly_schema table,
+// and skip all statements when the stored fingerprint matches the struct, so that an unchanged schema only costs a quick lookup.
+// Changes made to the tables outside of sqly aren't detected.
+func WithSchemaFingerprints() Option {
+	return func(db *DB) error {
+		db.fingerprints = true
+		return nil
+	}
+}
+
+// fingerprint returns a hash of the DDL sqly derives from the struct, which is stable as long as the struct and DB configuration are.
+func (meta *tableMeta) fingerprint(driverName string) string {
+	hash := sha256.New()
+	fmt.Fprintln(hash, meta.createTableSQL())
+	for _, index := range meta.indices {
+		fmt.Fprintln(hash, index.createSQL())
+	}
+	for _, trigger := range meta.triggers {
+		fmt.Fprintln(hash, meta.createTriggerSQL(trigger))
+	}
+	for _, stmt := range meta.extraDDL(driverName) {
+		fmt.Fprintln(hash, stmt)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (m *metaCache) fingerprintTable() string {
+	return m.prefix + fingerprintTable
+}
+
+// storedFingerprint returns the fingerprint stored for table, or "" if none is stored.
+func (m *metaCache) storedFingerprint(ctx context.Context, queryer sqlx.QueryerContext, driverName string, table string) (string, error) {
+	exists, err := tableExists(ctx, queryer, driverName, m.fingerprintTable())
+	if err != nil || !exists {
+		return "", err
+	}
+	query := fmt.Sprintf("SELECT `Fingerprint` FROM `%s` WHERE `Table` = ?", m.fingerprintTable())
+	result := ""
+	if err := getContext(ctx, queryer, &result, sqlx.Rebind(sqlx.BindType(driverName), query), table); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", withStack(err)
+	}
+	return result, nil
+}
+
+func (m *metaCache) storeFingerprint(ctx context.Context, execer sqlx.ExecerContext, driverName string, table string, fingerprint string) error {
+	if _, err := execer.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (`Table` TEXT PRIMARY KEY, `Fingerprint` TEXT)", m.fingerprintTable())); err != nil {
+		return withStack(err)
+	}
+	query := fmt.Sprintf("INSERT OR REPLACE INTO `%s` (`Table`, `Fingerprint`) VALUES (?, ?)", m.fingerprintTable())
+	if _, err := execer.ExecContext(ctx, sqlx.Rebind(sqlx.BindType(driverName), query), table, fingerprint); err != nil {
+		return withStack(err)
+	}
+	return nil
+}
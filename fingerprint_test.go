@@ -0,0 +1,70 @@
+package sqly
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type fingerprintedTestStruct struct {
+	Id   int64  `sqly:"pkey"`
+	Name string `sqly:"index"`
+}
+
+type evolvedFingerprintedTestStruct struct {
+	Id    int64  `sqly:"pkey"`
+	Name  string `sqly:"index"`
+	Added int
+}
+
+func (evolvedFingerprintedTestStruct) TableName() string {
+	return "fingerprintedTestStruct"
+}
+
+func TestSchemaFingerprints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	for run := 0; run < 2; run++ {
+		db, err := Open("sqlite", path, WithSchemaFingerprints())
+		noerr(t, err)
+		recorder := &recordingDB{DB: db}
+		executed, err := CreateTableIfNotExistsVerbose(ctx, recorder, fingerprintedTestStruct{})
+		noerr(t, err)
+		if run == 0 && len(executed) != 2 {
+			t.Errorf("got %q, wanted the CREATE TABLE and CREATE INDEX", executed)
+		}
+		if run == 1 && (len(executed) != 0 || len(recorder.statements) != 0) {
+			t.Errorf("got %q and %q, wanted nothing executed for an unchanged struct", executed, recorder.statements)
+		}
+		db.Close()
+	}
+	withFileDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, fingerprintedTestStruct{}))
+		executed, err := db.CreateTableIfNotExistsVerbose(ctx, evolvedFingerprintedTestStruct{})
+		noerr(t, err)
+		// The index of Name already exists, so only the new column is added.
+		want := []string{
+			"ALTER TABLE `fingerprintedTestStruct` ADD COLUMN `Added` INTEGER",
+		}
+		if !reflect.DeepEqual(executed, want) {
+			t.Errorf("got %q, wanted %q", executed, want)
+		}
+		executed, err = db.CreateTableIfNotExistsVerbose(ctx, evolvedFingerprintedTestStruct{})
+		noerr(t, err)
+		if len(executed) != 0 {
+			t.Errorf("got %q, wanted nothing executed for an unchanged struct", executed)
+		}
+		fingerprints := 0
+		noerr(t, db.Get(&fingerprints, "SELECT COUNT(*) FROM sqly_schema"))
+		if fingerprints != 1 {
+			t.Errorf("got %v fingerprints, wanted 1", fingerprints)
+		}
+	}, WithSchemaFingerprints())
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, fingerprintedTestStruct{}))
+		found := false
+		noerr(t, db.Get(&found, "SELECT COUNT(*) > 0 FROM sqlite_master WHERE name = 'sqly_schema'"))
+		if found {
+			t.Errorf("got a sqly_schema table without WithSchemaFingerprints")
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package sqly
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"modernc.org/sqlite"
+)
+
+type sqliteFunc struct {
+	nArgs         int32
+	deterministic bool
+	impl          atomic.Pointer[reflect.Value]
+}
+
+var (
+	sqliteFuncsLock sync.Mutex
+	sqliteFuncs     = map[string]*sqliteFunc{}
+	errorType       = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// RegisterFunc makes fn callable as name from SQL, in e.g. queries, CHECK constraints and expression indices.
+// fn must be a func returning a single value, or a value and an error, and is called with the SQL arguments converted to its parameter types.
+// Arguments that can't be converted without loss, like 2.5 to an int or 300 to an int8, make the call fail.
+//
+// The function is registered with the modernc sqlite driver, and is only available on connections opened after the registration,
+// so it must be called before the DB runs any queries. Use WithFunc to register it while opening the DB.
+// The registry is process global, like the driver's: the function is available to every sqlite DB in the process,
+// and registering the same name again, from any DB, replaces fn for all of them, as long as the number of arguments and determinism stays the same.
+func (db *DB) RegisterFunc(name string, fn any, deterministic bool) error {
+	if !isSQLiteDriver(db.DriverName()) {
+		return errors.Errorf("functions can't be registered for driver %q", db.DriverName())
+	}
+	return registerFunc(name, fn, deterministic)
+}
+
+// WithFunc registers fn as name, like RegisterFunc, before the DB opens any connections. The registration is process global.
+func WithFunc(name string, fn any, deterministic bool) Option {
+	return func(db *DB) error {
+		return db.RegisterFunc(name, fn, deterministic)
+	}
+}
+
+func registerFunc(name string, fn any, deterministic bool) error {
+	if err := validIdentifier(name); err != nil {
+		return errors.Wrap(err, "invalid function name")
+	}
+	val := reflect.ValueOf(fn)
+	if val.Kind() != reflect.Func || val.IsNil() {
+		return errors.Errorf("%v is not a func", fn)
+	}
+	typ := val.Type()
+	if typ.NumOut() < 1 || typ.NumOut() > 2 || (typ.NumOut() == 2 && typ.Out(1) != errorType) {
+		return errors.Errorf("%v must return a value, or a value and an error", typ)
+	}
+	nArgs := int32(typ.NumIn())
+	if typ.IsVariadic() {
+		nArgs = -1
+	}
+	sqliteFuncsLock.Lock()
+	defer sqliteFuncsLock.Unlock()
+	if found, ok := sqliteFuncs[name]; ok {
+		if found.nArgs != nArgs || found.deterministic != deterministic {
+			return errors.Errorf("function %q is already registered with %d arguments and deterministic %v", name, found.nArgs, found.deterministic)
+		}
+		found.impl.Store(&val)
+		return nil
+	}
+	registered := &sqliteFunc{nArgs: nArgs, deterministic: deterministic}
+	registered.impl.Store(&val)
+	if err := sqlite.RegisterFunction(name, &sqlite.FunctionImpl{
+		NArgs:         nArgs,
+		Deterministic: deterministic,
+		Scalar: func(_ *sqlite.FunctionContext, args []driver.Value) (result driver.Value, err error) {
+			// Panics escaping into the driver would leave its connection locked, and the transaction unable to roll back.
+			defer func() {
+				if r := recover(); r != nil {
+					result, err = nil, errors.Errorf("function %q panicked: %v", name, r)
+				}
+			}()
+			return registered.call(args)
+		},
+	}); err != nil {
+		return withStack(err)
+	}
+	sqliteFuncs[name] = registered
+	return nil
+}
+
+func (f *sqliteFunc) call(args []driver.Value) (driver.Value, error) {
+	impl := *f.impl.Load()
+	typ := impl.Type()
+	if typ.IsVariadic() && len(args) < typ.NumIn()-1 {
+		return nil, errors.Errorf("got %d arguments, wanted at least %d", len(args), typ.NumIn()-1)
+	}
+	in := make([]reflect.Value, len(args))
+	for argIndex, arg := range args {
+		var paramType reflect.Type
+		if typ.IsVariadic() && argIndex >= typ.NumIn()-1 {
+			paramType = typ.In(typ.NumIn() - 1).Elem()
+		} else {
+			paramType = typ.In(argIndex)
+		}
+		converted, err := convertArg(arg, paramType)
+		if err != nil {
+			return nil, errors.Wrapf(err, "argument %d", argIndex)
+		}
+		in[argIndex] = converted
+	}
+	out := impl.Call(in)
+	if len(out) == 2 && !out[1].IsNil() {
+		return nil, out[1].Interface().(error)
+	}
+	return driver.DefaultParameterConverter.ConvertValue(out[0].Interface())
+}
+
+func convertArg(arg driver.Value, typ reflect.Type) (reflect.Value, error) {
+	if arg == nil {
+		return reflect.Zero(typ), nil
+	}
+	val := reflect.ValueOf(arg)
+	if typ.Kind() == reflect.Interface {
+		if !val.Type().Implements(typ) {
+			return reflect.Value{}, errors.Errorf("%T doesn't implement %v", arg, typ)
+		}
+		return val, nil
+	}
+	if val.Type() == typ {
+		return val, nil
+	}
+	numeric := func(kind reflect.Kind) bool {
+		return kind >= reflect.Int && kind <= reflect.Float64
+	}
+	textual := func(t reflect.Type) bool {
+		return t.Kind() == reflect.String || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8)
+	}
+	switch {
+	case typ.Kind() == reflect.Bool && val.CanInt():
+		return reflect.ValueOf(val.Int() != 0).Convert(typ), nil
+	case numeric(typ.Kind()) && numeric(val.Kind()):
+		converted := val.Convert(typ)
+		if lossyConversion(val, converted) {
+			return reflect.Value{}, errors.Errorf("%T %v can't be converted to %v without loss", arg, arg, typ)
+		}
+		return converted, nil
+	case textual(typ) && textual(val.Type()):
+		return val.Convert(typ), nil
+	}
+	return reflect.Value{}, errors.Errorf("%T can't be converted to %v", arg, typ)
+}
+
+// lossyConversion returns whether converting from to to changed the value, e.g. by truncating a float, overflowing an integer or flipping a sign.
+// Conversions between floats are rounded, and never lossy.
+func lossyConversion(from, to reflect.Value) bool {
+	switch {
+	case from.CanFloat() && to.CanFloat():
+		return false
+	case from.CanInt() && to.CanUint():
+		return from.Int() < 0 || !to.Convert(from.Type()).Equal(from)
+	case from.CanUint() && to.CanInt():
+		return to.Int() < 0 || !to.Convert(from.Type()).Equal(from)
+	}
+	return !to.Convert(from.Type()).Equal(from)
+}
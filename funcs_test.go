@@ -0,0 +1,114 @@
+package sqly
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type checkedTestStruct struct {
+	Id   int64  `sqly:"pkey"`
+	Name string `sqly:"unique"`
+}
+
+func TestRegisterFunc(t *testing.T) {
+	withDB(t, func(db *DB) {
+		result := ""
+		noerr(t, db.Get(&result, "SELECT sqlyTestReverse('abc')"))
+		if result != "cba" {
+			t.Errorf("got %q, wanted \"cba\"", result)
+		}
+		sum := 0
+		noerr(t, db.Get(&sum, "SELECT sqlyTestSum(1, 2.0, 3)"))
+		if sum != 6 {
+			t.Errorf("got %v, wanted 6", sum)
+		}
+		noerr(t, db.CreateTableIfNotExists(ctx, checkedTestStruct{}))
+		_, err := db.Exec("CREATE TABLE checked (Name TEXT CHECK (sqlyTestValid(Name)))")
+		noerr(t, err)
+		_, err = db.Exec("INSERT INTO checked (Name) VALUES ('ok')")
+		noerr(t, err)
+		_, err = db.Exec("INSERT INTO checked (Name) VALUES ('')")
+		yeserr(t, err)
+		err = db.Get(&sum, "SELECT sqlyTestSum(1, 2.5)")
+		if err == nil || !strings.Contains(err.Error(), "without loss") {
+			t.Errorf("got %v, wanted 2.5 to be rejected as an int argument", err)
+		}
+		yeserr(t, db.Get(&sum, "SELECT sqlyTestSum(9223372036854775807.0)"))
+		small := 0
+		noerr(t, db.Get(&small, "SELECT sqlyTestSmall(-128)"))
+		if small != -128 {
+			t.Errorf("got %v, wanted -128", small)
+		}
+		yeserr(t, db.Get(&small, "SELECT sqlyTestSmall(300)"))
+		yeserr(t, db.Get(&small, "SELECT sqlyTestUnsigned(-1)"))
+		half := 0.0
+		noerr(t, db.Get(&half, "SELECT sqlyTestHalf(3)"))
+		if half != 1.5 {
+			t.Errorf("got %v, wanted 1.5", half)
+		}
+		yeserr(t, db.Get(&half, "SELECT sqlyTestHalf(9007199254740993)"))
+		valid := false
+		noerr(t, db.Get(&valid, "SELECT sqlyTestNot(0)"))
+		if !valid {
+			t.Errorf("got false, wanted 0 to convert to false")
+		}
+		err = db.Get(&valid, "SELECT sqlyTestNot(2.5)")
+		if err == nil || !strings.Contains(err.Error(), "can't be converted") {
+			t.Errorf("got %v, wanted 2.5 to be rejected as a bool argument", err)
+		}
+		err = db.Read(ctx, func(tx *Tx) error {
+			return tx.Get(&sum, "SELECT sqlyTestPanic()")
+		})
+		if err == nil || !strings.Contains(err.Error(), "panicked: boom") {
+			t.Errorf("got %v, wanted the panic as an error", err)
+		}
+		noerr(t, db.Get(&sum, "SELECT sqlyTestSum(1)"))
+		err = db.Get(&sum, "SELECT sqlyTestValid(NULL)")
+		yeserr(t, err)
+		if !strings.Contains(err.Error(), "empty name") {
+			t.Errorf("got %v, wanted the error returned by the func", err)
+		}
+	},
+		WithFunc("sqlyTestReverse", func(s string) string {
+			runes := []rune(s)
+			for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+				runes[i], runes[j] = runes[j], runes[i]
+			}
+			return string(runes)
+		}, true),
+		WithFunc("sqlyTestSum", func(nums ...int) int {
+			sum := 0
+			for _, num := range nums {
+				sum += num
+			}
+			return sum
+		}, true),
+		WithFunc("sqlyTestSmall", func(i int8) int8 {
+			return i
+		}, true),
+		WithFunc("sqlyTestUnsigned", func(u uint) uint {
+			return u
+		}, true),
+		WithFunc("sqlyTestHalf", func(f float64) float64 {
+			return f / 2
+		}, true),
+		WithFunc("sqlyTestNot", func(b bool) bool {
+			return !b
+		}, true),
+		WithFunc("sqlyTestPanic", func() int {
+			panic("boom")
+		}, true),
+		WithFunc("sqlyTestValid", func(s string) (bool, error) {
+			if s == "" {
+				return false, fmt.Errorf("empty name")
+			}
+			return true, nil
+		}, true))
+	withDB(t, func(db *DB) {
+		yeserr(t, db.RegisterFunc("sqlyTestReverse", func(s string) string { return s }, false))
+		yeserr(t, db.RegisterFunc("sqlyTestBad", func(s string) {}, false))
+		yeserr(t, db.RegisterFunc("sqlyTestBad", "not a func", false))
+		yeserr(t, db.RegisterFunc("bad name", func(s string) string { return s }, false))
+	})
+}
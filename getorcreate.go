@@ -0,0 +1,97 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+func (db *DB) GetOrCreate(ctx context.Context, structPointer any) (bool, error) {
+	return GetOrCreate(ctx, db, structPointer)
+}
+
+func (tx *Tx) GetOrCreate(ctx context.Context, structPointer any) (bool, error) {
+	created, err := GetOrCreate(ctx, tx, structPointer)
+	return created, tx.db.mapError(err)
+}
+
+// GetOrCreate inserts structPointer unless it conflicts with an existing row, and then loads the row, new or existing, back into structPointer.
+// The existing row is found using the unique, non partial column indices of the struct, including the content hash, and the pkey if it is set.
+// Returns whether the row was created. Only uniqueness conflicts fall back to loading the existing row, so rows violating e.g. CHECK constraints fail.
+// If ext is a *DB everything runs in a single Write.
+func GetOrCreate(ctx context.Context, ext sqlx.ExtContext, structPointer any) (bool, error) {
+	if db, ok := ext.(*DB); ok {
+		created := false
+		err := db.Write(ctx, func(tx *Tx) error {
+			var err error
+			created, err = GetOrCreate(ctx, tx, structPointer)
+			return err
+		})
+		return created, err
+	}
+	val, meta, err := structPointerMeta(ext, structPointer)
+	if err != nil {
+		return false, err
+	}
+	if err := meta.setContentHash(val); err != nil {
+		return false, err
+	}
+	ctx = labeled(ctx, ext, "getOrCreate", meta.typ.Name())
+	conditions := []string{}
+	params := []any{}
+	keys := []IndexSpec{}
+	if meta.pkey != nil && !meta.needsPrimaryKey(val) {
+		pkey := IndexSpec{}
+		for _, field := range meta.pkeys {
+			if !val.Field(field.index).IsZero() {
+				pkey.Columns = append(pkey.Columns, field.col)
+			}
+		}
+		if len(pkey.Columns) == len(meta.pkeys) {
+			keys = append(keys, pkey)
+		}
+	}
+	for _, index := range meta.indices {
+		if index.Unique && index.Expr == "" && index.Where == "" {
+			keys = append(keys, index)
+		}
+	}
+	fieldsByCol := map[string]*fieldMeta{}
+	for _, field := range meta.fields {
+		fieldsByCol[field.col] = field
+	}
+	for _, key := range keys {
+		colConditions := []string{}
+		for _, col := range key.Columns {
+			field := fieldsByCol[col]
+			if field == nil {
+				return false, errors.Errorf("unique index %q of %v has unknown col %q", key.IndexName(), meta.typ, col)
+			}
+			param, err := field.encode(val.Field(field.index))
+			if err != nil {
+				return false, err
+			}
+			colConditions = append(colConditions, fmt.Sprintf("`%s` = ?", col))
+			params = append(params, param)
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s)", strings.Join(colConditions, " AND ")))
+	}
+	if len(conditions) == 0 {
+		return false, errors.Errorf("%v has no unique index or set pkey to find existing rows with", meta.typ)
+	}
+	created, err := insertStruct(ctx, ext, meta, val, onConflictDoNothing)
+	if err != nil {
+		return false, err
+	}
+	if created {
+		return true, nil
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s LIMIT 1", meta.columnsSQL(), quoteTable(meta.table), strings.Join(conditions, " OR "))
+	if err := getStruct(ctx, ext, meta, val, query, params...); err != nil {
+		return false, err
+	}
+	return false, nil
+}
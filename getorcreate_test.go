@@ -0,0 +1,60 @@
+package sqly
+
+import (
+	"strings"
+	"testing"
+)
+
+type lookupTestStruct struct {
+	Id    int64  `sqly:"pkey,autoinc"`
+	Name  string `sqly:"unique"`
+	Count int
+}
+
+type checkedLookupTestStruct struct {
+	Id    int64  `sqly:"pkey,autoinc"`
+	Name  string `sqly:"unique"`
+	Count int
+}
+
+func (checkedLookupTestStruct) SQLYTableChecks() []string {
+	return []string{"Count >= 0"}
+}
+
+func TestGetOrCreate(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, lookupTestStruct{}))
+		first := &lookupTestStruct{Name: "a", Count: 1}
+		created, err := db.GetOrCreate(ctx, first)
+		noerr(t, err)
+		if !created || first.Id == 0 {
+			t.Errorf("got %v and %+v, wanted a created row with an id", created, first)
+		}
+		noerr(t, db.Upsert(ctx, &lookupTestStruct{Name: "b"}, false))
+		again := &lookupTestStruct{Name: "a", Count: 2}
+		created, err = db.GetOrCreate(ctx, again)
+		noerr(t, err)
+		if created || *again != *first {
+			t.Errorf("got %v and %+v, wanted the existing %+v", created, again, first)
+		}
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			byId := &lookupTestStruct{Id: first.Id, Name: "c"}
+			created, err := tx.GetOrCreate(ctx, byId)
+			noerr(t, err)
+			if created || *byId != *first {
+				t.Errorf("got %v and %+v, wanted the existing %+v", created, byId, first)
+			}
+			return nil
+		}))
+		if count := countRows(t, db, "lookupTestStruct"); count != 2 {
+			t.Errorf("got %v rows, wanted 2", count)
+		}
+		_, err = db.GetOrCreate(ctx, &sharedTestStruct{Name: "a"})
+		yeserr(t, err)
+		noerr(t, db.CreateTableIfNotExists(ctx, checkedLookupTestStruct{}))
+		_, err = db.GetOrCreate(ctx, &checkedLookupTestStruct{Name: "a", Count: -1})
+		if err == nil || !strings.Contains(err.Error(), "CHECK constraint failed") {
+			t.Errorf("got %v, wanted the CHECK violation", err)
+		}
+	})
+}
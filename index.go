@@ -0,0 +1,159 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// IndexSpec describes an index on either Columns or Expr of Table.
+// The index will be named "Table.Name", and without a Name it's named by the NamingStrategy of the DB, just like the indices created from field tags.
+type IndexSpec struct {
+	Table   string
+	Columns []string
+	Expr    string
+	Unique  bool
+	Where   string
+	Name    string
+
+	// fullName is the name given by a NamingStrategy, which replaces "Table.Name".
+	fullName string
+}
+
+func (spec IndexSpec) IndexName() string {
+	if spec.fullName != "" {
+		return spec.fullName
+	}
+	name := spec.Name
+	if name == "" {
+		name = strings.Join(spec.Columns, ",")
+	}
+	return fmt.Sprintf("%s.%s", spec.Table, name)
+}
+
+// Indices works like the package level Indices, but uses the NameMapper and NamingStrategy of db.
+func (db *DB) Indices(prototype any) ([]IndexSpec, error) {
+	return indices(db, prototype)
+}
+
+// Indices returns the indices CreateTableIfNotExists creates for prototype, from both field tags and Indexer, in the order they are created,
+// using the default NameMapper and NamingStrategy. The names the indices get are returned by their IndexName.
+func Indices(prototype any) ([]IndexSpec, error) {
+	return indices(nil, prototype)
+}
+
+func indices(x any, prototype any) ([]IndexSpec, error) {
+	meta, err := metaOf(x, prototype)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]IndexSpec, len(meta.indices))
+	for specIndex, spec := range meta.indices {
+		// The columns are copied to keep callers from changing the cached meta.
+		spec.Columns = append([]string(nil), spec.Columns...)
+		result[specIndex] = spec
+	}
+	return result, nil
+}
+
+func (spec IndexSpec) validate() error {
+	if spec.Table == "" {
+		return errors.Errorf("index %+v has no table", spec)
+	}
+	if err := validTableName(spec.Table); err != nil {
+		return err
+	}
+	if (len(spec.Columns) == 0) == (spec.Expr == "") {
+		return errors.Errorf("index %+v must have either columns or an expression", spec)
+	}
+	if strings.Contains(spec.IndexName(), "`") {
+		return errors.Errorf("index name %q can't contain backticks", spec.IndexName())
+	}
+	for _, col := range spec.Columns {
+		if strings.Contains(col, "`") {
+			return errors.Errorf("index column %q can't contain backticks", col)
+		}
+	}
+	return nil
+}
+
+func (spec IndexSpec) createSQL() string {
+	unique := ""
+	if spec.Unique {
+		unique = "UNIQUE "
+	}
+	target := spec.Expr
+	if target == "" {
+		escapedCols := make([]string, len(spec.Columns))
+		for colIndex, col := range spec.Columns {
+			escapedCols[colIndex] = fmt.Sprintf("`%s`", col)
+		}
+		target = strings.Join(escapedCols, ",")
+	}
+	where := ""
+	if spec.Where != "" {
+		where = fmt.Sprintf(" WHERE %s", spec.Where)
+	}
+	// Indices of tables in attached databases are created in the same database, and must name their table without the schema.
+	schema, table := splitTable(spec.Table)
+	name := spec.IndexName()
+	if schema != "" {
+		name = strings.TrimPrefix(name, schema+".")
+	}
+	return fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s ON `%s` (%s)%s", unique, quoteQualified(schema, name), table, target, where)
+}
+
+func ensureIndex(ctx context.Context, execer sqlx.ExecerContext, spec IndexSpec) (string, error) {
+	if spec.Name == "" && spec.fullName == "" && len(spec.Columns) > 0 {
+		spec.fullName = metasFor(execer).naming.IndexName(spec.Table, spec.Columns, spec.Unique)
+	}
+	if err := spec.validate(); err != nil {
+		return "", err
+	}
+	stmt := spec.createSQL()
+	if _, err := execer.ExecContext(labeled(ctx, execer, "ensureIndex", spec.IndexName()), stmt); err != nil {
+		return "", withStack(err)
+	}
+	return stmt, nil
+}
+
+// EnsureIndex creates the index described by spec unless it already exists.
+func EnsureIndex(ctx context.Context, execer sqlx.ExecerContext, spec IndexSpec) error {
+	_, err := ensureIndex(ctx, execer, spec)
+	return err
+}
+
+// DropIndex drops the index with the given full name, as returned by IndexSpec.IndexName, if it exists.
+// Names like "archive.Events.Email", with more than one dot, are the names of indices of tables in attached databases.
+func DropIndex(ctx context.Context, execer sqlx.ExecerContext, name string) error {
+	if strings.Contains(name, "`") {
+		return errors.Errorf("index name %q can't contain backticks", name)
+	}
+	quoted := quoteQualified("", name)
+	if strings.Count(name, ".") > 1 {
+		quoted = quoteTable(name)
+	}
+	if _, err := execer.ExecContext(labeled(ctx, execer, "dropIndex", name), fmt.Sprintf("DROP INDEX IF EXISTS %s", quoted)); err != nil {
+		return withStack(err)
+	}
+	return nil
+}
+
+func (db *DB) EnsureIndex(ctx context.Context, spec IndexSpec) error {
+	return db.mapError(EnsureIndex(ctx, db, spec))
+}
+
+func (db *DB) DropIndex(ctx context.Context, name string) error {
+	return db.mapError(DropIndex(ctx, db, name))
+}
+
+func (tx *Tx) EnsureIndex(ctx context.Context, spec IndexSpec) error {
+	return tx.db.mapError(EnsureIndex(ctx, tx, spec))
+}
+
+func (tx *Tx) DropIndex(ctx context.Context, name string) error {
+	return tx.db.mapError(DropIndex(ctx, tx, name))
+}
@@ -0,0 +1,77 @@
+package sqly
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnsureIndex(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		spec := IndexSpec{
+			Table:   "sharedTestStruct",
+			Columns: []string{"Name"},
+			Unique:  true,
+			Where:   "`Name` != ''",
+		}
+		if spec.IndexName() != "sharedTestStruct.Name" {
+			t.Errorf("got %q, wanted \"sharedTestStruct.Name\"", spec.IndexName())
+		}
+		noerr(t, db.EnsureIndex(ctx, spec))
+		noerr(t, db.EnsureIndex(ctx, spec))
+		noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 1, Name: "a"}, false))
+		yeserr(t, db.Upsert(ctx, &sharedTestStruct{Id: 2, Name: "a"}, false))
+		noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 3}, false))
+		noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 4}, false))
+		noerr(t, db.DropIndex(ctx, spec.IndexName()))
+		noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 2, Name: "a"}, false))
+		noerr(t, db.DropIndex(ctx, spec.IndexName()))
+
+		yeserr(t, db.EnsureIndex(ctx, IndexSpec{Table: "sharedTestStruct"}))
+		yeserr(t, db.EnsureIndex(ctx, IndexSpec{Table: "sharedTestStruct", Columns: []string{"Name"}, Expr: "lower(Name)"}))
+		yeserr(t, db.EnsureIndex(ctx, IndexSpec{Columns: []string{"Name"}}))
+	})
+}
+
+func TestIndices(t *testing.T) {
+	specs, err := Indices(indexedTestStruct{})
+	noerr(t, err)
+	names := []string{}
+	for _, spec := range specs {
+		names = append(names, spec.IndexName())
+	}
+	if want := []string{
+		"indexedTestStruct.Indexed",
+		"indexedTestStruct.Unique",
+		"indexedTestStruct.ThreeIndexed3,ThreeIndexed1,ThreeIndexed2",
+		"indexedTestStruct.ThreeUnique3,ThreeUnique1,ThreeUnique2",
+	}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, wanted %v", names, want)
+	}
+	if !specs[3].Unique || specs[2].Unique || !reflect.DeepEqual(specs[3].Columns, []string{"ThreeUnique3", "ThreeUnique1", "ThreeUnique2"}) {
+		t.Errorf("got %+v, wanted the uniqueWith index", specs[3])
+	}
+	specs[0].Columns[0] = "changed"
+	if again, err := Indices(indexedTestStruct{}); err != nil || again[0].Columns[0] != "Indexed" {
+		t.Errorf("got %+v, %v, wanted the returned specs to be copies", again, err)
+	}
+
+	specs, err = Indices(partialIndexedTestStruct{})
+	noerr(t, err)
+	if len(specs) != 2 || specs[1].Name != "liveName" || specs[1].Where != "NOT `Deleted`" || !specs[1].Unique {
+		t.Errorf("got %+v, wanted the tagged index followed by the partial index", specs)
+	}
+	_, err = Indices(invalidIndexedTestStruct{})
+	yeserr(t, err)
+
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, indexedTestStruct{}))
+		specs, err := db.Indices(indexedTestStruct{})
+		noerr(t, err)
+		created, err := existingIndices(ctx, db, db.DriverName(), "indexedTestStruct")
+		noerr(t, err)
+		if len(created) != len(specs) {
+			t.Errorf("got %v indices, wanted %+v", created, specs)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package sqly
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// FKViolation is a row violating a foreign key constraint, as reported by PRAGMA foreign_key_check.
+type FKViolation struct {
+	// Table is the table of the violating row.
+	Table string
+	// RowID is the rowid of the violating row, which is NULL for WITHOUT ROWID tables.
+	RowID sql.NullInt64
+	// Parent is the table referred to by the violated foreign key.
+	Parent string
+	// FKID is the id of the violated foreign key, as listed by PRAGMA foreign_key_list of Table.
+	FKID int
+}
+
+// IntegrityCheck runs PRAGMA integrity_check, or the faster but less thorough quick_check if quick is set, and returns the problems found.
+// An empty result means that the database is ok.
+// The read lock is held while checking.
+func (db *DB) IntegrityCheck(ctx context.Context, quick bool) ([]string, error) {
+	result, err := db.integrityCheck(ctx, quick)
+	return result, db.mapError(err)
+}
+
+func (db *DB) integrityCheck(ctx context.Context, quick bool) ([]string, error) {
+	if !isSQLiteDriver(db.DriverName()) {
+		return nil, errors.Errorf("integrity checks aren't supported for driver %q", db.DriverName())
+	}
+	pragma := "integrity_check"
+	if quick {
+		pragma = "quick_check"
+	}
+	if err := db.rlockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer db.locker.RUnlock()
+	rows, err := db.QueryxContext(labeled(ctx, db, "pragma", pragma), fmt.Sprintf("PRAGMA %s", pragma))
+	if err != nil {
+		return nil, withStack(err)
+	}
+	defer rows.Close()
+	result := []string{}
+	for rows.Next() {
+		problem := ""
+		if err := rows.Scan(&problem); err != nil {
+			return nil, withStack(err)
+		}
+		if problem != "ok" {
+			result = append(result, problem)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, withStack(err)
+	}
+	return result, nil
+}
+
+// ForeignKeyCheck runs PRAGMA foreign_key_check, and returns the rows violating foreign key constraints.
+// It finds violations also when foreign key enforcement is turned off.
+// The read lock is held while checking.
+func (db *DB) ForeignKeyCheck(ctx context.Context) ([]FKViolation, error) {
+	result, err := db.foreignKeyCheck(ctx)
+	return result, db.mapError(err)
+}
+
+func (db *DB) foreignKeyCheck(ctx context.Context) ([]FKViolation, error) {
+	if !isSQLiteDriver(db.DriverName()) {
+		return nil, errors.Errorf("foreign key checks aren't supported for driver %q", db.DriverName())
+	}
+	if err := db.rlockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer db.locker.RUnlock()
+	rows, err := db.QueryxContext(labeled(ctx, db, "pragma", "foreign_key_check"), "PRAGMA foreign_key_check")
+	if err != nil {
+		return nil, withStack(err)
+	}
+	defer rows.Close()
+	result := []FKViolation{}
+	for rows.Next() {
+		violation := FKViolation{}
+		if err := rows.Scan(&violation.Table, &violation.RowID, &violation.Parent, &violation.FKID); err != nil {
+			return nil, withStack(err)
+		}
+		result = append(result, violation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, withStack(err)
+	}
+	return result, nil
+}
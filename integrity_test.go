@@ -0,0 +1,73 @@
+package sqly
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIntegrityCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	db, err := Open("sqlite", path)
+	noerr(t, err)
+	noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+	noerr(t, db.Write(ctx, func(tx *Tx) error {
+		for i := 0; i < 1000; i++ {
+			if err := tx.Upsert(ctx, &sharedTestStruct{Id: i + 1, Name: strings.Repeat("a", 100)}, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	for _, quick := range []bool{false, true} {
+		problems, err := db.IntegrityCheck(ctx, quick)
+		noerr(t, err)
+		if len(problems) != 0 {
+			t.Errorf("got %q for a healthy database, wanted no problems", problems)
+		}
+	}
+	noerr(t, db.Close())
+
+	content, err := os.ReadFile(path)
+	noerr(t, err)
+	// Overwriting the middle of the file with garbage corrupts the pages of the table, while leaving the header and schema intact.
+	for index := len(content) / 2; index < len(content)/2+4096; index++ {
+		content[index] = 0xff
+	}
+	corruptPath := filepath.Join(dir, "corrupt.db")
+	noerr(t, os.WriteFile(corruptPath, content, 0600))
+	corrupt, err := Open("sqlite", corruptPath)
+	noerr(t, err)
+	defer corrupt.Close()
+	// Depending on which pages the garbage hits, SQLite either reports the problems or fails the check itself as malformed.
+	problems, err := corrupt.IntegrityCheck(ctx, false)
+	if err == nil && len(problems) == 0 {
+		t.Errorf("got no problems for a corrupt database")
+	}
+}
+
+func TestForeignKeyCheck(t *testing.T) {
+	withFileDB(t, func(db *DB) {
+		_, err := db.ExecContext(ctx, "CREATE TABLE parent (Id INTEGER PRIMARY KEY); CREATE TABLE child (Id INTEGER PRIMARY KEY, ParentId INTEGER REFERENCES parent(Id))")
+		noerr(t, err)
+		_, err = db.ExecContext(ctx, "INSERT INTO parent (Id) VALUES (1); INSERT INTO child (Id, ParentId) VALUES (1, 1), (2, 2)")
+		noerr(t, err)
+		violations, err := db.ForeignKeyCheck(ctx)
+		noerr(t, err)
+		if len(violations) != 1 {
+			t.Fatalf("got %+v, wanted one violation", violations)
+		}
+		if violation := violations[0]; violation.Table != "child" || !violation.RowID.Valid || violation.RowID.Int64 != 2 || violation.Parent != "parent" || violation.FKID != 0 {
+			t.Errorf("got %+v, wanted row 2 of child violating its key to parent", violation)
+		}
+		_, err = db.ExecContext(ctx, "DELETE FROM child WHERE Id = 2")
+		noerr(t, err)
+		violations, err = db.ForeignKeyCheck(ctx)
+		noerr(t, err)
+		if len(violations) != 0 {
+			t.Errorf("got %+v, wanted no violations", violations)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package sqly
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"modernc.org/libc"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// connInterrupter returns a function aborting the statements running on driverConn, or nil if the driver doesn't support it.
+// The modernc driver only interrupts a statement while it's being executed, not while its rows are stepped through, and doesn't export
+// its interrupt, so this reads the SQLite handle of the connection and calls sqlite3_interrupt while holding the lock the driver uses to
+// defend against closing the connection concurrently.
+func connInterrupter(driverConn any) func() {
+	val := reflect.ValueOf(driverConn)
+	if val.Kind() != reflect.Pointer || val.Elem().Kind() != reflect.Struct || val.Elem().Type().PkgPath() != "modernc.org/sqlite" {
+		return nil
+	}
+	locker, ok := driverConn.(sync.Locker)
+	if !ok {
+		return nil
+	}
+	handle := val.Elem().FieldByName("db")
+	tls := val.Elem().FieldByName("tls")
+	if handle.Kind() != reflect.Uintptr || tls.Type() != reflect.TypeFor[*libc.TLS]() {
+		return nil
+	}
+	return func() {
+		locker.Lock()
+		defer locker.Unlock()
+		if tls.IsNil() || handle.Uint() == 0 {
+			return
+		}
+		interruptTLS := libc.NewTLS()
+		defer interruptTLS.Close()
+		sqlite3.Xsqlite3_interrupt(interruptTLS, uintptr(handle.Uint()))
+	}
+}
+
+// interruptOnDone calls interrupt if ctx is done before the returned function is called.
+// The returned function waits until interrupt can't be called anymore, so that the connection can be reused safely.
+func interruptOnDone(ctx context.Context, interrupt func()) func() {
+	if interrupt == nil || ctx.Done() == nil {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			interrupt()
+		case <-stop:
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+// release stops interrupting the connection of tx, and returns it to the pool.
+func (tx *Tx) release() {
+	if tx.conn == nil {
+		return
+	}
+	tx.stopInterrupts()
+	tx.conn.Close()
+	tx.conn = nil
+}
@@ -0,0 +1,47 @@
+package sqly
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInterruptOnCancel(t *testing.T) {
+	withFileDB(t, func(db *DB) {
+		_, err := db.ExecContext(ctx, "CREATE TABLE big (x INTEGER); WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 3000) INSERT INTO big SELECT x FROM c")
+		noerr(t, err)
+		// The first row is found right away, and the next one only after stepping through the whole cross join.
+		query := "SELECT a.x FROM big a, big b, big c WHERE (a.x = 1 AND b.x = 1 AND c.x = 1) OR a.x + b.x + c.x = 0"
+		cancelCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		done := make(chan error, 1)
+		go func() {
+			done <- db.Read(cancelCtx, func(tx *Tx) error {
+				return tx.EachMap(tx.Context(), query, func(map[string]any) error { return nil })
+			})
+		}()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Errorf("got no error from the cancelled query")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("cancelled query didn't return within 5s")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("cancelled query took %v", elapsed)
+		}
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO big (x) VALUES (0)")
+			return err
+		}))
+		count := 0
+		noerr(t, db.Read(ctx, func(tx *Tx) error {
+			return tx.GetContext(ctx, &count, "SELECT COUNT(*) FROM big")
+		}))
+		if count != 3001 {
+			t.Errorf("got %v rows, wanted 3001", count)
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package sqly
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ConflictMode decides what ImportJSON does with rows conflicting with existing rows.
+type ConflictMode int
+
+const (
+	// ConflictFail fails the import.
+	ConflictFail ConflictMode = iota
+	// ConflictIgnore skips the conflicting rows.
+	ConflictIgnore
+	// ConflictReplace replaces the existing rows.
+	ConflictReplace
+)
+
+func (mode ConflictMode) clause() (string, error) {
+	switch mode {
+	case ConflictFail:
+		return "", nil
+	case ConflictIgnore:
+		return "OR IGNORE ", nil
+	case ConflictReplace:
+		return "OR REPLACE ", nil
+	}
+	return "", errors.Errorf("unknown conflict mode %v", mode)
+}
+
+// ExportJSON writes the rows of the table of prototype to w as a JSON array of objects keyed by column name.
+// The fields use their natural JSON form, except SQLTime and SQLTimeText that are written as RFC3339Nano UTC times, with zero SQLTimeTexts as null.
+// The rows are streamed one at a time, and the export stops when ctx is done. If q is a *DB the rows are read in a Read transaction.
+func ExportJSON(ctx context.Context, q Querier, prototype any, w io.Writer) error {
+	meta, err := metaOf(q, prototype)
+	if err != nil {
+		return err
+	}
+	return readIn(ctx, q, func(q Querier) error {
+		return exportJSON(labeled(ctx, q, "exportJSON", meta.typ.Name()), q, meta, w)
+	})
+}
+
+func exportJSON(ctx context.Context, q Querier, meta *tableMeta, w io.Writer) error {
+	keys := make([][]byte, len(meta.fields))
+	for index, field := range meta.fields {
+		key, err := json.Marshal(field.col)
+		if err != nil {
+			return withStack(err)
+		}
+		keys[index] = key
+	}
+	rows, err := q.QueryxContext(ctx, fmt.Sprintf("SELECT %s FROM %s", meta.columnsSQL(), quoteTable(meta.table)))
+	if err != nil {
+		return withStack(err)
+	}
+	defer rows.Close()
+	if _, err := io.WriteString(w, "["); err != nil {
+		return withStack(err)
+	}
+	buf := []byte{}
+	for first := true; rows.Next(); first = false {
+		if err := ctx.Err(); err != nil {
+			return withStack(err)
+		}
+		val := reflect.New(meta.typ).Elem()
+		if err := meta.scanRow(rows, val); err != nil {
+			return err
+		}
+		if err := meta.afterScan(val); err != nil {
+			return err
+		}
+		buf = buf[:0]
+		if !first {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\n{"...)
+		for index, field := range meta.fields {
+			if index > 0 {
+				buf = append(buf, ',')
+			}
+			value, err := json.Marshal(jsonValue(val.Field(field.index)))
+			if err != nil {
+				return errors.Wrapf(err, "encoding %q", field.name)
+			}
+			buf = append(append(append(buf, keys[index]...), ':'), value...)
+		}
+		buf = append(buf, '}')
+		if _, err := w.Write(buf); err != nil {
+			return withStack(err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return withStack(err)
+	}
+	_, err = io.WriteString(w, "\n]\n")
+	return withStack(err)
+}
+
+// jsonValue returns the value to JSON encode for fieldVal.
+func jsonValue(fieldVal reflect.Value) any {
+	for fieldVal.Kind() == reflect.Ptr {
+		if fieldVal.IsNil() {
+			return nil
+		}
+		fieldVal = fieldVal.Elem()
+	}
+	switch typed := fieldVal.Interface().(type) {
+	case SQLTime:
+		return typed.Time().UTC().Format(time.RFC3339Nano)
+	case SQLTimeText:
+		if typed.Time().IsZero() {
+			return nil
+		}
+		return typed.Time().UTC().Format(time.RFC3339Nano)
+	}
+	if fieldVal.CanAddr() {
+		return fieldVal.Addr().Interface()
+	}
+	return fieldVal.Interface()
+}
+
+// ImportJSON inserts the objects of the JSON array in r, as written by ExportJSON, into the table of T, and returns the number of rows inserted.
+// The array is decoded one object at a time, and the rows are inserted 1000 at a time, each batch in its own Write.
+// Columns of T missing from an object are left at their zero value, so an absent autoinc primary key gets generated.
+func ImportJSON[T any](ctx context.Context, db *DB, r io.Reader, conflict ConflictMode) (int64, error) {
+	meta, err := metasFor(db).get(reflect.TypeFor[T]())
+	if err != nil {
+		return 0, err
+	}
+	clause, err := conflict.clause()
+	if err != nil {
+		return 0, err
+	}
+	fieldsByCol := map[string]*fieldMeta{}
+	for _, field := range meta.fields {
+		fieldsByCol[field.col] = field
+	}
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil {
+		return 0, withStack(err)
+	} else if token != json.Delim('[') {
+		return 0, errors.Errorf("got %v, wanted a JSON array", token)
+	}
+	inserted := int64(0)
+	element := 0
+	for decoder.More() {
+		batch := int64(0)
+		if err := db.Write(ctx, func(tx *Tx) error {
+			ctx := labeled(ctx, tx, "importJSON", meta.typ.Name())
+			for count := 0; count < defaultImportBatchSize && decoder.More(); count++ {
+				if err := ctx.Err(); err != nil {
+					return withStack(err)
+				}
+				object := map[string]json.RawMessage{}
+				if err := decoder.Decode(&object); err != nil {
+					return errors.Wrapf(err, "element %d", element)
+				}
+				var row T
+				val := reflect.ValueOf(&row).Elem()
+				for col, raw := range object {
+					field, found := fieldsByCol[col]
+					if !found {
+						return errors.Errorf("element %d: %v has no column %q", element, meta.typ, col)
+					}
+					if err := parseJSON(val.Field(field.index), raw); err != nil {
+						return errors.Wrapf(err, "element %d, column %q", element, col)
+					}
+				}
+				rowInserted, err := insertStruct(ctx, tx, meta, val, clause)
+				if err != nil {
+					return errors.Wrapf(err, "element %d", element)
+				}
+				if rowInserted {
+					batch++
+				}
+				element++
+			}
+			return nil
+		}); err != nil {
+			return inserted, err
+		}
+		inserted += batch
+	}
+	return inserted, nil
+}
+
+// parseJSON sets fieldVal from raw, encoded like jsonValue encodes it.
+func parseJSON(fieldVal reflect.Value, raw json.RawMessage) error {
+	if string(raw) == "null" {
+		fieldVal.SetZero()
+		return nil
+	}
+	for fieldVal.Kind() == reflect.Ptr {
+		if fieldVal.IsNil() {
+			fieldVal.Set(reflect.New(fieldVal.Type().Elem()))
+		}
+		fieldVal = fieldVal.Elem()
+	}
+	switch fieldVal.Type() {
+	case sqlTimeType, sqlTimeTextType:
+		var t time.Time
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return withStack(err)
+		}
+		if fieldVal.Type() == sqlTimeType {
+			fieldVal.SetInt(int64(ToSQLTime(t)))
+		} else {
+			fieldVal.Set(reflect.ValueOf(ToSQLTimeText(t)))
+		}
+		return nil
+	}
+	return withStack(json.Unmarshal(raw, fieldVal.Addr().Interface()))
+}
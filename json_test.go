@@ -0,0 +1,119 @@
+package sqly
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type jsonTestStruct struct {
+	Id       int `sqly:"pkey"`
+	Small    int8
+	Unsigned uint32
+	Ratio    float32
+	Score    float64
+	Name     string
+	Active   bool
+	Data     []byte
+	Hash     [4]byte
+	Created  SQLTime
+	Updated  SQLTimeText
+	Count    *int
+	Big      big.Int
+	Rat      *big.Rat
+	Document string `sqly:"transform=gzip"`
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	count := 7
+	created := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	first := jsonTestStruct{
+		Id:       1,
+		Small:    -8,
+		Unsigned: 4000000000,
+		Ratio:    0.25,
+		Score:    -1.5e-10,
+		Name:     "a \"quoted\"\nname",
+		Active:   true,
+		Data:     []byte{0, 1, 2},
+		Hash:     [4]byte{1, 2, 3, 4},
+		Created:  ToSQLTime(created),
+		Updated:  ToSQLTimeText(created),
+		Count:    &count,
+		Rat:      big.NewRat(1, 3),
+		Document: strings.Repeat("compressible ", 10),
+	}
+	first.Big.SetString("123456789012345678901234567890", 10)
+	exported := &bytes.Buffer{}
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, jsonTestStruct{}))
+		noerr(t, db.Upsert(ctx, &first, false))
+		noerr(t, db.Upsert(ctx, &jsonTestStruct{Id: 2}, false))
+		noerr(t, ExportJSON(ctx, db, jsonTestStruct{}, exported))
+	})
+	if !strings.Contains(exported.String(), `"Created":"2024-01-02T03:04:05.000000006Z"`) || !strings.Contains(exported.String(), `"Data":"AAEC"`) || !strings.Contains(exported.String(), `"Updated":null`) {
+		t.Errorf("got %s, wanted RFC3339 times, base64 BLOBs and null for the zero SQLTimeText", exported)
+	}
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, jsonTestStruct{}))
+		inserted, err := ImportJSON[jsonTestStruct](ctx, db, bytes.NewReader(exported.Bytes()), ConflictFail)
+		noerr(t, err)
+		if inserted != 2 {
+			t.Errorf("got %v rows inserted, wanted 2", inserted)
+		}
+		got, err := SelectSQL[jsonTestStruct](ctx, db, "SELECT * FROM jsonTestStruct ORDER BY Id")
+		noerr(t, err)
+		if len(got) != 2 || got[0].Big.Cmp(&first.Big) != 0 || got[0].Rat.Cmp(first.Rat) != 0 || got[1].Rat != nil {
+			t.Fatalf("got %+v, wanted the big numbers of %+v", got, first)
+		}
+		got[0].Big, got[0].Rat, first.Big, first.Rat = big.Int{}, nil, big.Int{}, nil
+		if !reflect.DeepEqual(got, []jsonTestStruct{first, {Id: 2}}) {
+			t.Errorf("got %+v, wanted %+v", got, []jsonTestStruct{first, {Id: 2}})
+		}
+		reexported := &bytes.Buffer{}
+		noerr(t, ExportJSON(ctx, db, jsonTestStruct{}, reexported))
+		if reexported.String() != exported.String() {
+			t.Errorf("got %s after importing, wanted %s", reexported, exported)
+		}
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			return ExportJSON(ctx, tx, jsonTestStruct{}, &bytes.Buffer{})
+		}))
+	})
+}
+
+func TestImportJSONConflicts(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, createdEvent{}))
+		inserted, err := ImportJSON[createdEvent](ctx, db, strings.NewReader(`[{"Name": "a"}, {"Id": 5, "Name": "b"}]`), ConflictFail)
+		noerr(t, err)
+		if inserted != 2 {
+			t.Errorf("got %v rows inserted, wanted 2", inserted)
+		}
+		_, err = ImportJSON[createdEvent](ctx, db, strings.NewReader(`[{"Id": 6, "Name": "c"}, {"Id": 5, "Name": "d"}]`), ConflictFail)
+		yeserr(t, err)
+		inserted, err = ImportJSON[createdEvent](ctx, db, strings.NewReader(`[{"Id": 6, "Name": "c"}, {"Id": 5, "Name": "d"}]`), ConflictIgnore)
+		noerr(t, err)
+		if inserted != 1 {
+			t.Errorf("got %v rows inserted, wanted 1", inserted)
+		}
+		inserted, err = ImportJSON[createdEvent](ctx, db, strings.NewReader(`[{"Id": 5, "Name": "e"}]`), ConflictReplace)
+		noerr(t, err)
+		if inserted != 1 {
+			t.Errorf("got %v rows inserted, wanted 1", inserted)
+		}
+		got, err := SelectSQL[createdEvent](ctx, db, "SELECT * FROM createdEvent ORDER BY Id")
+		noerr(t, err)
+		if want := []createdEvent{{Id: 1, Name: "a"}, {Id: 5, Name: "e"}, {Id: 6, Name: "c"}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, wanted %+v", got, want)
+		}
+		for _, bad := range []string{`{"Id": 1}`, `[{"Extra": 1}]`, `[{"Name": 1}]`, `[{"Name": "x"}`} {
+			_, err = ImportJSON[createdEvent](ctx, db, strings.NewReader(bad), ConflictIgnore)
+			yeserr(t, err)
+		}
+		_, err = ImportJSON[createdEvent](ctx, db, strings.NewReader(`[]`), ConflictMode(10))
+		yeserr(t, err)
+	})
+}
@@ -0,0 +1,48 @@
+package sqly
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// jsonPathRegexp matches the JSON1 paths made of object keys and array indices, like `$.tags[0].name` or `$.items[#-1]`.
+	jsonPathRegexp = regexp.MustCompile(`^\$(\.[A-Za-z_][A-Za-z0-9_]*|\[[0-9]+\]|\[#(-[0-9]+)?\])*$`)
+	jsonOps        = map[string]string{
+		"=":      "= ?",
+		"!=":     "!= ?",
+		"<>":     "<> ?",
+		"<":      "< ?",
+		"<=":     "<= ?",
+		">":      "> ?",
+		">=":     ">= ?",
+		"IS":     "IS ?",
+		"IS NOT": "IS NOT ?",
+		"LIKE":   "LIKE ?",
+		"GLOB":   "GLOB ?",
+		"IN":     "IN (?)",
+		"NOT IN": "NOT IN (?)",
+	}
+)
+
+// WhereJSON returns a condition, and its args, comparing the value at path of the JSON stored in the TEXT column col with value using op,
+// like "json_extract(`Attrs`, ?) = ?", for use in the WHERE clause of queries run by GetSQL or SelectSQL.
+// The path must consist of object keys and array indices, like `$.tags[0].name`, and op is one of =, !=, <>, <, <=, >, >=, IS, IS NOT, LIKE,
+// GLOB, IN and NOT IN, where IN and NOT IN take a slice value that GetSQL and SelectSQL expand.
+// JSON1 is built into SQLite since 3.38.
+func WhereJSON(col string, path string, op string, value any) (string, []any, error) {
+	if err := validIdentifier(col); err != nil {
+		return "", nil, err
+	}
+	if !jsonPathRegexp.MatchString(path) {
+		return "", nil, errors.Errorf("%q is not a valid JSON path", path)
+	}
+	comparison, found := jsonOps[strings.ToUpper(strings.Join(strings.Fields(op), " "))]
+	if !found {
+		return "", nil, errors.Errorf("%q is not a supported JSON comparison", op)
+	}
+	return fmt.Sprintf("json_extract(`%s`, ?) %s", col, comparison), []any{path, value}, nil
+}
@@ -0,0 +1,51 @@
+package sqly
+
+import (
+	"reflect"
+	"testing"
+)
+
+type jsonQueryTestStruct struct {
+	Id    int64 `sqly:"pkey"`
+	Attrs string
+}
+
+func TestWhereJSON(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, jsonQueryTestStruct{}))
+		noerr(t, db.UpsertMany(ctx, false,
+			&jsonQueryTestStruct{Id: 1, Attrs: `{"color": "red", "size": 3, "tags": ["a", "b"]}`},
+			&jsonQueryTestStruct{Id: 2, Attrs: `{"color": "blue", "size": 5, "tags": ["b"]}`},
+			&jsonQueryTestStruct{Id: 3, Attrs: `{"color": "red", "size": 7}`}))
+		for _, tc := range []struct {
+			path  string
+			op    string
+			value any
+			want  []int64
+		}{
+			{path: "$.color", op: "=", value: "red", want: []int64{1, 3}},
+			{path: "$.size", op: ">=", value: 5, want: []int64{2, 3}},
+			{path: "$.tags[0]", op: "=", value: "b", want: []int64{2}},
+			{path: "$.tags[#-1]", op: "=", value: "b", want: []int64{1, 2}},
+			{path: "$.tags", op: "is", value: nil, want: []int64{3}},
+			{path: "$.size", op: "not  in", value: []int{3, 7}, want: []int64{2}},
+		} {
+			cond, args, err := WhereJSON("Attrs", tc.path, tc.op, tc.value)
+			noerr(t, err)
+			found, err := SelectSQL[int64](ctx, db, "SELECT Id FROM jsonQueryTestStruct WHERE "+cond+" ORDER BY Id", args...)
+			noerr(t, err)
+			if !reflect.DeepEqual(found, tc.want) {
+				t.Errorf("%s %s %v: got %v, wanted %v", tc.path, tc.op, tc.value, found, tc.want)
+			}
+		}
+		for _, bad := range [][3]string{
+			{"Attrs", "$.color') OR 1=1 --", "="},
+			{"Attrs", "color", "="},
+			{"Attrs", "$.color", "; DROP TABLE x"},
+			{"Attrs`", "$.color", "="},
+		} {
+			_, _, err := WhereJSON(bad[0], bad[1], bad[2], "red")
+			yeserr(t, err)
+		}
+	})
+}
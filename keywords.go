@@ -0,0 +1,54 @@
+package sqly
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// sqlKeywords are the keywords of SQLite, from https://www.sqlite.org/lang_keywords.html, which include the reserved words of the SQL standard
+	// that other dialects don't allow as unquoted identifiers.
+	sqlKeywords = map[string]bool{}
+)
+
+func init() {
+	for _, keyword := range strings.Fields(`
+		ABORT ACTION ADD AFTER ALL ALTER ALWAYS ANALYZE AND AS ASC ATTACH AUTOINCREMENT BEFORE BEGIN BETWEEN BY CASCADE CASE CAST CHECK
+		COLLATE COLUMN COMMIT CONFLICT CONSTRAINT CREATE CROSS CURRENT CURRENT_DATE CURRENT_TIME CURRENT_TIMESTAMP DATABASE DEFAULT
+		DEFERRABLE DEFERRED DELETE DESC DETACH DISTINCT DO DROP EACH ELSE END ESCAPE EXCEPT EXCLUDE EXCLUSIVE EXISTS EXPLAIN FAIL FILTER
+		FIRST FOLLOWING FOR FOREIGN FROM FULL GENERATED GLOB GROUP GROUPS HAVING IF IGNORE IMMEDIATE IN INDEX INDEXED INITIALLY INNER
+		INSERT INSTEAD INTERSECT INTO IS ISNULL JOIN KEY LAST LEFT LIKE LIMIT MATCH MATERIALIZED NATURAL NO NOT NOTHING NOTNULL NULL
+		NULLS OF OFFSET ON OR ORDER OTHERS OUTER OVER PARTITION PLAN PRAGMA PRECEDING PRIMARY QUERY RAISE RANGE RECURSIVE REFERENCES
+		REGEXP REINDEX RELEASE RENAME REPLACE RESTRICT RETURNING RIGHT ROLLBACK ROW ROWS SAVEPOINT SELECT SET TABLE TEMP TEMPORARY THEN
+		TIES TO TRANSACTION TRIGGER UNBOUNDED UNION UNIQUE UPDATE USING VACUUM VALUES VIEW VIRTUAL WHEN WHERE WINDOW WITH WITHOUT`) {
+		sqlKeywords[keyword] = true
+	}
+}
+
+// WithStrictIdentifiers makes planning a struct type fail if its table or any of its columns are named like SQL keywords, like `Order` or `Group`.
+// sqly quotes all the identifiers it generates, so keyword identifiers work with SQLite, but they need quoting in every hand written query
+// and may not work in other dialects.
+func WithStrictIdentifiers() Option {
+	return func(db *DB) error {
+		db.strictIdentifiers = true
+		return nil
+	}
+}
+
+// keywordProblems returns the table and column names of meta that are SQL keywords.
+func (meta *tableMeta) keywordProblems() []error {
+	problems := []error{}
+	schema, table := splitTable(meta.table)
+	for _, name := range []string{schema, table} {
+		if sqlKeywords[strings.ToUpper(name)] {
+			problems = append(problems, errors.Errorf("table %q of %v is the SQL keyword %q", meta.table, meta.typ, strings.ToUpper(name)))
+		}
+	}
+	for _, field := range meta.fields {
+		if sqlKeywords[strings.ToUpper(field.col)] {
+			problems = append(problems, errors.Errorf("col %q of %v is the SQL keyword %q", field.col, meta.typ, strings.ToUpper(field.col)))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,67 @@
+package sqly
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type keywordTestStruct struct {
+	Id     int `sqly:"pkey"`
+	Order  int `sqly:"index"`
+	Group  string
+	Select bool
+}
+
+type whereTestStruct struct {
+	Id int `sqly:"pkey"`
+}
+
+func (whereTestStruct) TableName() string {
+	return "Where"
+}
+
+func TestKeywordIdentifiers(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, keywordTestStruct{}))
+		noerr(t, db.CreateTableIfNotExists(ctx, keywordTestStruct{}))
+		noerr(t, db.CreateTableIfNotExists(ctx, whereTestStruct{}))
+		noerr(t, db.Upsert(ctx, &keywordTestStruct{Id: 1, Order: 2, Group: "a"}, false))
+		noerr(t, db.UpsertAll(ctx, []*keywordTestStruct{{Id: 2, Order: 3, Group: "b", Select: true}}, false))
+		created, err := GetOrCreate(ctx, db, &keywordTestStruct{Id: 3, Order: 4})
+		noerr(t, err)
+		if !created {
+			t.Errorf("got an existing row, wanted one to be created")
+		}
+		found, err := SelectByExample(ctx, db, keywordTestStruct{Order: 3})
+		noerr(t, err)
+		if want := []keywordTestStruct{{Id: 2, Order: 3, Group: "b", Select: true}}; !reflect.DeepEqual(found, want) {
+			t.Errorf("got %+v, wanted %+v", found, want)
+		}
+		refreshed := &keywordTestStruct{Id: 1}
+		noerr(t, db.Refresh(ctx, refreshed))
+		if refreshed.Group != "a" {
+			t.Errorf("got %+v, wanted Group a", refreshed)
+		}
+		noerr(t, db.Delete(ctx, refreshed))
+		noerr(t, db.Upsert(ctx, &whereTestStruct{Id: 1}, true))
+		stats, err := db.TableStats(ctx, keywordTestStruct{}, whereTestStruct{})
+		noerr(t, err)
+		if stats[0].Rows != 2 || stats[1].Rows != 1 {
+			t.Errorf("got %+v, wanted 2 and 1 rows", stats)
+		}
+	})
+	withDB(t, func(db *DB) {
+		err := db.CreateTableIfNotExists(ctx, keywordTestStruct{})
+		for _, want := range []string{`col "Order" of sqly.keywordTestStruct is the SQL keyword "ORDER"`, `"GROUP"`, `"SELECT"`} {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("got %v, wanted it to contain %q", err, want)
+			}
+		}
+		err = db.CreateTableIfNotExists(ctx, whereTestStruct{})
+		if err == nil || !strings.Contains(err.Error(), `table "Where" of sqly.whereTestStruct is the SQL keyword "WHERE"`) {
+			t.Errorf("got %v, wanted an error about the table name", err)
+		}
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+	}, WithStrictIdentifiers())
+}
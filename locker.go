@@ -0,0 +1,162 @@
+package sqly
+
+import (
+	"context"
+	"sync"
+)
+
+type locker interface {
+	Lock()
+	TryLock() bool
+	Unlock()
+	RLock()
+	TryRLock() bool
+	RUnlock()
+}
+
+// acquireContext calls lock, and returns an error matching ErrLockTimeout if ctx is done before it returns.
+// A lock acquired after giving up is released by unlock right away.
+// Contexts that can't be done just call lock, so they behave exactly like before.
+func acquireContext(ctx context.Context, try func() bool, lock func(), unlock func()) error {
+	if ctx.Done() == nil {
+		lock()
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return withStack(&classifiedError{error: err, sentinel: ErrLockTimeout})
+	}
+	if try() {
+		return nil
+	}
+	locked := make(chan struct{})
+	go func() {
+		lock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		go func() {
+			<-locked
+			unlock()
+		}()
+		return withStack(&classifiedError{error: ctx.Err(), sentinel: ErrLockTimeout})
+	}
+}
+
+// lockContext acquires the write lock unless ctx is done first.
+func (db *DB) lockContext(ctx context.Context) error {
+	return acquireContext(ctx, db.locker.TryLock, db.locker.Lock, db.locker.Unlock)
+}
+
+// rlockContext acquires the read lock unless ctx is done first.
+func (db *DB) rlockContext(ctx context.Context) error {
+	return acquireContext(ctx, db.locker.TryRLock, db.locker.RLock, db.locker.RUnlock)
+}
+
+type noopLocker struct{}
+
+func (noopLocker) Lock() {}
+
+func (noopLocker) TryLock() bool { return true }
+
+func (noopLocker) Unlock() {}
+
+func (noopLocker) RLock() {}
+
+func (noopLocker) TryRLock() bool { return true }
+
+func (noopLocker) RUnlock() {}
+
+type fifoWaiter struct {
+	write bool
+	ready chan struct{}
+}
+
+// fifoLocker is a readers/writer lock granting the lock in arrival order, where consecutive readers share the lock.
+type fifoLocker struct {
+	mutex   sync.Mutex
+	readers int
+	writing bool
+	queue   []*fifoWaiter
+}
+
+func (f *fifoLocker) canGrant(write bool) bool {
+	if write {
+		return !f.writing && f.readers == 0
+	}
+	return !f.writing
+}
+
+func (f *fifoLocker) grant(write bool) {
+	if write {
+		f.writing = true
+	} else {
+		f.readers++
+	}
+}
+
+func (f *fifoLocker) lock(write bool) {
+	f.mutex.Lock()
+	if len(f.queue) == 0 && f.canGrant(write) {
+		f.grant(write)
+		f.mutex.Unlock()
+		return
+	}
+	waiter := &fifoWaiter{write: write, ready: make(chan struct{})}
+	f.queue = append(f.queue, waiter)
+	f.mutex.Unlock()
+	<-waiter.ready
+}
+
+func (f *fifoLocker) tryLock(write bool) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(f.queue) == 0 && f.canGrant(write) {
+		f.grant(write)
+		return true
+	}
+	return false
+}
+
+func (f *fifoLocker) unlock(write bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if write {
+		f.writing = false
+	} else {
+		f.readers--
+	}
+	for len(f.queue) > 0 && f.canGrant(f.queue[0].write) {
+		waiter := f.queue[0]
+		f.queue[0] = nil
+		f.queue = f.queue[1:]
+		f.grant(waiter.write)
+		close(waiter.ready)
+	}
+}
+
+func (f *fifoLocker) Lock() {
+	f.lock(true)
+}
+
+func (f *fifoLocker) TryLock() bool {
+	return f.tryLock(true)
+}
+
+func (f *fifoLocker) Unlock() {
+	f.unlock(true)
+}
+
+func (f *fifoLocker) RLock() {
+	f.lock(false)
+}
+
+func (f *fifoLocker) TryRLock() bool {
+	return f.tryLock(false)
+}
+
+func (f *fifoLocker) RUnlock() {
+	f.unlock(false)
+}
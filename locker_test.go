@@ -0,0 +1,236 @@
+package sqly
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"modernc.org/sqlite"
+)
+
+func init() {
+	sql.Register("fakepostgres", &sqlite.Driver{})
+	sqlx.BindDriver("fakepostgres", sqlx.DOLLAR)
+}
+
+func writesOverlap(t *testing.T, db *DB) bool {
+	t.Helper()
+	firstStarted := make(chan struct{})
+	overlapped := make(chan bool, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- db.Write(ctx, func(tx *Tx) error {
+			close(firstStarted)
+			select {
+			case <-overlapped:
+				overlapped <- true
+			case <-time.After(100 * time.Millisecond):
+				overlapped <- false
+			}
+			return nil
+		})
+	}()
+	<-firstStarted
+	secondDone := make(chan error, 1)
+	go func() {
+		secondDone <- db.Write(ctx, func(tx *Tx) error {
+			select {
+			case overlapped <- true:
+			default:
+			}
+			return nil
+		})
+	}()
+	noerr(t, <-done)
+	noerr(t, <-secondDone)
+	return <-overlapped
+}
+
+func TestDriverLockingDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	for _, tc := range []struct {
+		driverName string
+		opts       []Option
+		wantLocked bool
+	}{
+		{driverName: "sqlite", wantLocked: true},
+		{driverName: "sqlite", opts: []Option{WithFIFOLocking()}, wantLocked: true},
+		{driverName: "sqlite", opts: []Option{WithLocking(false)}, wantLocked: false},
+		{driverName: "fakepostgres", wantLocked: false},
+		{driverName: "fakepostgres", opts: []Option{WithLocking(true)}, wantLocked: true},
+	} {
+		db, err := Open(tc.driverName, path, tc.opts...)
+		noerr(t, err)
+		if db.DriverName() != tc.driverName {
+			t.Errorf("got driver name %q, wanted %q", db.DriverName(), tc.driverName)
+		}
+		if overlapped := writesOverlap(t, db); overlapped == tc.wantLocked {
+			t.Errorf("%v with %v options: got overlapping writes %v, wanted locking %v", tc.driverName, len(tc.opts), overlapped, tc.wantLocked)
+		}
+		db.Close()
+	}
+}
+
+func TestFIFOLockerOrder(t *testing.T) {
+	l := &fifoLocker{}
+	l.RLock()
+	writerDone := make(chan struct{})
+	go func() {
+		l.Lock()
+		time.Sleep(10 * time.Millisecond)
+		close(writerDone)
+		l.Unlock()
+	}()
+	for {
+		l.mutex.Lock()
+		queued := len(l.queue)
+		l.mutex.Unlock()
+		if queued == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	readerDone := make(chan struct{})
+	go func() {
+		l.RLock()
+		select {
+		case <-writerDone:
+		default:
+			t.Errorf("reader arriving after a queued writer got the lock before it")
+		}
+		l.RUnlock()
+		close(readerDone)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	l.RUnlock()
+	<-readerDone
+}
+
+func TestFIFOLockerExclusion(t *testing.T) {
+	l := &fifoLocker{}
+	readers := int32(0)
+	writers := int32(0)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(write bool) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if write {
+					l.Lock()
+					if atomic.AddInt32(&writers, 1) != 1 || atomic.LoadInt32(&readers) != 0 {
+						t.Errorf("writer not exclusive")
+					}
+					atomic.AddInt32(&writers, -1)
+					l.Unlock()
+				} else {
+					l.RLock()
+					atomic.AddInt32(&readers, 1)
+					if atomic.LoadInt32(&writers) != 0 {
+						t.Errorf("reader concurrent with writer")
+					}
+					atomic.AddInt32(&readers, -1)
+					l.RUnlock()
+				}
+			}
+		}(i%4 == 0)
+	}
+	wg.Wait()
+}
+
+func writeLatencyP99(t *testing.T, opts ...Option) time.Duration {
+	t.Helper()
+	latencies := []time.Duration{}
+	withFileDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		stop := make(chan struct{})
+		wg := sync.WaitGroup{}
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					if err := db.Read(ctx, func(tx *Tx) error {
+						count := 0
+						return tx.Get(&count, "SELECT COUNT(*) FROM sharedTestStruct")
+					}); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}()
+		}
+		for i := 0; i < 50; i++ {
+			start := time.Now()
+			noerr(t, db.Write(ctx, func(tx *Tx) error {
+				return tx.Upsert(ctx, &sharedTestStruct{Id: i + 1000}, false)
+			}))
+			latencies = append(latencies, time.Since(start))
+		}
+		close(stop)
+		wg.Wait()
+	}, opts...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies[len(latencies)*99/100]
+}
+
+func TestWriteLatencyUnderReadLoad(t *testing.T) {
+	if testing.Short() {
+		t.Skip("stress test")
+	}
+	t.Logf("RWMutex write p99: %v", writeLatencyP99(t))
+	t.Logf("FIFO write p99: %v", writeLatencyP99(t, WithFIFOLocking()))
+}
+
+func TestLockTimeout(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithFIFOLocking()}} {
+		withFileDB(t, func(db *DB) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			done := make(chan error, 1)
+			go func() {
+				done <- db.Write(ctx, func(tx *Tx) error {
+					close(started)
+					<-release
+					return nil
+				})
+			}()
+			<-started
+			for _, do := range []func(context.Context, func(*Tx) error) error{db.Write, db.Read} {
+				short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+				start := time.Now()
+				err := do(short, func(tx *Tx) error {
+					t.Errorf("ran without the lock")
+					return nil
+				})
+				cancel()
+				if !errors.Is(err, ErrLockTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("got %v, wanted ErrLockTimeout", err)
+				}
+				if elapsed := time.Since(start); elapsed > time.Second {
+					t.Errorf("took %v, wanted to fail fast", elapsed)
+				}
+			}
+			waiting := make(chan error, 1)
+			go func() {
+				waiting <- db.Write(ctx, func(tx *Tx) error { return nil })
+			}()
+			close(release)
+			noerr(t, <-done)
+			noerr(t, <-waiting)
+			noerr(t, db.Read(ctx, func(tx *Tx) error { return nil }))
+		}, opts...)
+	}
+}
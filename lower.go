@@ -0,0 +1,14 @@
+package sqly
+
+import (
+	"reflect"
+	"strings"
+)
+
+// normalized returns the lowercased and trimmed copy of the string fieldVal of a field tagged `sqly:"lower"`.
+// Such fields are stored, and compared by SelectByExample and Refresh, normalized, so that a unique index on them rejects natural keys like emails
+// and usernames that only differ in case, while the struct keeps the value it was given.
+// Raw SQL isn't normalized, so queries using these columns should lowercase their arguments, or the field should also be tagged `sqly:"collate=NOCASE"`.
+func normalized(fieldVal reflect.Value) reflect.Value {
+	return reflect.ValueOf(strings.ToLower(strings.TrimSpace(fieldVal.String()))).Convert(fieldVal.Type())
+}
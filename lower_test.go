@@ -0,0 +1,53 @@
+package sqly
+
+import (
+	"testing"
+)
+
+type loweredTestStruct struct {
+	Id    int64  `sqly:"pkey"`
+	Email string `sqly:"unique,lower"`
+}
+
+type nocaseTestStruct struct {
+	Id    int64  `sqly:"pkey"`
+	Email string `sqly:"unique,collate=NOCASE"`
+}
+
+type badLoweredTestStruct struct {
+	Id    int64 `sqly:"pkey"`
+	Count int   `sqly:"lower"`
+}
+
+func TestLower(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, loweredTestStruct{}))
+		given := &loweredTestStruct{Id: 1, Email: " Foo@Example.com "}
+		noerr(t, db.Upsert(ctx, given, false))
+		if given.Email != " Foo@Example.com " {
+			t.Errorf("got %q, wanted the struct to keep its value", given.Email)
+		}
+		stored := ""
+		noerr(t, db.Get(&stored, "SELECT Email FROM loweredTestStruct WHERE Id = 1"))
+		if stored != "foo@example.com" {
+			t.Errorf("got %q, wanted it normalized", stored)
+		}
+		yeserr(t, db.Upsert(ctx, &loweredTestStruct{Id: 2, Email: "FOO@example.COM"}, false))
+		yeserr(t, db.UpsertAll(ctx, []any{&loweredTestStruct{Id: 3, Email: "foo@EXAMPLE.com"}}, false))
+		found, err := SelectByExample(ctx, db, loweredTestStruct{Email: "FOO@EXAMPLE.COM"})
+		noerr(t, err)
+		if len(found) != 1 || found[0].Id != 1 {
+			t.Errorf("got %+v, wanted the normalized row", found)
+		}
+
+		noerr(t, db.CreateTableIfNotExists(ctx, nocaseTestStruct{}))
+		noerr(t, db.Upsert(ctx, &nocaseTestStruct{Id: 1, Email: "Foo@Example.com"}, false))
+		yeserr(t, db.Upsert(ctx, &nocaseTestStruct{Id: 2, Email: "foo@example.com"}, false))
+		noerr(t, db.Get(&stored, "SELECT Email FROM nocaseTestStruct WHERE Email = ?", "FOO@EXAMPLE.COM"))
+		if stored != "Foo@Example.com" {
+			t.Errorf("got %q, wanted NOCASE to keep the stored value", stored)
+		}
+
+		yeserr(t, db.CreateTableIfNotExists(ctx, badLoweredTestStruct{}))
+	})
+}
@@ -0,0 +1,81 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+func (db *DB) Delete(ctx context.Context, structPointer any) error {
+	return db.mapError(Delete(ctx, db, structPointer))
+}
+
+func (tx *Tx) Delete(ctx context.Context, structPointer any) error {
+	return tx.db.mapError(Delete(ctx, tx, structPointer))
+}
+
+// Delete deletes the row with the pkey of structPointer, and does nothing if there is no such row.
+func Delete(ctx context.Context, execer sqlx.ExecerContext, structPointer any) error {
+	val, meta, err := structPointerMeta(execer, structPointer)
+	if err != nil {
+		return err
+	}
+	condition, params, err := meta.pkeyCondition(val)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", quoteTable(meta.table), condition)
+	if _, err := execer.ExecContext(labeled(ctx, execer, "delete", meta.typ.Name()), rebind(execer, query), params...); err != nil {
+		return viewError(ctx, execer, meta, withStack(err))
+	}
+	return nil
+}
+
+func (db *DB) UpsertMany(ctx context.Context, overwrite bool, structPointers ...any) error {
+	return UpsertMany(ctx, db, overwrite, structPointers...)
+}
+
+func (tx *Tx) UpsertMany(ctx context.Context, overwrite bool, structPointers ...any) error {
+	return tx.db.mapError(UpsertMany(ctx, tx, overwrite, structPointers...))
+}
+
+// UpsertMany upserts structPointers, which may be of different types, one by one in order.
+// When execer is a *DB it's done in one Write transaction, so the first failure rolls them all back.
+// Returns the error of the first failing element, annotated with its index and type.
+func UpsertMany(ctx context.Context, execer sqlx.ExecerContext, overwrite bool, structPointers ...any) error {
+	return eachInWrite(ctx, execer, structPointers, func(execer sqlx.ExecerContext, structPointer any) error {
+		return Upsert(ctx, execer, structPointer, overwrite)
+	})
+}
+
+func (db *DB) DeleteMany(ctx context.Context, structPointers ...any) error {
+	return DeleteMany(ctx, db, structPointers...)
+}
+
+func (tx *Tx) DeleteMany(ctx context.Context, structPointers ...any) error {
+	return tx.db.mapError(DeleteMany(ctx, tx, structPointers...))
+}
+
+// DeleteMany deletes structPointers, which may be of different types, one by one in order, like UpsertMany.
+func DeleteMany(ctx context.Context, execer sqlx.ExecerContext, structPointers ...any) error {
+	return eachInWrite(ctx, execer, structPointers, func(execer sqlx.ExecerContext, structPointer any) error {
+		return Delete(ctx, execer, structPointer)
+	})
+}
+
+// eachInWrite calls f with each of structPointers, in a Write transaction if execer is a *DB, and stops at the first error.
+func eachInWrite(ctx context.Context, execer sqlx.ExecerContext, structPointers []any, f func(sqlx.ExecerContext, any) error) error {
+	if db, ok := execer.(*DB); ok {
+		return db.Write(ctx, func(tx *Tx) error {
+			return eachInWrite(ctx, tx, structPointers, f)
+		})
+	}
+	for index, structPointer := range structPointers {
+		if err := f(execer, structPointer); err != nil {
+			return errors.Wrapf(err, "element %v (%T)", index, structPointer)
+		}
+	}
+	return nil
+}
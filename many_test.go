@@ -0,0 +1,56 @@
+package sqly
+
+import (
+	"strings"
+	"testing"
+)
+
+type manyTestOwner struct {
+	Id   int `sqly:"pkey"`
+	Name string
+}
+
+type manyTestPet struct {
+	Id      int `sqly:"pkey"`
+	OwnerId int
+	Name    string `sqly:"unique"`
+}
+
+func TestUpsertManyDeleteMany(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, manyTestOwner{}))
+		noerr(t, db.CreateTableIfNotExists(ctx, manyTestPet{}))
+		noerr(t, db.UpsertMany(ctx, false, &manyTestOwner{Id: 1, Name: "a"}, &manyTestPet{Id: 1, OwnerId: 1, Name: "rex"}))
+
+		err := db.UpsertMany(ctx, false, &manyTestOwner{Id: 2, Name: "b"}, &manyTestPet{Id: 2, OwnerId: 2, Name: "rex"}, &manyTestOwner{Id: 3, Name: "c"})
+		if err == nil || !strings.Contains(err.Error(), "element 1 (*sqly.manyTestPet)") {
+			t.Errorf("got %v, wanted an error naming the failing element", err)
+		}
+		if count := countRows(t, db, "manyTestOwner"); count != 1 {
+			t.Errorf("got %v owners, wanted the failed UpsertMany rolled back", count)
+		}
+
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			if err := tx.UpsertMany(ctx, false, &manyTestOwner{Id: 2, Name: "b"}, &manyTestPet{Id: 2, OwnerId: 2, Name: "fido"}); err != nil {
+				return err
+			}
+			return tx.DeleteMany(ctx, &manyTestPet{Id: 1}, &manyTestOwner{Id: 1})
+		}))
+		owners, err := SelectByExample(ctx, db, manyTestOwner{})
+		noerr(t, err)
+		if len(owners) != 1 || owners[0].Id != 2 {
+			t.Errorf("got %+v, wanted only owner 2", owners)
+		}
+		if count := countRows(t, db, "manyTestPet"); count != 1 {
+			t.Errorf("got %v pets, wanted 1", count)
+		}
+		noerr(t, db.DeleteMany(ctx, &manyTestPet{Id: 100}))
+		err = db.DeleteMany(ctx, &manyTestPet{Id: 2}, manyTestOwner{Id: 2})
+		if err == nil || !strings.Contains(err.Error(), "element 1 (sqly.manyTestOwner)") {
+			t.Errorf("got %v, wanted an error naming the non pointer element", err)
+		}
+		if count := countRows(t, db, "manyTestPet"); count != 1 {
+			t.Errorf("got %v pets, wanted the failed DeleteMany rolled back", count)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package sqly
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// normalizeValue converts a value scanned from a column with the declared type declType to a consistent Go type.
+// BLOB columns produce []byte, TEXT columns string, BOOL and BOOLEAN columns bool, and other values keep the type of their storage class,
+// int64, float64, string, []byte or nil.
+func normalizeValue(value any, declType string) any {
+	declType = strings.ToUpper(declType)
+	switch typed := value.(type) {
+	case []byte:
+		if strings.Contains(declType, "BLOB") || declType == "" {
+			return append([]byte{}, typed...)
+		}
+		return string(typed)
+	case string:
+		if strings.Contains(declType, "BLOB") {
+			return []byte(typed)
+		}
+	case int64:
+		if declType == "BOOL" || declType == "BOOLEAN" {
+			return typed != 0
+		}
+	}
+	return value
+}
+
+// EachMap runs query and calls fn with each resulting row as a map from column name to normalized value, without loading all rows at once.
+// Slice args are expanded like for GetSQL, and if querier is a *DB the query is run in a Read transaction.
+// Iteration stops at the first error returned by fn, which is returned.
+func EachMap(ctx context.Context, querier sqlx.QueryerContext, query string, fn func(map[string]any) error, args ...any) error {
+	return readIn(ctx, querier, func(q sqlx.QueryerContext) error {
+		query, args, err := expand(q, query, args)
+		if err != nil {
+			return err
+		}
+		rows, err := q.QueryxContext(labeled(ctx, q, "eachMap", ""), query, args...)
+		if err != nil {
+			return withStack(err)
+		}
+		defer rows.Close()
+		colTypes, err := rows.ColumnTypes()
+		if err != nil {
+			return withStack(err)
+		}
+		values := make([]any, len(colTypes))
+		dests := make([]any, len(colTypes))
+		for index := range values {
+			dests[index] = &values[index]
+		}
+		for rows.Next() {
+			if err := rows.Scan(dests...); err != nil {
+				return withStack(err)
+			}
+			row := make(map[string]any, len(colTypes))
+			for index, colType := range colTypes {
+				row[colType.Name()] = normalizeValue(values[index], colType.DatabaseTypeName())
+			}
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return withStack(rows.Err())
+	})
+}
+
+func (db *DB) EachMap(ctx context.Context, query string, fn func(map[string]any) error, args ...any) error {
+	return EachMap(ctx, db, query, fn, args...)
+}
+
+func (tx *Tx) EachMap(ctx context.Context, query string, fn func(map[string]any) error, args ...any) error {
+	return EachMap(ctx, tx, query, fn, args...)
+}
@@ -0,0 +1,39 @@
+package sqly
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestEachMap(t *testing.T) {
+	withDB(t, func(db *DB) {
+		_, err := db.Exec("CREATE TABLE report (Name TEXT, Count INTEGER, Ratio REAL, Data BLOB, Flag BOOLEAN, Other)")
+		noerr(t, err)
+		_, err = db.Exec("INSERT INTO report VALUES ('a', 1, 0.5, x'0102', 1, NULL), ('b', 2, 1.5, 'text', 0, 'x')")
+		noerr(t, err)
+		rows := []map[string]any{}
+		noerr(t, db.EachMap(ctx, "SELECT * FROM report WHERE Count > ? ORDER BY Name", func(row map[string]any) error {
+			rows = append(rows, row)
+			return nil
+		}, 0))
+		want := []map[string]any{
+			{"Name": "a", "Count": int64(1), "Ratio": 0.5, "Data": []byte{1, 2}, "Flag": true, "Other": nil},
+			{"Name": "b", "Count": int64(2), "Ratio": 1.5, "Data": []byte("text"), "Flag": false, "Other": "x"},
+		}
+		if !reflect.DeepEqual(rows, want) {
+			t.Errorf("got %#v, wanted %#v", rows, want)
+		}
+		stop := errors.New("stop")
+		calls := 0
+		err = db.EachMap(ctx, "SELECT * FROM report", func(row map[string]any) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("got %v after %v calls, wanted the error of the first call", err, calls)
+		}
+		yeserr(t, db.EachMap(ctx, "SELECT * FROM missing", func(row map[string]any) error { return nil }))
+	})
+}
@@ -0,0 +1,757 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// NameMapper decides the table and column names used for structs and their fields.
+// ColumnName receives the Go field name, since it is also installed as the sqlx mapper used when scanning.
+type NameMapper interface {
+	TableName(typ reflect.Type) string
+	ColumnName(fieldName string) string
+}
+
+type identityMapper struct{}
+
+func (identityMapper) TableName(typ reflect.Type) string {
+	return typ.Name()
+}
+
+func (identityMapper) ColumnName(fieldName string) string {
+	return fieldName
+}
+
+// Tabler lets a struct override the table name given to it by the NameMapper.
+type Tabler interface {
+	TableName() string
+}
+
+// TablePrefixSkipper lets a Tabler opt out of the TablePrefix of the DB.
+type TablePrefixSkipper interface {
+	SkipTablePrefix() bool
+}
+
+var (
+	identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	sqlTypeRegexp    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_ ]*$`)
+)
+
+func validIdentifier(s string) error {
+	if !identifierRegexp.MatchString(s) {
+		return errors.Errorf("%q is not a valid identifier", s)
+	}
+	return nil
+}
+
+// Index is an index declared by an Indexer, on either the fields Columns or the expression Expr, like `lower(Name)`.
+// Name defaults to the comma separated Columns, just like for the indices created from field tags, and is required for expression indices.
+// A non empty Where makes it a partial index, only covering the rows matching the condition.
+type Index struct {
+	Name    string
+	Columns []string
+	Expr    string
+	Unique  bool
+	Where   string
+}
+
+// ExpressionIndex is the name Index had when it could only describe expression indices.
+type ExpressionIndex = Index
+
+// StrictTabler lets a struct opt in to being created as a STRICT table, where SQLite enforces the declared column types.
+type StrictTabler interface {
+	SQLYStrict() bool
+}
+
+// WithoutRowIDer lets a struct opt in to being created as a WITHOUT ROWID table, which must have a PRIMARY KEY that isn't autoinc.
+// WITHOUT ROWID tables may tag multiple fields pkey to get a composite PRIMARY KEY.
+type WithoutRowIDer interface {
+	SQLYWithoutRowID() bool
+}
+
+var (
+	strictSQLTypes = map[string]bool{
+		"INT":     true,
+		"INTEGER": true,
+		"REAL":    true,
+		"TEXT":    true,
+		"BLOB":    true,
+		"ANY":     true,
+	}
+)
+
+// Indexer lets a struct declare indices that can't be expressed using field tags.
+// The indices are merged with those from the field tags, and an index with the same name as another must have the same definition.
+type Indexer interface {
+	SQLYIndices() []Index
+}
+
+// Trigger is a trigger on the table of a struct, created as "table.Name".
+// Timing is BEFORE, AFTER or INSTEAD OF, Event is INSERT, UPDATE, UPDATE OF columns or DELETE, and Body is the statements run for each row.
+// A non empty When is a condition on OLD and NEW that the row must match for Body to run.
+type Trigger struct {
+	Name   string
+	Timing string
+	Event  string
+	Body   string
+	When   string
+}
+
+// Triggerer lets a struct declare triggers that are created along with its table.
+type Triggerer interface {
+	SQLYTriggers() []Trigger
+}
+
+// TableChecker lets a struct declare CHECK constraints spanning several columns, like "StartAt < EndAt", in which field names are replaced by
+// their quoted column names. SQLite can't add constraints to existing tables, so CreateTableIfNotExists fails if the table exists without them.
+type TableChecker interface {
+	SQLYTableChecks() []string
+}
+
+// ExtraDDLer lets a struct declare statements, like triggers or partial indices that Triggerer or tags can't express,
+// that CreateTableIfNotExists runs on the driver named dialect after the table, its indices and its triggers exist.
+// The statements are run every time the table is ensured, so they must be idempotent, like CREATE TRIGGER IF NOT EXISTS.
+type ExtraDDLer interface {
+	SQLYExtraDDL(dialect string) []string
+}
+
+// AfterCreater lets a struct run its own setup, like seeding reference data or running ANALYZE, when CreateTableIfNotExists creates its table.
+// AfterCreate runs after the table, its indices, its triggers and its extra DDL exist, using the same transaction, so an error rolls back the whole creation.
+type AfterCreater interface {
+	AfterCreate(ctx context.Context, execer sqlx.ExecerContext) error
+}
+
+var (
+	triggerTimings = map[string]bool{
+		"BEFORE":     true,
+		"AFTER":      true,
+		"INSTEAD OF": true,
+	}
+	triggerEventRegexp = regexp.MustCompile(`^(?i)(INSERT|DELETE|UPDATE|UPDATE OF [A-Za-z_][A-Za-z0-9_]*( *, *[A-Za-z_][A-Za-z0-9_]*)*)$`)
+)
+
+var (
+	builtinCollations = map[string]bool{
+		"BINARY": true,
+		"NOCASE": true,
+		"RTRIM":  true,
+	}
+)
+
+func (m *metaCache) collation(name string) (string, error) {
+	upper := strings.ToUpper(name)
+	if !builtinCollations[upper] && !m.collations[upper] {
+		return "", errors.Errorf("no collation %q registered", name)
+	}
+	return upper, nil
+}
+
+type fieldMeta struct {
+	index   int
+	name    string
+	col     string
+	typ     reflect.Type
+	sqlType string
+	pkey    bool
+	autoinc bool
+	collate string
+	hashed  bool
+	lower   bool
+	// renamed is set when the col of the field was chosen by a col tag instead of the NameMapper.
+	renamed bool
+
+	omitEmpty bool
+	// omitBit identifies omitted omitempty fields in insertKey.omitted.
+	omitBit uint64
+
+	transformer Transformer
+}
+
+type tableMeta struct {
+	typ      reflect.Type
+	table    string
+	fields   []*fieldMeta
+	pkey     *fieldMeta
+	pkeys    []*fieldMeta
+	indices  []IndexSpec
+	triggers []Trigger
+	checks   []string
+	strict   bool
+
+	withoutRowID bool
+	omitsEmpty   bool
+
+	unknownTags []string
+	// withTags are the uniqueWith and indexWith tags, whose fields are checked when all fields are planned.
+	withTags []withTag
+
+	contentHash *fieldMeta
+	newHash     func() hash.Hash
+	updated     *fieldMeta
+	now         func() time.Time
+
+	insertSQLsLock sync.RWMutex
+	insertSQLs     map[insertKey]string
+}
+
+type withTag struct {
+	field  string
+	tag    string
+	fields []string
+	// index is the position of the declared index in tableMeta.indices.
+	index int
+}
+
+type metaCache struct {
+	mapper       NameMapper
+	naming       NamingStrategy
+	prefix       string
+	strictTables bool
+	strictTags   bool
+	fingerprints bool
+	contentHash  func() hash.Hash
+	clock        func() time.Time
+	transformers map[string]Transformer
+	collations   map[string]bool
+	metas        sync.Map
+
+	strictIdentifiers bool
+}
+
+var (
+	defaultMetas = &metaCache{mapper: identityMapper{}, naming: defaultNaming{}}
+)
+
+type metaCacher interface {
+	metaCache() *metaCache
+}
+
+func metasFor(x any) *metaCache {
+	if cacher, ok := x.(metaCacher); ok {
+		return cacher.metaCache()
+	}
+	return defaultMetas
+}
+
+func metaOf(x any, prototype any) (*tableMeta, error) {
+	val := reflect.ValueOf(prototype)
+	if val.Kind() != reflect.Struct {
+		return nil, errors.Errorf("%v is not a reflect.Struct", prototype)
+	}
+	return metasFor(x).get(val.Type())
+}
+
+func (m *metaCache) get(typ reflect.Type) (*tableMeta, error) {
+	if found, ok := m.metas.Load(typ); ok {
+		return found.(*tableMeta), nil
+	}
+	meta, err := m.plan(typ)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := m.metas.LoadOrStore(typ, meta)
+	return actual.(*tableMeta), nil
+}
+
+func sqlTypeOf(typ reflect.Type) (string, error) {
+	if isBinary(typ) {
+		return "BLOB", nil
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return "TEXT", nil
+	case reflect.Uint:
+		fallthrough
+	case reflect.Uint8:
+		fallthrough
+	case reflect.Uint16:
+		fallthrough
+	case reflect.Uint32:
+		fallthrough
+	case reflect.Uint64:
+		fallthrough
+	case reflect.Int:
+		fallthrough
+	case reflect.Int8:
+		fallthrough
+	case reflect.Int16:
+		fallthrough
+	case reflect.Int32:
+		fallthrough
+	case reflect.Int64:
+		return "INTEGER", nil
+	case reflect.Float32:
+		return "REAL", nil
+	case reflect.Float64:
+		return "REAL", nil
+	case reflect.Bool:
+		return "INTEGER", nil
+	case reflect.Struct:
+		if typ == sqlTimeTextType || typ == bigIntType || typ == bigRatType {
+			return "TEXT", nil
+		}
+		if typ == timeType {
+			return "", errors.Errorf("%v isn't supported, use SQLTime or SQLTimeText", typ)
+		}
+		return "", errors.Errorf("%v isn't of a supported struct type", typ)
+	case reflect.Ptr:
+		return sqlTypeOf(typ.Elem())
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return "BLOB", nil
+		}
+		return "", errors.Errorf("%v isn't of a supported slice type", typ.Elem())
+	case reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return "BLOB", nil
+		}
+		return "", errors.Errorf("%v isn't of a supported array type", typ.Elem())
+	}
+	return "", errors.Errorf("%v isn't of a supported type", typ)
+}
+
+// tableName returns the table of typ, which may be qualified by the schema of an attached database, like "archive.Events".
+// The TablePrefix is added to the table, not the schema.
+func (m *metaCache) tableName(typ reflect.Type) string {
+	prototype := reflect.New(typ).Interface()
+	tabler, ok := prototype.(Tabler)
+	if !ok {
+		return m.prefix + m.mapper.TableName(typ)
+	}
+	if skipper, ok := prototype.(TablePrefixSkipper); ok && skipper.SkipTablePrefix() {
+		return tabler.TableName()
+	}
+	schema, table := splitTable(tabler.TableName())
+	if schema == "" {
+		return m.prefix + table
+	}
+	return schema + "." + m.prefix + table
+}
+
+// validTableName returns an error if table is qualified by a schema, but isn't made of exactly two valid identifiers separated by a dot.
+func validTableName(table string) error {
+	if !strings.Contains(table, ".") {
+		return nil
+	}
+	parts := strings.Split(table, ".")
+	if len(parts) != 2 {
+		return errors.Errorf("qualified table name %q has more than one dot", table)
+	}
+	for _, part := range parts {
+		if err := validIdentifier(part); err != nil {
+			return errors.Wrapf(err, "invalid qualified table name %q", table)
+		}
+	}
+	return nil
+}
+
+// columnNames returns the cols of the named fields of meta.
+func (meta *tableMeta) columnNames(fieldNames []string) []string {
+	colsByName := map[string]string{}
+	for _, field := range meta.fields {
+		colsByName[field.name] = field.col
+	}
+	result := make([]string, len(fieldNames))
+	for index, fieldName := range fieldNames {
+		result[index] = colsByName[fieldName]
+	}
+	return result
+}
+
+// plan computes the metadata of typ. The fields are kept in declaration order, which all generated SQL relies on to be deterministic.
+func (m *metaCache) plan(typ reflect.Type) (*tableMeta, error) {
+	meta, problems := m.planProblems(typ)
+	if len(problems) > 0 {
+		return nil, newMultiError(problems)
+	}
+	return meta, nil
+}
+
+// planProblems computes the metadata of typ, and returns every problem found instead of stopping at the first.
+func (m *metaCache) planProblems(typ reflect.Type) (*tableMeta, []error) {
+	if typ.Kind() != reflect.Struct {
+		return nil, []error{errors.Errorf("%v is not a reflect.Struct", typ)}
+	}
+	problems := []error{}
+	meta := &tableMeta{
+		typ:     typ,
+		table:   m.tableName(typ),
+		strict:  m.strictTables,
+		newHash: m.contentHash,
+		now:     m.clock,
+	}
+	if meta.newHash == nil {
+		meta.newHash = defaultContentHash
+	}
+	if meta.now == nil {
+		meta.now = time.Now
+	}
+	if err := validTableName(meta.table); err != nil {
+		problems = append(problems, err)
+	}
+	if strictTabler, ok := reflect.New(typ).Interface().(StrictTabler); ok {
+		meta.strict = strictTabler.SQLYStrict()
+	}
+	if withoutRowIDer, ok := reflect.New(typ).Interface().(WithoutRowIDer); ok {
+		meta.withoutRowID = withoutRowIDer.SQLYWithoutRowID()
+	}
+	fieldsByCol := map[string]string{}
+	omitEmptyFields := 0
+	for fieldIndex := 0; fieldIndex < typ.NumField(); fieldIndex++ {
+		field := typ.Field(fieldIndex)
+		if !field.IsExported() {
+			continue
+		}
+		sqlType, err := sqlTypeOf(field.Type)
+		if err != nil {
+			problems = append(problems, errors.Wrapf(err, "col %q of %v", field.Name, typ))
+			continue
+		}
+		fieldMeta := &fieldMeta{
+			index:   fieldIndex,
+			name:    field.Name,
+			col:     m.mapper.ColumnName(field.Name),
+			typ:     field.Type,
+			sqlType: sqlType,
+		}
+		tags, err := parseTags(field.Tag.Get("sqly"))
+		if err != nil {
+			problems = append(problems, errors.Wrapf(err, "invalid sqly tag %q on %v.%s", field.Tag.Get("sqly"), typ, field.Name))
+			continue
+		}
+		// col goes first, since the other tags use the col of the field.
+		sort.SliceStable(tags, func(i, j int) bool {
+			return tags[i].name == "col" && tags[j].name != "col"
+		})
+		seenTags := map[string]bool{}
+		for _, tag := range tags {
+			if seenTags[tag.name] {
+				problems = append(problems, errors.Errorf("sqly tag %q is repeated on %v.%s", tag.name, typ, field.Name))
+				continue
+			}
+			seenTags[tag.name] = true
+			if err := m.applyTag(meta, fieldMeta, field, tag); err != nil {
+				problems = append(problems, errors.Wrapf(err, "invalid sqly tag %q on %v.%s", tag.text, typ, field.Name))
+			}
+		}
+		if other, found := fieldsByCol[fieldMeta.col]; found {
+			problems = append(problems, errors.Errorf("%v.%s and %v.%s both map to col %q", typ, other, typ, field.Name, fieldMeta.col))
+		}
+		fieldsByCol[fieldMeta.col] = field.Name
+		if fieldMeta.pkey {
+			if meta.pkey == nil {
+				meta.pkey = fieldMeta
+			} else if !meta.withoutRowID {
+				problems = append(problems, errors.Errorf("%v has multiple PRIMARY KEY fields: %q and %q", typ, meta.pkey.name, field.Name))
+			}
+			meta.pkeys = append(meta.pkeys, fieldMeta)
+			if fieldMeta.autoinc && meta.withoutRowID {
+				problems = append(problems, errors.Errorf("col %q can't be autoinc since %v is a WITHOUT ROWID table", field.Name, typ))
+			}
+			if fieldMeta.autoinc && sqlType != "INTEGER" {
+				problems = append(problems, errors.Errorf("col %q can't be autoinc pkey if it's not an INTEGER type", field.Name))
+			}
+		} else if fieldMeta.autoinc {
+			problems = append(problems, errors.Errorf("col %q can't be autoinc if it's not also pkey", field.Name))
+		}
+		if fieldMeta.omitEmpty {
+			if fieldMeta.pkey {
+				problems = append(problems, errors.Errorf("col %q can't be omitempty since it's the pkey", field.Name))
+			} else if omitEmptyFields == 64 {
+				problems = append(problems, errors.Errorf("%v has more than 64 omitempty fields", typ))
+			} else {
+				fieldMeta.omitBit = 1 << omitEmptyFields
+				omitEmptyFields++
+				meta.omitsEmpty = true
+			}
+		}
+		if fieldMeta.collate != "" && fieldMeta.sqlType != "TEXT" {
+			problems = append(problems, errors.Errorf("col %q can't have a collation since it's not a TEXT type", field.Name))
+		}
+		if meta.strict && !strictSQLTypes[fieldMeta.sqlType] {
+			problems = append(problems, errors.Errorf("col %q of STRICT table %v has type %v, which isn't allowed in STRICT tables", field.Name, typ, sqlType))
+		}
+		meta.fields = append(meta.fields, fieldMeta)
+	}
+	if meta.contentHash != nil {
+		problems = append(problems, meta.planContentHash()...)
+	}
+	persisted := map[string]bool{}
+	for _, field := range meta.fields {
+		persisted[field.name] = true
+	}
+	for _, with := range meta.withTags {
+		valid := true
+		for _, name := range with.fields {
+			if !persisted[name] {
+				problems = append(problems, errors.Errorf("invalid sqly tag %q on %v.%s: %q isn't a persisted field of %v", with.tag, typ, with.field, name, typ))
+				valid = false
+			}
+		}
+		if valid {
+			// The named fields may be declared after the tagged one, so their cols are only known now.
+			meta.indices[with.index].Columns = append(meta.indices[with.index].Columns[:1], meta.columnNames(with.fields)...)
+		}
+	}
+	for indexIndex := range meta.indices {
+		index := &meta.indices[indexIndex]
+		index.Table = meta.table
+		index.fullName = m.naming.IndexName(meta.table, index.Columns, index.Unique)
+	}
+	if indexer, ok := reflect.New(typ).Interface().(Indexer); ok {
+		indicesByName := map[string]IndexSpec{}
+		for _, index := range meta.indices {
+			indicesByName[index.IndexName()] = index
+		}
+		for _, index := range indexer.SQLYIndices() {
+			spec, err := m.indexSpec(meta, index)
+			if err != nil {
+				problems = append(problems, errors.Wrapf(err, "invalid index of %v", typ))
+				continue
+			}
+			if existing, found := indicesByName[spec.IndexName()]; found {
+				if !reflect.DeepEqual(existing, spec) {
+					problems = append(problems, errors.Errorf("index %q of %v has conflicting definitions %+v and %+v", spec.IndexName(), typ, existing, spec))
+				}
+				continue
+			}
+			indicesByName[spec.IndexName()] = spec
+			meta.indices = append(meta.indices, spec)
+		}
+	}
+	if triggerer, ok := reflect.New(typ).Interface().(Triggerer); ok {
+		triggerNames := map[string]bool{}
+		for _, trigger := range triggerer.SQLYTriggers() {
+			if err := validIdentifier(trigger.Name); err != nil {
+				problems = append(problems, errors.Wrapf(err, "invalid trigger name for %v", typ))
+				continue
+			}
+			if triggerNames[trigger.Name] {
+				problems = append(problems, errors.Errorf("trigger %q of %v is declared multiple times", trigger.Name, typ))
+				continue
+			}
+			triggerNames[trigger.Name] = true
+			trigger.Timing = strings.ToUpper(strings.Join(strings.Fields(trigger.Timing), " "))
+			if !triggerTimings[trigger.Timing] {
+				problems = append(problems, errors.Errorf("trigger %q of %v has invalid timing %q", trigger.Name, typ, trigger.Timing))
+				continue
+			}
+			if !triggerEventRegexp.MatchString(trigger.Event) {
+				problems = append(problems, errors.Errorf("trigger %q of %v has invalid event %q", trigger.Name, typ, trigger.Event))
+				continue
+			}
+			if strings.TrimSpace(trigger.Body) == "" {
+				problems = append(problems, errors.Errorf("trigger %q of %v has no body", trigger.Name, typ))
+				continue
+			}
+			meta.triggers = append(meta.triggers, trigger)
+		}
+	}
+	if checker, ok := reflect.New(typ).Interface().(TableChecker); ok {
+		for _, check := range checker.SQLYTableChecks() {
+			if strings.TrimSpace(check) == "" {
+				problems = append(problems, errors.Errorf("%v has an empty table check", typ))
+				continue
+			}
+			meta.checks = append(meta.checks, meta.quoteFieldNames(check))
+		}
+	}
+	if meta.withoutRowID && meta.pkey == nil {
+		problems = append(problems, errors.Errorf("%v is a WITHOUT ROWID table but doesn't have a PRIMARY KEY (field tagged `sqly:\"pkey\"`)", typ))
+	}
+	if m.strictTags && len(meta.unknownTags) > 0 {
+		problems = append(problems, errors.Errorf("%v has unknown sqly tags: %s", typ, strings.Join(meta.unknownTags, ", ")))
+	}
+	if m.strictIdentifiers {
+		problems = append(problems, meta.keywordProblems()...)
+	}
+	return meta, problems
+}
+
+// applyTag applies tag to fieldMeta, and the indices of meta, and collects unknown tags in meta.
+// It is the only place tags are recognized, so new tags only have to be added here.
+func (m *metaCache) applyTag(meta *tableMeta, fieldMeta *fieldMeta, field reflect.StructField, tag tag) error {
+	var err error
+	switch tag.name {
+	case "unique", "index", "pkey", "autoinc", "contenthash", "hashed", "omitempty", "lower", "updated":
+		if tag.value != "" || tag.hasArgs {
+			return errors.Errorf("%q takes no arguments", tag.name)
+		}
+	case "transform", "collate", "col":
+		if tag.value == "" {
+			return errors.Errorf("%q needs a value, like %s=name", tag.name, tag.name)
+		}
+	case "type":
+		if len(tag.args) != 1 {
+			return errors.Errorf("%q needs a single argument, like type(NUMERIC)", tag.name)
+		}
+	case "uniqueWith", "indexWith":
+		if !tag.hasArgs {
+			return errors.Errorf("%q needs arguments, like %s(Field)", tag.name, tag.name)
+		}
+		seen := map[string]bool{field.Name: true}
+		for _, arg := range tag.args {
+			if arg == field.Name {
+				return errors.Errorf("%q can't name its own field %q", tag.name, arg)
+			}
+			if seen[arg] {
+				return errors.Errorf("%q names %q more than once", tag.name, arg)
+			}
+			seen[arg] = true
+		}
+	}
+	switch tag.name {
+	case "unique":
+		meta.indices = append(meta.indices, IndexSpec{
+			Columns: []string{fieldMeta.col},
+			Unique:  true,
+		})
+	case "index":
+		meta.indices = append(meta.indices, IndexSpec{
+			Columns: []string{fieldMeta.col},
+			Unique:  false,
+		})
+	case "col":
+		if err := validIdentifier(tag.value); err != nil {
+			return err
+		}
+		fieldMeta.col = tag.value
+		fieldMeta.renamed = tag.value != m.mapper.ColumnName(field.Name)
+	case "pkey":
+		fieldMeta.pkey = true
+	case "autoinc":
+		fieldMeta.autoinc = true
+	case "hashed":
+		fieldMeta.hashed = true
+	case "omitempty":
+		fieldMeta.omitEmpty = true
+	case "lower":
+		if field.Type.Kind() != reflect.String {
+			return errors.Errorf("col %q can't be lowercased since it's not a string", field.Name)
+		}
+		fieldMeta.lower = true
+	case "contenthash":
+		if !transformable(field.Type) {
+			return errors.Errorf("col %q can't be a contenthash since it's not a string or []byte", field.Name)
+		}
+		if meta.contentHash != nil {
+			return errors.Errorf("%v has multiple contenthash fields: %q and %q", meta.typ, meta.contentHash.name, field.Name)
+		}
+		meta.contentHash = fieldMeta
+	case "updated":
+		if !isUpdatedType(field.Type) {
+			return errors.Errorf("col %q can't be updated since it's not a SQLTime or SQLTimeText", field.Name)
+		}
+		if meta.updated != nil {
+			return errors.Errorf("%v has multiple updated fields: %q and %q", meta.typ, meta.updated.name, field.Name)
+		}
+		meta.updated = fieldMeta
+	case "transform":
+		if !transformable(field.Type) {
+			return errors.Errorf("col %q can't be transformed since it's not a string or []byte", field.Name)
+		}
+		if fieldMeta.transformer, err = m.transformer(tag.value); err != nil {
+			return err
+		}
+		fieldMeta.sqlType = "BLOB"
+	case "collate":
+		if fieldMeta.collate, err = m.collation(tag.value); err != nil {
+			return err
+		}
+	case "type":
+		if !sqlTypeRegexp.MatchString(tag.args[0]) {
+			return errors.Errorf("%q is not a valid SQL type", tag.args[0])
+		}
+		fieldMeta.sqlType = strings.ToUpper(tag.args[0])
+	case "uniqueWith", "indexWith":
+		meta.withTags = append(meta.withTags, withTag{field: field.Name, tag: tag.text, fields: tag.args, index: len(meta.indices)})
+		meta.indices = append(meta.indices, IndexSpec{
+			Columns: []string{fieldMeta.col},
+			Unique:  tag.name == "uniqueWith",
+		})
+	default:
+		meta.unknownTags = append(meta.unknownTags, fmt.Sprintf("%s: %q", field.Name, tag.text))
+	}
+	return nil
+}
+
+// indexSpec returns the IndexSpec of an index declared by an Indexer of meta.
+func (m *metaCache) indexSpec(meta *tableMeta, index Index) (IndexSpec, error) {
+	if (len(index.Columns) == 0) == (index.Expr == "") {
+		return IndexSpec{}, errors.Errorf("index %+v must have either columns or an expression", index)
+	}
+	spec := IndexSpec{
+		Table:  meta.table,
+		Expr:   index.Expr,
+		Unique: index.Unique,
+		Where:  strings.TrimSpace(index.Where),
+	}
+	if len(index.Columns) > 0 {
+		fieldsByName := map[string]*fieldMeta{}
+		for _, field := range meta.fields {
+			fieldsByName[field.name] = field
+		}
+		for _, name := range index.Columns {
+			if _, found := fieldsByName[name]; !found {
+				return IndexSpec{}, errors.Errorf("index %+v has unknown column %q", index, name)
+			}
+		}
+		spec.Columns = meta.columnNames(index.Columns)
+	}
+	switch {
+	case index.Name != "":
+		if err := validIdentifier(index.Name); err != nil {
+			return IndexSpec{}, errors.Wrapf(err, "invalid index name")
+		}
+		spec.Name = index.Name
+	case index.Expr != "":
+		return IndexSpec{}, errors.Errorf("expression index %q has no name", index.Expr)
+	default:
+		spec.fullName = m.naming.IndexName(meta.table, spec.Columns, spec.Unique)
+	}
+	return spec, nil
+}
+
+func (meta *tableMeta) planContentHash() []error {
+	problems := []error{}
+	if meta.contentHash.hashed || meta.contentHash.transformer != nil {
+		problems = append(problems, errors.Errorf("contenthash col %q of %v can't be hashed or transformed", meta.contentHash.name, meta.typ))
+	}
+	hashed := false
+	for _, field := range meta.fields {
+		hashed = hashed || field.hashed
+	}
+	if !hashed {
+		problems = append(problems, errors.Errorf("%v has a contenthash field but no fields tagged `sqly:\"hashed\"`", meta.typ))
+	}
+	for _, index := range meta.indices {
+		if index.Unique && len(index.Columns) == 1 && index.Columns[0] == meta.contentHash.col {
+			return problems
+		}
+	}
+	meta.indices = append(meta.indices, IndexSpec{
+		Columns: []string{meta.contentHash.col},
+		Unique:  true,
+	})
+	return problems
+}
+
+// needsPrimaryKey returns whether val has an unset integer pkey that should be back-filled from the inserted row.
+// WITHOUT ROWID tables never do, since they have no rowid for LastInsertId to return.
+func (meta *tableMeta) needsPrimaryKey(val reflect.Value) bool {
+	if meta.pkey == nil || meta.withoutRowID {
+		return false
+	}
+	fieldVal := val.Field(meta.pkey.index)
+	return fieldVal.CanInt() && fieldVal.Int() == 0
+}
@@ -0,0 +1,120 @@
+package sqly
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// StatementHandler runs the statements of a DB or Tx.
+// label describes which sqly helper issued the statement, like "upsert:User", and is empty for statements run directly.
+type StatementHandler interface {
+	ExecContext(ctx context.Context, label string, query string, args ...any) (sql.Result, error)
+	QueryxContext(ctx context.Context, label string, query string, args ...any) (*sqlx.Rows, error)
+}
+
+// StatementHandlerFuncs is a StatementHandler made of two functions, which must both be set.
+type StatementHandlerFuncs struct {
+	Exec  func(ctx context.Context, label string, query string, args ...any) (sql.Result, error)
+	Query func(ctx context.Context, label string, query string, args ...any) (*sqlx.Rows, error)
+}
+
+func (s StatementHandlerFuncs) ExecContext(ctx context.Context, label string, query string, args ...any) (sql.Result, error) {
+	return s.Exec(ctx, label, query, args...)
+}
+
+func (s StatementHandlerFuncs) QueryxContext(ctx context.Context, label string, query string, args ...any) (*sqlx.Rows, error) {
+	return s.Query(ctx, label, query, args...)
+}
+
+// Middleware wraps the StatementHandler running the statements of a DB or Tx.
+type Middleware func(next StatementHandler) StatementHandler
+
+// WithMiddleware makes the ExecContext and QueryxContext of the DB, and of the Txs it begins, run through middlewares, the first being the outermost.
+// Since all sqly helpers run their statements that way, middlewares see every statement sqly generates.
+// Statements run using other methods of the embedded sqlx types, like QueryRowxContext or prepared statements, bypass the middlewares.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(db *DB) error {
+		db.middlewares = append(db.middlewares, middlewares...)
+		return nil
+	}
+}
+
+type labelKey struct{}
+
+// labeled returns ctx labeled op:subject, or just op if subject is empty, if x runs statements through middlewares.
+// Labels aren't replaced, so helpers used by other helpers, like EnsureIndex by CreateTableIfNotExists, keep the label of the outermost one.
+func labeled(ctx context.Context, x any, op string, subject string) context.Context {
+	if router, ok := x.(statementRouter); !ok || !router.routesStatements() || labelOf(ctx) != "" {
+		return ctx
+	}
+	label := op
+	if subject != "" {
+		label += ":" + subject
+	}
+	return context.WithValue(ctx, labelKey{}, label)
+}
+
+func labelOf(ctx context.Context) string {
+	label, _ := ctx.Value(labelKey{}).(string)
+	return label
+}
+
+type statementRouter interface {
+	routesStatements() bool
+}
+
+// chain wraps base in middlewares, the first being the outermost.
+func chain(base StatementHandler, middlewares []Middleware) StatementHandler {
+	for index := len(middlewares) - 1; index >= 0; index-- {
+		base = middlewares[index](base)
+	}
+	return base
+}
+
+func (db *DB) routesStatements() bool {
+	return db.handler != nil
+}
+
+func (tx *Tx) routesStatements() bool {
+	return tx.handler != nil
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if db.handler == nil {
+		return db.DB.ExecContext(ctx, query, args...)
+	}
+	return db.handler.ExecContext(ctx, labelOf(ctx), query, args...)
+}
+
+func (db *DB) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	if db.handler == nil {
+		return db.DB.QueryxContext(ctx, query, args...)
+	}
+	return db.handler.QueryxContext(ctx, labelOf(ctx), query, args...)
+}
+
+func (tx *Tx) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	if tx.handler == nil {
+		return tx.Tx.QueryxContext(ctx, query, args...)
+	}
+	return tx.handler.QueryxContext(ctx, labelOf(ctx), query, args...)
+}
+
+// getContext works like sqlx.GetContext, but runs query using QueryxContext if q routes statements through middlewares.
+func getContext(ctx context.Context, q sqlx.QueryerContext, dest any, query string, args ...any) error {
+	if router, ok := q.(statementRouter); !ok || !router.routesStatements() {
+		return sqlx.GetContext(ctx, q, dest, query, args...)
+	}
+	found := reflect.New(reflect.SliceOf(reflect.TypeOf(dest).Elem()))
+	if err := sqlx.SelectContext(ctx, q, found.Interface(), query, args...); err != nil {
+		return err
+	}
+	if found.Elem().Len() == 0 {
+		return sql.ErrNoRows
+	}
+	reflect.ValueOf(dest).Elem().Set(found.Elem().Index(0))
+	return nil
+}
@@ -0,0 +1,122 @@
+package sqly
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type tenantTestStruct struct {
+	Id   int `sqly:"pkey"`
+	Name string
+}
+
+// tenantMiddleware rewrites the table of tenantTestStruct to a per tenant table, in the SQL and in args naming the table.
+func tenantMiddleware(tenant string) Middleware {
+	rewrite := func(query string, args []any) (string, []any) {
+		rewritten := make([]any, len(args))
+		for index, arg := range args {
+			if arg == "tenantTestStruct" {
+				arg = tenant + "_tenantTestStruct"
+			}
+			rewritten[index] = arg
+		}
+		return strings.ReplaceAll(query, "tenantTestStruct", tenant+"_tenantTestStruct"), rewritten
+	}
+	return func(next StatementHandler) StatementHandler {
+		return StatementHandlerFuncs{
+			Exec: func(ctx context.Context, label string, query string, args ...any) (sql.Result, error) {
+				query, args = rewrite(query, args)
+				return next.ExecContext(ctx, label, query, args...)
+			},
+			Query: func(ctx context.Context, label string, query string, args ...any) (*sqlx.Rows, error) {
+				query, args = rewrite(query, args)
+				return next.QueryxContext(ctx, label, query, args...)
+			},
+		}
+	}
+}
+
+// failingMiddleware fails the statements with labels starting with prefix.
+func failingMiddleware(prefix string) Middleware {
+	return func(next StatementHandler) StatementHandler {
+		return StatementHandlerFuncs{
+			Exec: func(ctx context.Context, label string, query string, args ...any) (sql.Result, error) {
+				if strings.HasPrefix(label, prefix) {
+					return nil, errors.Errorf("injected failure of %q", label)
+				}
+				return next.ExecContext(ctx, label, query, args...)
+			},
+			Query: func(ctx context.Context, label string, query string, args ...any) (*sqlx.Rows, error) {
+				if strings.HasPrefix(label, prefix) {
+					return nil, errors.Errorf("injected failure of %q", label)
+				}
+				return next.QueryxContext(ctx, label, query, args...)
+			},
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	labels := map[string]bool{}
+	recording := func(next StatementHandler) StatementHandler {
+		return StatementHandlerFuncs{
+			Exec: func(ctx context.Context, label string, query string, args ...any) (sql.Result, error) {
+				labels[label] = true
+				return next.ExecContext(ctx, label, query, args...)
+			},
+			Query: func(ctx context.Context, label string, query string, args ...any) (*sqlx.Rows, error) {
+				labels[label] = true
+				return next.QueryxContext(ctx, label, query, args...)
+			},
+		}
+	}
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, tenantTestStruct{}))
+		noerr(t, db.Upsert(ctx, &tenantTestStruct{Id: 1, Name: "a"}, false))
+		got, err := GetSQL[tenantTestStruct](ctx, db, "SELECT * FROM tenantTestStruct WHERE Id = ?", 1)
+		noerr(t, err)
+		if got.Name != "a" {
+			t.Errorf("got %+v, wanted the upserted row", got)
+		}
+		found, err := SelectByExample(ctx, db, tenantTestStruct{Name: "a"})
+		noerr(t, err)
+		if len(found) != 1 {
+			t.Errorf("got %+v, wanted the upserted row", found)
+		}
+		count := 0
+		noerr(t, db.DB.Get(&count, "SELECT COUNT(*) FROM acme_tenantTestStruct"))
+		if count != 1 {
+			t.Errorf("got %v rows in the tenant table, wanted 1", count)
+		}
+		exists, err := tableExists(ctx, &db.DB, "sqlite", "tenantTestStruct")
+		noerr(t, err)
+		if exists {
+			t.Errorf("got the unrewritten table created")
+		}
+		for _, label := range []string{"createTable:tenantTestStruct", "upsert:tenantTestStruct", "get:tenantTestStruct", "selectByExample:tenantTestStruct"} {
+			if !labels[label] {
+				t.Errorf("got labels %v, wanted %q", labels, label)
+			}
+		}
+	}, WithMiddleware(recording, tenantMiddleware("acme")))
+
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, tenantTestStruct{}))
+		err := db.Upsert(ctx, &tenantTestStruct{Id: 1, Name: "a"}, false)
+		if err == nil || !strings.Contains(err.Error(), `injected failure of "upsert:tenantTestStruct"`) {
+			t.Errorf("got %v, wanted the injected failure", err)
+		}
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO tenantTestStruct (Id, Name) VALUES (1, 'a')")
+			return err
+		}))
+		if count := countRows(t, db, "tenantTestStruct"); count != 1 {
+			t.Errorf("got %v rows, wanted only the unlabeled insert", count)
+		}
+	}, WithMiddleware(failingMiddleware("upsert:")))
+}
@@ -0,0 +1,34 @@
+package sqly
+
+import (
+	"strings"
+)
+
+// NamingStrategy decides the names sqly generates for the indices of field tags, Indexer indices without names, and IndexSpecs without names.
+type NamingStrategy interface {
+	// IndexName returns the full name of an index on cols of table, which may be qualified by a schema like "archive.Events".
+	IndexName(table string, cols []string, unique bool) string
+	// OwnsIndex returns whether name, of an index of table, is one IndexName could have returned, so that SchemaDiff
+	// can report the indices sqly created but doesn't declare anymore without reporting indices created by others.
+	OwnsIndex(table string, name string) bool
+}
+
+// defaultNaming names indices "table.col1,col2", which is how sqly has always named them.
+type defaultNaming struct{}
+
+func (defaultNaming) IndexName(table string, cols []string, unique bool) string {
+	return table + "." + strings.Join(cols, ",")
+}
+
+func (defaultNaming) OwnsIndex(table string, name string) bool {
+	return strings.HasPrefix(name, table+".")
+}
+
+// WithNamingStrategy makes the DB name the indices it generates names for using strategy, instead of as "table.col1,col2".
+// Indices created with the previous names aren't renamed, but SchemaDiff reports them as extra indices if strategy owns them.
+func WithNamingStrategy(strategy NamingStrategy) Option {
+	return func(db *DB) error {
+		db.namingStrategy = strategy
+		return nil
+	}
+}
@@ -0,0 +1,61 @@
+package sqly
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// snakeNaming names indices like idx_table_col1_col2 and uniq_table_col1_col2.
+type snakeNaming struct{}
+
+func (snakeNaming) IndexName(table string, cols []string, unique bool) string {
+	prefix := "idx_"
+	if unique {
+		prefix = "uniq_"
+	}
+	return prefix + table + "_" + strings.Join(cols, "_")
+}
+
+func (snakeNaming) OwnsIndex(table string, name string) bool {
+	return strings.HasPrefix(name, "idx_"+table+"_") || strings.HasPrefix(name, "uniq_"+table+"_")
+}
+
+type namedTestStruct struct {
+	Id    int    `sqly:"pkey"`
+	Email string `sqly:"unique"`
+	First string `sqly:"indexWith(Last)"`
+	Last  string
+}
+
+type renamedNamedTestStruct struct {
+	Id    int    `sqly:"pkey"`
+	Email string `sqly:"unique"`
+	First string
+	Last  string
+}
+
+func (renamedNamedTestStruct) TableName() string {
+	return "namedTestStruct"
+}
+
+func TestNamingStrategy(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, namedTestStruct{}))
+		noerr(t, db.EnsureIndex(ctx, IndexSpec{Table: "namedTestStruct", Columns: []string{"Last"}}))
+		noerr(t, db.EnsureIndex(ctx, IndexSpec{Table: "namedTestStruct", Columns: []string{"Last"}, Name: "byLast"}))
+		indices := []string{}
+		noerr(t, db.SelectContext(ctx, &indices, "SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'namedTestStruct' AND sql IS NOT NULL ORDER BY name"))
+		if want := []string{"idx_namedTestStruct_First_Last", "idx_namedTestStruct_Last", "namedTestStruct.byLast", "uniq_namedTestStruct_Email"}; !reflect.DeepEqual(indices, want) {
+			t.Errorf("got indices %q, wanted %q", indices, want)
+		}
+		diff, err := SchemaDiff(ctx, db, renamedNamedTestStruct{})
+		noerr(t, err)
+		if want := []DiffIndex{
+			{Table: "namedTestStruct", Name: "idx_namedTestStruct_First_Last"},
+			{Table: "namedTestStruct", Name: "idx_namedTestStruct_Last"},
+		}; !reflect.DeepEqual(diff.ExtraIndices, want) {
+			t.Errorf("got extra indices %+v, wanted %+v", diff.ExtraIndices, want)
+		}
+	}, WithNamingStrategy(snakeNaming{}))
+}
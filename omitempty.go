@@ -0,0 +1,103 @@
+package sqly
+
+import (
+	"database/sql"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// Fields tagged `sqly:"omitempty"` are left out of the INSERT when they are zero, so the column gets its default, which is NULL for columns created by sqly.
+// Omitting a column that is NOT NULL without a default makes the INSERT fail, and overwriting Upserts replace the whole row, so omitted columns become NULL again.
+// Non pointer omitempty fields are scanned as zero when NULL by the sqly helpers like GetSQL, but not by plain sqlx.
+
+// omittedFields returns the omitBits of the zero valued omitempty fields of val.
+func (meta *tableMeta) omittedFields(val reflect.Value) uint64 {
+	if !meta.omitsEmpty {
+		return 0
+	}
+	result := uint64(0)
+	for _, field := range meta.fields {
+		if field.omitBit != 0 && val.Field(field.index).IsZero() {
+			result |= field.omitBit
+		}
+	}
+	return result
+}
+
+// nullScanned returns whether field is omitempty and can't hold NULL, so that it has to be scanned by a nullScanner.
+func (field *fieldMeta) nullScanned() bool {
+	if !field.omitEmpty || field.typ.Kind() == reflect.Ptr {
+		return false
+	}
+	_, isScanner := reflect.New(field.typ).Interface().(sql.Scanner)
+	return !isScanner
+}
+
+// nullScanner scans NULL, like the omitted omitempty fields get, as the zero value.
+type nullScanner struct {
+	field reflect.Value
+}
+
+func (n nullScanner) Scan(src any) error {
+	if src == nil {
+		n.field.SetZero()
+		return nil
+	}
+	srcVal := reflect.ValueOf(src)
+	switch n.field.Kind() {
+	case reflect.String:
+		switch typed := src.(type) {
+		case string:
+			n.field.SetString(typed)
+			return nil
+		case []byte:
+			n.field.SetString(string(typed))
+			return nil
+		}
+	case reflect.Slice:
+		if n.field.Type().Elem().Kind() == reflect.Uint8 {
+			switch typed := src.(type) {
+			case []byte:
+				n.field.SetBytes(append([]byte{}, typed...))
+				return nil
+			case string:
+				n.field.SetBytes([]byte(typed))
+				return nil
+			}
+		}
+	case reflect.Bool:
+		switch typed := src.(type) {
+		case bool:
+			n.field.SetBool(typed)
+			return nil
+		case int64:
+			n.field.SetBool(typed != 0)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if typed, ok := src.(int64); ok && !n.field.OverflowInt(typed) {
+			n.field.SetInt(typed)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if typed, ok := src.(int64); ok && typed >= 0 && !n.field.OverflowUint(uint64(typed)) {
+			n.field.SetUint(uint64(typed))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch typed := src.(type) {
+		case float64:
+			n.field.SetFloat(typed)
+			return nil
+		case int64:
+			n.field.SetFloat(float64(typed))
+			return nil
+		}
+	}
+	if srcVal.Type().AssignableTo(n.field.Type()) {
+		n.field.Set(srcVal)
+		return nil
+	}
+	return errors.Errorf("can't scan %T into %v", src, n.field.Type())
+}
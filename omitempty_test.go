@@ -0,0 +1,62 @@
+package sqly
+
+import (
+	"strings"
+	"testing"
+)
+
+type omitEmptyTestStruct struct {
+	Id      int `sqly:"pkey,autoinc"`
+	Name    string
+	Nick    string  `sqly:"omitempty"`
+	Count   int     `sqly:"omitempty"`
+	Flag    bool    `sqly:"omitempty"`
+	Ratio   float64 `sqly:"omitempty"`
+	Payload []byte  `sqly:"omitempty"`
+	Pointer *string `sqly:"omitempty"`
+}
+
+type omitEmptyPkeyTestStruct struct {
+	Id string `sqly:"pkey,omitempty"`
+}
+
+func TestOmitEmpty(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, omitEmptyTestStruct{}))
+		recorder := &recordingDB{DB: db}
+		empty := &omitEmptyTestStruct{Name: "a"}
+		noerr(t, Upsert(ctx, recorder, empty, false))
+		if want := "INSERT INTO `omitEmptyTestStruct` (`Name`) VALUES (?)"; len(recorder.statements) != 1 || recorder.statements[0] != want {
+			t.Errorf("got %q, wanted [%q]", recorder.statements, want)
+		}
+		nulls := 0
+		noerr(t, db.Get(&nulls, "SELECT COUNT(*) FROM omitEmptyTestStruct WHERE Nick IS NULL AND Count IS NULL AND Flag IS NULL AND Ratio IS NULL AND Payload IS NULL AND Pointer IS NULL"))
+		if nulls != 1 {
+			t.Errorf("got %v rows with NULLs, wanted 1", nulls)
+		}
+		got, err := GetSQL[omitEmptyTestStruct](ctx, db, "SELECT * FROM omitEmptyTestStruct WHERE Id = ?", empty.Id)
+		noerr(t, err)
+		if got.Name != "a" || got.Nick != "" || got.Count != 0 || got.Flag || got.Ratio != 0 || got.Payload != nil || got.Pointer != nil {
+			t.Errorf("got %+v, wanted the NULLs scanned as zero values", got)
+		}
+
+		nick := "n"
+		full := &omitEmptyTestStruct{Name: "b", Nick: "nick", Count: 3, Flag: true, Ratio: 0.5, Payload: []byte{1}, Pointer: &nick}
+		noerr(t, db.Upsert(ctx, full, false))
+		got, err = GetSQL[omitEmptyTestStruct](ctx, db, "SELECT * FROM omitEmptyTestStruct WHERE Id = ?", full.Id)
+		noerr(t, err)
+		if got.Nick != "nick" || got.Count != 3 || !got.Flag || got.Ratio != 0.5 || len(got.Payload) != 1 || got.Pointer == nil || *got.Pointer != "n" {
+			t.Errorf("got %+v, wanted %+v", got, full)
+		}
+
+		noerr(t, db.UpsertAll(ctx, []*omitEmptyTestStruct{{Id: 10, Name: "c"}, {Id: 11, Name: "d", Count: 1}}, false))
+		found, err := SelectByExample(ctx, db, omitEmptyTestStruct{Count: 1})
+		noerr(t, err)
+		if len(found) != 1 || found[0].Id != 11 {
+			t.Errorf("got %+v, wanted the row with Count 1", found)
+		}
+	})
+	if err := Validate(omitEmptyPkeyTestStruct{}); err == nil || !strings.Contains(err.Error(), "can't be omitempty") {
+		t.Errorf("got %v, wanted an error about an omitempty pkey", err)
+	}
+}
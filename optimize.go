@@ -0,0 +1,53 @@
+package sqly
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Optimize runs PRAGMA optimize, which updates the statistics the query planner uses to choose indices when they are outdated.
+// The write lock is held while optimizing.
+func (db *DB) Optimize(ctx context.Context) error {
+	return db.mapError(db.optimize(ctx))
+}
+
+func (db *DB) optimize(ctx context.Context) error {
+	if !isSQLiteDriver(db.DriverName()) {
+		return errors.Errorf("optimizing isn't supported for driver %q", db.DriverName())
+	}
+	if err := db.lockContext(ctx); err != nil {
+		return err
+	}
+	defer db.locker.Unlock()
+	start := time.Now()
+	if _, err := db.ExecContext(labeled(ctx, db, "optimize", ""), "PRAGMA optimize"); err != nil {
+		return withStack(err)
+	}
+	db.log(ctx, slog.LevelInfo, "sqly: optimized", "duration", time.Since(start))
+	return nil
+}
+
+// WithOptimizeInterval makes the DB run Optimize every interval, and once more when it's closed, as SQLite recommends.
+// Failures are logged to the logger from WithLogger.
+func WithOptimizeInterval(interval time.Duration) Option {
+	return func(db *DB) error {
+		if !isSQLiteDriver(db.DriverName()) {
+			return errors.Errorf("optimizing isn't supported for driver %q", db.DriverName())
+		}
+		if interval <= 0 {
+			return errors.Errorf("optimize interval %v isn't positive", interval)
+		}
+		db.optimizing = &periodic{
+			interval: interval,
+			run: func(ctx context.Context) {
+				if err := db.Optimize(ctx); err != nil && ctx.Err() == nil {
+					db.log(ctx, slog.LevelError, "sqly: optimizing failed", "error", err)
+				}
+			},
+		}
+		return nil
+	}
+}
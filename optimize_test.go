@@ -0,0 +1,78 @@
+package sqly
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// lockedBuffer is a bytes.Buffer that can be logged to from other goroutines.
+type lockedBuffer struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (l *lockedBuffer) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.buffer.Write(p)
+}
+
+func (l *lockedBuffer) String() string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.buffer.String()
+}
+
+func TestOptimize(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		noerr(t, db.Optimize(ctx))
+	})
+	_, err := Open("fakepostgres", ":memory:", WithOptimizeInterval(time.Second))
+	yeserr(t, err)
+}
+
+func TestOptimizeInterval(t *testing.T) {
+	optimizes := atomic.Int64{}
+	counter := func(next StatementHandler) StatementHandler {
+		return StatementHandlerFuncs{
+			Exec: func(ctx context.Context, label string, query string, args ...any) (sql.Result, error) {
+				if strings.HasPrefix(query, "PRAGMA optimize") {
+					optimizes.Add(1)
+				}
+				return next.ExecContext(ctx, label, query, args...)
+			},
+			Query: func(ctx context.Context, label string, query string, args ...any) (*sqlx.Rows, error) {
+				return next.QueryxContext(ctx, label, query, args...)
+			},
+		}
+	}
+	logs := &lockedBuffer{}
+	db, err := Open("sqlite", ":memory:", WithOptimizeInterval(10*time.Millisecond), WithMiddleware(counter), WithLogger(slog.New(slog.NewTextHandler(logs, nil))))
+	noerr(t, err)
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(logs.String(), "sqly: optimized") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !strings.Contains(logs.String(), "sqly: optimized") {
+		t.Errorf("got logs %q, wanted an optimization", logs.String())
+	}
+	noerr(t, db.Close())
+	closed := optimizes.Load()
+	if closed < 2 {
+		t.Errorf("got %v optimizes after closing, wanted at least one in the background and one when closing", closed)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if optimizes.Load() != closed {
+		t.Errorf("got %v optimizes after closing, wanted them to stop at %v", optimizes.Load(), closed)
+	}
+}
@@ -0,0 +1,41 @@
+package sqly
+
+import (
+	"context"
+	"time"
+)
+
+// periodic calls run every interval in a goroutine of its own, until it's stopped.
+type periodic struct {
+	interval time.Duration
+	// run is given a context that is cancelled when the periodic is stopped.
+	run func(ctx context.Context)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (p *periodic) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.run(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// stop stops calling run, and waits for a running call to return.
+func (p *periodic) stop() {
+	p.cancel()
+	<-p.done
+}
@@ -0,0 +1,229 @@
+package sqly
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+)
+
+func notFoundOrStack(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.WithStack(ErrNotFound)
+	}
+	return withStack(err)
+}
+
+// rebind rewrites the ? placeholders of query to the bindvar type of the driver of x.
+func rebind(x any, query string) string {
+	return sqlx.Rebind(sqlx.BindType(driverNameOf(x)), query)
+}
+
+// inSlice returns the slice that sqlx.In would expand arg into, if any.
+func inSlice(arg any) (reflect.Value, bool) {
+	if arg == nil {
+		return reflect.Value{}, false
+	}
+	if _, ok := arg.(driver.Valuer); ok {
+		return reflect.Value{}, false
+	}
+	val := reflect.Indirect(reflect.ValueOf(arg))
+	if val.Kind() != reflect.Slice || val.Type().Elem().Kind() == reflect.Uint8 {
+		return reflect.Value{}, false
+	}
+	return val, true
+}
+
+// expand expands the slice args of query into one ? placeholder per element, like sqlx.In, and then rebinds it to the bindvar type of the driver of x.
+// Empty slices are replaced by an empty subquery, since `IN ()` isn't valid in most dialects, and an empty subquery makes IN false and NOT IN true.
+func expand(x any, query string, args []any) (string, []any, error) {
+	slices := false
+	for _, arg := range args {
+		if _, ok := inSlice(arg); ok {
+			slices = true
+			break
+		}
+	}
+	if !slices {
+		return rebind(x, query), args, nil
+	}
+	buf := &strings.Builder{}
+	kept := make([]any, 0, len(args))
+	argIndex := 0
+	for {
+		placeholder := strings.IndexByte(query, '?')
+		if placeholder == -1 || argIndex >= len(args) {
+			break
+		}
+		buf.WriteString(query[:placeholder])
+		if val, ok := inSlice(args[argIndex]); ok && val.Len() == 0 {
+			buf.WriteString("SELECT NULL WHERE 1=0")
+		} else if ok {
+			// sqlx.In can't expand pointers to slices.
+			buf.WriteByte('?')
+			kept = append(kept, val.Interface())
+		} else {
+			buf.WriteByte('?')
+			kept = append(kept, args[argIndex])
+		}
+		query = query[placeholder+1:]
+		argIndex++
+	}
+	buf.WriteString(query)
+	kept = append(kept, args[argIndex:]...)
+	expanded, expandedArgs, err := sqlx.In(buf.String(), kept...)
+	if err != nil {
+		return "", nil, withStack(err)
+	}
+	return rebind(x, expanded), expandedArgs, nil
+}
+
+func readIn(ctx context.Context, querier sqlx.QueryerContext, f func(sqlx.QueryerContext) error) error {
+	if db, ok := querier.(*DB); ok {
+		return db.Read(ctx, func(tx *Tx) error {
+			return f(tx)
+		})
+	}
+	return mapErrorOf(querier, f(querier))
+}
+
+// afterScan runs the post processing, like decoding transformed fields, of the struct pointed to by structPointer.
+// Non struct pointers are left untouched, and structs sqly can't plan a table for fail.
+func afterScan(querier any, structPointer any) error {
+	val := reflect.ValueOf(structPointer).Elem()
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+	meta, err := metasFor(querier).get(val.Type())
+	if err != nil {
+		return err
+	}
+	return meta.afterScan(val)
+}
+
+// GetSQL runs query and scans the single resulting row into a T, which is either a struct or a scannable scalar.
+// Structs with fields database/sql can't scan into, like byte arrays, are scanned by sqly instead of sqlx.
+// The query is rebound from ? placeholders to the bindvar type of the driver, so the same query works across dialects,
+// and slice args, except []byte, are expanded into one placeholder per element for use with IN (?).
+// If querier is a *DB the query is run in a Read transaction.
+// Returns ErrNotFound if the query produced no rows.
+func GetSQL[T any](ctx context.Context, querier sqlx.QueryerContext, query string, args ...any) (T, error) {
+	var result T
+	if err := readIn(ctx, querier, func(q sqlx.QueryerContext) error {
+		ctx := labeled(ctx, q, "get", reflect.TypeFor[T]().Name())
+		query, args, err := expand(q, query, args)
+		if err != nil {
+			return err
+		}
+		if meta := scanRowMeta[T](q); meta != nil {
+			found, err := selectRows[T](ctx, q, meta, query, args...)
+			if err != nil {
+				return err
+			}
+			if len(found) == 0 {
+				return errors.WithStack(ErrNotFound)
+			}
+			result = found[0]
+			return afterScan(q, &result)
+		}
+		if err := getContext(ctx, q, &result, query, args...); err != nil {
+			return notFoundOrStack(err)
+		}
+		return afterScan(q, &result)
+	}); err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+// SelectSQL runs query and scans the resulting rows into a []T, like GetSQL.
+// If querier is a *DB the query is run in a Read transaction.
+func SelectSQL[T any](ctx context.Context, querier sqlx.QueryerContext, query string, args ...any) ([]T, error) {
+	var result []T
+	if err := readIn(ctx, querier, func(q sqlx.QueryerContext) error {
+		var err error
+		result, err = selectSQL[T](labeled(ctx, q, "select", reflect.TypeFor[T]().Name()), q, query, args...)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func selectSQL[T any](ctx context.Context, q sqlx.QueryerContext, query string, args ...any) ([]T, error) {
+	query, args, err := expand(q, query, args)
+	if err != nil {
+		return nil, err
+	}
+	result := []T{}
+	if meta := scanRowMeta[T](q); meta != nil {
+		if result, err = selectRows[T](ctx, q, meta, query, args...); err != nil {
+			return nil, err
+		}
+	} else if err := sqlx.SelectContext(ctx, q, &result, query, args...); err != nil {
+		return nil, withStack(err)
+	}
+	for index := range result {
+		if err := afterScan(q, &result[index]); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// columnsSQL returns the columns of meta, for selecting only the columns the struct knows about instead of *,
+// which would fail to scan columns added to the table by newer versions of the struct.
+func (meta *tableMeta) columnsSQL() string {
+	cols := make([]string, len(meta.fields))
+	for index, field := range meta.fields {
+		cols[index] = fmt.Sprintf("`%s`", field.col)
+	}
+	return strings.Join(cols, ",")
+}
+
+// SelectByExample returns the rows of the table of T that are equal to example in every non zero field.
+// Since zero values can't be filtered on this way, pointer fields can be used instead, where nil means unset and any non nil pointer is filtered on.
+// If querier is a *DB the query is run in a Read transaction.
+func SelectByExample[T any](ctx context.Context, querier sqlx.QueryerContext, example T) ([]T, error) {
+	val := reflect.ValueOf(example)
+	meta, err := metaOf(querier, example)
+	if err != nil {
+		return nil, err
+	}
+	conditions := []string{}
+	params := []any{}
+	for _, field := range meta.fields {
+		fieldVal := val.Field(field.index)
+		if fieldVal.IsZero() {
+			continue
+		}
+		param, err := field.encode(fieldVal)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, fmt.Sprintf("`%s` = ?", field.col))
+		params = append(params, param)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", meta.columnsSQL(), quoteTable(meta.table))
+	if len(conditions) > 0 {
+		query = fmt.Sprintf("%s WHERE %s", query, strings.Join(conditions, " AND "))
+	}
+	var result []T
+	if err := readIn(ctx, querier, func(q sqlx.QueryerContext) error {
+		result, err = selectSQL[T](labeled(ctx, q, "selectByExample", meta.typ.Name()), q, query, params...)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
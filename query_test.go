@@ -0,0 +1,183 @@
+package sqly
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestGetSQL(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 1, Name: "one"}, false))
+		got, err := GetSQL[sharedTestStruct](ctx, db, "SELECT * FROM sharedTestStruct WHERE Id = ?", 1)
+		noerr(t, err)
+		if got.Name != "one" {
+			t.Errorf("got %+v, wanted Name \"one\"", got)
+		}
+		name, err := GetSQL[string](ctx, db, "SELECT Name FROM sharedTestStruct WHERE Id = ?", 1)
+		noerr(t, err)
+		if name != "one" {
+			t.Errorf("got %q, wanted \"one\"", name)
+		}
+		_, err = GetSQL[sharedTestStruct](ctx, db, "SELECT * FROM sharedTestStruct WHERE Id = ?", 2)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("got %v, wanted ErrNotFound", err)
+		}
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			if err := tx.Upsert(ctx, &sharedTestStruct{Id: 2, Name: "two"}, false); err != nil {
+				return err
+			}
+			name, err := GetSQL[string](ctx, tx, "SELECT Name FROM sharedTestStruct WHERE Id = ?", 2)
+			if err != nil {
+				return err
+			}
+			if name != "two" {
+				t.Errorf("got %q, wanted \"two\"", name)
+			}
+			return nil
+		}))
+		yeserr(t, func() error {
+			_, err := GetSQL[int](ctx, db, "SELECT * FROM nonExistent")
+			return err
+		}())
+	})
+}
+
+type exampleTestStruct struct {
+	Id     int `sqly:"pkey"`
+	Name   string
+	Active *bool
+	Score  *int
+}
+
+func TestSelectByExample(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, exampleTestStruct{}))
+		yes, no, zero, ten := true, false, 0, 10
+		noerr(t, db.UpsertAll(ctx, []*exampleTestStruct{
+			{Id: 1, Name: "a", Active: &yes, Score: &zero},
+			{Id: 2, Name: "a", Active: &no, Score: &ten},
+			{Id: 3, Name: "b", Active: &no},
+		}, false))
+		ids := func(rows []exampleTestStruct) []int {
+			result := []int{}
+			for _, row := range rows {
+				result = append(result, row.Id)
+			}
+			return result
+		}
+		for _, tc := range []struct {
+			example exampleTestStruct
+			want    []int
+		}{
+			{example: exampleTestStruct{}, want: []int{1, 2, 3}},
+			{example: exampleTestStruct{Name: "a"}, want: []int{1, 2}},
+			{example: exampleTestStruct{Active: &no}, want: []int{2, 3}},
+			{example: exampleTestStruct{Name: "a", Score: &zero}, want: []int{1}},
+			{example: exampleTestStruct{Name: "c"}, want: []int{}},
+		} {
+			got, err := SelectByExample(ctx, db, tc.example)
+			noerr(t, err)
+			if !reflect.DeepEqual(ids(got), tc.want) {
+				t.Errorf("got %v for %+v, wanted %v", ids(got), tc.example, tc.want)
+			}
+		}
+		got, err := SelectByExample(ctx, db, exampleTestStruct{Id: 3})
+		noerr(t, err)
+		if len(got) != 1 || got[0].Score != nil || got[0].Active == nil || *got[0].Active {
+			t.Errorf("got %+v, wanted row 3 with nil Score and false Active", got)
+		}
+	})
+}
+
+func TestExtraColumns(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		_, err := db.ExecContext(ctx, "ALTER TABLE sharedTestStruct ADD COLUMN Extra TEXT DEFAULT 'newer'")
+		noerr(t, err)
+		noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 1, Name: "one"}, false))
+		got, err := SelectByExample(ctx, db, sharedTestStruct{Name: "one"})
+		noerr(t, err)
+		if len(got) != 1 || got[0].Id != 1 {
+			t.Errorf("got %+v, wanted row 1", got)
+		}
+		refreshed := sharedTestStruct{Id: 1}
+		noerr(t, db.Refresh(ctx, &refreshed))
+		if refreshed.Name != "one" {
+			t.Errorf("got %+v, wanted Name one", refreshed)
+		}
+		existing := sharedTestStruct{Id: 1}
+		created, err := db.GetOrCreate(ctx, &existing)
+		noerr(t, err)
+		if created || existing.Name != "one" {
+			t.Errorf("got %+v, created %v, wanted the existing row", existing, created)
+		}
+	})
+}
+
+func TestRebind(t *testing.T) {
+	db, err := Open("fakepostgres", ":memory:")
+	noerr(t, err)
+	defer db.Close()
+	if got := db.Rebind("SELECT * FROM x WHERE a = ? AND b = ?"); got != "SELECT * FROM x WHERE a = $1 AND b = $2" {
+		t.Errorf("got %q, wanted $N placeholders", got)
+	}
+	sum, err := GetSQL[int](ctx, db, "SELECT ? + ?", 1, 2)
+	noerr(t, err)
+	if sum != 3 {
+		t.Errorf("got %v, wanted 3", sum)
+	}
+	noerr(t, db.Write(ctx, func(tx *Tx) error {
+		if got := tx.Rebind("?"); got != "$1" {
+			t.Errorf("got %q, wanted $1", got)
+		}
+		return nil
+	}))
+}
+
+func TestSliceArgs(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		for i := 1; i <= 5; i++ {
+			noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: i, Name: fmt.Sprint(i)}, false))
+		}
+		got, err := SelectSQL[sharedTestStruct](ctx, db, "SELECT * FROM sharedTestStruct WHERE Id IN (?) AND Name != ? ORDER BY Id", []int{1, 3, 4}, "3")
+		noerr(t, err)
+		if len(got) != 2 || got[0].Id != 1 || got[1].Id != 4 {
+			t.Errorf("got %+v, wanted 1 and 4", got)
+		}
+		got, err = SelectSQL[sharedTestStruct](ctx, db, "SELECT * FROM sharedTestStruct WHERE Id IN (?)", []int{})
+		noerr(t, err)
+		if len(got) != 0 {
+			t.Errorf("got %+v for an empty IN, wanted nothing", got)
+		}
+		count, err := GetSQL[int](ctx, db, "SELECT COUNT(*) FROM sharedTestStruct WHERE Id NOT IN (?) AND Id IN (?)", []string{}, []int64{2, 5})
+		noerr(t, err)
+		if count != 2 {
+			t.Errorf("got %v rows for an empty NOT IN, wanted 2", count)
+		}
+		rows := 0
+		noerr(t, db.EachMap(ctx, "SELECT * FROM sharedTestStruct WHERE Id IN (?)", func(row map[string]any) error {
+			rows++
+			return nil
+		}, &[]int{1, 2}))
+		if rows != 2 {
+			t.Errorf("got %v rows, wanted 2", rows)
+		}
+		blob, err := GetSQL[[]byte](ctx, db, "SELECT ?", []byte("blob"))
+		noerr(t, err)
+		if string(blob) != "blob" {
+			t.Errorf("got %q, wanted []byte args to be left alone", blob)
+		}
+	})
+	db, err := Open("fakepostgres", ":memory:")
+	noerr(t, err)
+	defer db.Close()
+	sum, err := GetSQL[int](ctx, db, "SELECT ? + (SELECT COUNT(*) FROM (SELECT 1 UNION SELECT 2) WHERE 1 IN (?))", 1, []int{1, 2})
+	noerr(t, err)
+	if sum != 3 {
+		t.Errorf("got %v, wanted 3", sum)
+	}
+}
@@ -0,0 +1,72 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// pkeyCondition returns a WHERE condition matching the row with the pkey of val, and its params.
+func (meta *tableMeta) pkeyCondition(val reflect.Value) (string, []any, error) {
+	if err := meta.requirePrimaryKey(); err != nil {
+		return "", nil, err
+	}
+	conditions := []string{}
+	params := []any{}
+	for _, field := range meta.pkeys {
+		param, err := field.encode(val.Field(field.index))
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, fmt.Sprintf("`%s` = ?", field.col))
+		params = append(params, param)
+	}
+	return strings.Join(conditions, " AND "), params, nil
+}
+
+// zeroPrimaryKey returns whether all pkey fields of val are zero.
+func (meta *tableMeta) zeroPrimaryKey(val reflect.Value) bool {
+	for _, field := range meta.pkeys {
+		if !val.Field(field.index).IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+func (db *DB) Refresh(ctx context.Context, structPointer any) error {
+	return Refresh(ctx, db, structPointer)
+}
+
+func (tx *Tx) Refresh(ctx context.Context, structPointer any) error {
+	return Refresh(ctx, tx, structPointer)
+}
+
+// Refresh reloads all persisted fields of structPointer from the row with its pkey, and returns ErrNotFound if there is no such row.
+// Fields sqly doesn't store, like unexported fields, are left untouched. Returns an error matching ErrZeroPrimaryKey if all pkey fields
+// are zero, like 0, "" or all zero bytes.
+// If querier is a *DB the query is run in a Read transaction.
+func Refresh(ctx context.Context, querier sqlx.QueryerContext, structPointer any) error {
+	val, meta, err := structPointerMeta(querier, structPointer)
+	if err != nil {
+		return err
+	}
+	if err := meta.requirePrimaryKey(); err != nil {
+		return err
+	}
+	if meta.zeroPrimaryKey(val) {
+		return errors.Wrapf(ErrZeroPrimaryKey, "refreshing %v", meta.typ)
+	}
+	condition, params, err := meta.pkeyCondition(val)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", meta.columnsSQL(), quoteTable(meta.table), condition)
+	return readIn(ctx, querier, func(q sqlx.QueryerContext) error {
+		return getStruct(labeled(ctx, q, "refresh", meta.typ.Name()), q, meta, val, query, params...)
+	})
+}
@@ -0,0 +1,64 @@
+package sqly
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestRefresh(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, withoutRowIDTestStruct{}))
+		val := &withoutRowIDTestStruct{Tenant: "a", Key: "x", Value: "1"}
+		noerr(t, db.Upsert(ctx, val, false))
+		_, err := db.Exec("UPDATE withoutRowIDTestStruct SET Value = '2' WHERE Tenant = 'a' AND Key = 'x'")
+		noerr(t, err)
+		noerr(t, db.Refresh(ctx, val))
+		if val.Value != "2" {
+			t.Errorf("got %+v, wanted the updated row", val)
+		}
+		_, err = db.Exec("DELETE FROM withoutRowIDTestStruct")
+		noerr(t, err)
+		if err := db.Refresh(ctx, val); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got %v, wanted ErrNotFound", err)
+		}
+
+		noerr(t, db.CreateTableIfNotExists(ctx, hashedTestStruct{}))
+		hashed := &hashedTestStruct{Id: [16]byte{1}, Hash: [32]byte{2}}
+		noerr(t, db.Upsert(ctx, hashed, false))
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "UPDATE hashedTestStruct SET Optional = x'01020304'")
+			return err
+		}))
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			return tx.Refresh(ctx, hashed)
+		}))
+		if hashed.Optional == nil || *hashed.Optional != [4]byte{1, 2, 3, 4} || hashed.Hash != [32]byte{2} {
+			t.Errorf("got %+v, wanted the byte arrays reloaded", hashed)
+		}
+		if err := db.Refresh(ctx, &hashedTestStruct{}); !errors.Is(err, ErrZeroPrimaryKey) {
+			t.Errorf("got %v, wanted ErrZeroPrimaryKey", err)
+		}
+
+		noerr(t, db.CreateTableIfNotExists(ctx, testStruct{}))
+		stored := &testStruct{String: "old", Int64: 1}
+		noerr(t, db.Upsert(ctx, stored, false))
+		_, err = db.Exec("UPDATE testStruct SET String = 'new', Int64 = 2 WHERE Int = ?", stored.Int)
+		noerr(t, err)
+		stored.unexported = 5
+		noerr(t, db.Refresh(ctx, stored))
+		if stored.String != "new" || stored.Int64 != 2 || stored.unexported != 5 {
+			t.Errorf("got %+v, wanted the updated row with the unexported field untouched", stored)
+		}
+		if err := db.Refresh(ctx, &testStruct{}); !errors.Is(err, ErrZeroPrimaryKey) {
+			t.Errorf("got %v, wanted ErrZeroPrimaryKey", err)
+		}
+		noerr(t, db.CreateTableIfNotExists(ctx, keyedRepoTestStruct{}))
+		if err := db.Refresh(ctx, &keyedRepoTestStruct{}); !errors.Is(err, ErrZeroPrimaryKey) {
+			t.Errorf("got %v, wanted ErrZeroPrimaryKey for an empty string pkey", err)
+		}
+		if err := db.Refresh(ctx, &withoutRowIDTestStruct{}); !errors.Is(err, ErrZeroPrimaryKey) {
+			t.Errorf("got %v, wanted ErrZeroPrimaryKey for a zero composite pkey", err)
+		}
+	})
+}
@@ -0,0 +1,210 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Repo bundles the common operations on the table of T.
+// The operations of a Repo made by NewRepo run in their own Write or Read, and those of a Repo returned by WithTx in the given transaction.
+type Repo[T any] struct {
+	db   *DB
+	tx   *Tx
+	meta *tableMeta
+}
+
+// NewRepo returns a Repo for T, which must be a struct with a pkey that the metadata of db can plan a table for.
+func NewRepo[T any](db *DB) (*Repo[T], error) {
+	meta, err := metasFor(db).get(reflect.TypeFor[T]())
+	if err != nil {
+		return nil, err
+	}
+	if err := meta.requirePrimaryKey(); err != nil {
+		return nil, err
+	}
+	return &Repo[T]{db: db, meta: meta}, nil
+}
+
+// WithTx returns a copy of r running its operations in tx.
+func (r *Repo[T]) WithTx(tx *Tx) *Repo[T] {
+	return &Repo[T]{db: r.db, tx: tx, meta: r.meta}
+}
+
+func (r *Repo[T]) querier() sqlx.QueryerContext {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+func (r *Repo[T]) write(ctx context.Context, f func(*Tx) error) error {
+	if r.tx != nil {
+		return r.db.mapError(f(r.tx))
+	}
+	return r.db.Write(ctx, f)
+}
+
+// EnsureTable creates or evolves the table of T, like CreateTableIfNotExists.
+func (r *Repo[T]) EnsureTable(ctx context.Context) error {
+	var prototype T
+	return r.write(ctx, func(tx *Tx) error {
+		return CreateTableIfNotExists(ctx, tx, prototype)
+	})
+}
+
+// Create inserts element, failing if a row with its pkey already exists, and back-fills unset autoinc pkeys.
+func (r *Repo[T]) Create(ctx context.Context, element *T) error {
+	return r.write(ctx, func(tx *Tx) error {
+		return Upsert(ctx, tx, element, false)
+	})
+}
+
+// pkeyCondition returns a WHERE condition matching the row with the pkey values pkey, given in pkey field order, and its params.
+func (r *Repo[T]) pkeyCondition(pkey []any) (string, []any, error) {
+	if len(pkey) != len(r.meta.pkeys) {
+		return "", nil, errors.Errorf("%v has %d pkey fields, got %d values", r.meta.typ, len(r.meta.pkeys), len(pkey))
+	}
+	val := reflect.New(r.meta.typ).Elem()
+	for index, field := range r.meta.pkeys {
+		fieldVal := val.Field(field.index)
+		if !setPkeyValue(fieldVal, reflect.ValueOf(pkey[index])) {
+			return "", nil, errors.Errorf("%v (%T) can't be the pkey %q (%v) of %v", pkey[index], pkey[index], field.name, field.typ, r.meta.typ)
+		}
+	}
+	return r.meta.pkeyCondition(val)
+}
+
+// setPkeyValue sets fieldVal to pkeyVal if pkeyVal is assignable to it, is an integer fitting in the integer of the same signedness it is,
+// or is a []byte as long as the byte array it is, and returns whether it did.
+// Other conversions, like of integers to strings or of floats to integers, would look up other rows than the ones asked for.
+func setPkeyValue(fieldVal reflect.Value, pkeyVal reflect.Value) bool {
+	switch {
+	case !pkeyVal.IsValid():
+		return false
+	case pkeyVal.Type().AssignableTo(fieldVal.Type()):
+		fieldVal.Set(pkeyVal)
+	case pkeyVal.CanInt() && fieldVal.CanInt() && !fieldVal.OverflowInt(pkeyVal.Int()):
+		fieldVal.SetInt(pkeyVal.Int())
+	case pkeyVal.CanUint() && fieldVal.CanUint() && !fieldVal.OverflowUint(pkeyVal.Uint()):
+		fieldVal.SetUint(pkeyVal.Uint())
+	case pkeyVal.Kind() == reflect.Slice && pkeyVal.Type().Elem().Kind() == reflect.Uint8 && fieldVal.Kind() == reflect.Array && isByteArray(fieldVal.Type()):
+		if pkeyVal.Len() != fieldVal.Len() {
+			return false
+		}
+		reflect.Copy(fieldVal, pkeyVal)
+	default:
+		return false
+	}
+	return true
+}
+
+// Get returns the row with the pkey values pkey, given in pkey field order, or ErrNotFound.
+func (r *Repo[T]) Get(ctx context.Context, pkey ...any) (T, error) {
+	condition, params, err := r.pkeyCondition(pkey)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return GetSQL[T](ctx, r.querier(), fmt.Sprintf("SELECT %s FROM %s WHERE %s", r.meta.columnsSQL(), quoteTable(r.meta.table), condition), params...)
+}
+
+// Find returns the rows matching where, which may use ? placeholders for args, or all rows if where is empty.
+func (r *Repo[T]) Find(ctx context.Context, where string, args ...any) ([]T, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s", r.meta.columnsSQL(), quoteTable(r.meta.table))
+	if where != "" {
+		query = fmt.Sprintf("%s WHERE %s", query, where)
+	}
+	return SelectSQL[T](ctx, r.querier(), query, args...)
+}
+
+// Update stores the fields named by cols, either field or column names, of the row with the pkey of element, or all non pkey fields without cols.
+// The field tagged `sqly:"updated"`, if any, is always touched and stored. Returns ErrNotFound if there is no such row.
+func (r *Repo[T]) Update(ctx context.Context, element *T, cols ...string) error {
+	val := reflect.ValueOf(element).Elem()
+	untouch := r.meta.touchUpdated(val)
+	if err := r.update(ctx, val, cols); err != nil {
+		untouch()
+		return err
+	}
+	return nil
+}
+
+func (r *Repo[T]) update(ctx context.Context, val reflect.Value, cols []string) error {
+	if err := r.meta.setContentHash(val); err != nil {
+		return err
+	}
+	fields := []*fieldMeta{}
+	for _, field := range r.meta.fields {
+		if field.pkey {
+			continue
+		}
+		named := len(cols) == 0 || field == r.meta.contentHash || field == r.meta.updated
+		for _, col := range cols {
+			named = named || col == field.name || col == field.col
+		}
+		if named {
+			fields = append(fields, field)
+		}
+	}
+	for _, col := range cols {
+		found := false
+		for _, field := range fields {
+			found = found || col == field.name || col == field.col
+		}
+		if !found {
+			return errors.Errorf("%v has no non pkey column %q", r.meta.typ, col)
+		}
+	}
+	if len(fields) == 0 {
+		return errors.Errorf("%v has no columns to update", r.meta.typ)
+	}
+	assignments := make([]string, len(fields))
+	params := make([]any, 0, len(fields)+len(r.meta.pkeys))
+	for index, field := range fields {
+		param, err := field.encode(val.Field(field.index))
+		if err != nil {
+			return err
+		}
+		assignments[index] = fmt.Sprintf("`%s` = ?", field.col)
+		params = append(params, param)
+	}
+	condition, pkeyParams, err := r.meta.pkeyCondition(val)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", quoteTable(r.meta.table), strings.Join(assignments, ", "), condition)
+	return r.write(ctx, func(tx *Tx) error {
+		res, err := tx.ExecContext(labeled(ctx, tx, "update", r.meta.typ.Name()), rebind(tx, query), append(params, pkeyParams...)...)
+		if err != nil {
+			return viewError(ctx, tx, r.meta, withStack(err))
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return withStack(err)
+		}
+		if affected == 0 {
+			return errors.WithStack(ErrNotFound)
+		}
+		return nil
+	})
+}
+
+// Delete deletes the row with the pkey values pkey, given in pkey field order, and does nothing if there is no such row.
+func (r *Repo[T]) Delete(ctx context.Context, pkey ...any) error {
+	condition, params, err := r.pkeyCondition(pkey)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", quoteTable(r.meta.table), condition)
+	return r.write(ctx, func(tx *Tx) error {
+		if _, err := tx.ExecContext(labeled(ctx, tx, "delete", r.meta.typ.Name()), rebind(tx, query), params...); err != nil {
+			return viewError(ctx, tx, r.meta, withStack(err))
+		}
+		return nil
+	})
+}
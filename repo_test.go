@@ -0,0 +1,135 @@
+package sqly
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type repoTestStruct struct {
+	Id    int64 `sqly:"pkey,autoinc"`
+	Name  string
+	Email string `sqly:"unique"`
+}
+
+type noPkeyRepoTestStruct struct {
+	Name string
+}
+
+func TestRepo(t *testing.T) {
+	withDB(t, func(db *DB) {
+		_, err := NewRepo[noPkeyRepoTestStruct](db)
+		yeserr(t, err)
+		repo, err := NewRepo[repoTestStruct](db)
+		noerr(t, err)
+		noerr(t, repo.EnsureTable(ctx))
+		user := &repoTestStruct{Name: "a", Email: "a@example.com"}
+		noerr(t, repo.Create(ctx, user))
+		if user.Id == 0 {
+			t.Errorf("wanted a new primary key, got 0")
+		}
+		yeserr(t, repo.Create(ctx, &repoTestStruct{Id: user.Id, Name: "dup"}))
+		got, err := repo.Get(ctx, user.Id)
+		noerr(t, err)
+		if got != *user {
+			t.Errorf("got %+v, wanted %+v", got, *user)
+		}
+		_, err = repo.Get(ctx, "not an id")
+		yeserr(t, err)
+		_, err = repo.Get(ctx, user.Id+1)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("got %v, wanted ErrNotFound", err)
+		}
+
+		user.Name = "b"
+		user.Email = "b@example.com"
+		noerr(t, repo.Update(ctx, user, "Name"))
+		got, err = repo.Get(ctx, user.Id)
+		noerr(t, err)
+		if got.Name != "b" || got.Email != "a@example.com" {
+			t.Errorf("got %+v, wanted only the name updated", got)
+		}
+		noerr(t, repo.Update(ctx, user))
+		found, err := repo.Find(ctx, "Email = ?", "b@example.com")
+		noerr(t, err)
+		if len(found) != 1 || found[0] != *user {
+			t.Errorf("got %+v, wanted %+v", found, *user)
+		}
+		yeserr(t, repo.Update(ctx, user, "Missing"))
+		yeserr(t, repo.Update(ctx, user, "Id"))
+		if err := repo.Update(ctx, &repoTestStruct{Id: user.Id + 1}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got %v, wanted ErrNotFound", err)
+		}
+
+		yeserr(t, db.Write(ctx, func(tx *Tx) error {
+			txRepo := repo.WithTx(tx)
+			noerr(t, txRepo.Create(ctx, &repoTestStruct{Name: "c", Email: "c@example.com"}))
+			noerr(t, txRepo.Delete(ctx, user.Id))
+			found, err := txRepo.Find(ctx, "")
+			noerr(t, err)
+			if len(found) != 1 || found[0].Name != "c" {
+				t.Errorf("got %+v, wanted the uncommitted changes", found)
+			}
+			return errors.New("rollback")
+		}))
+		found, err = repo.Find(ctx, "")
+		noerr(t, err)
+		if len(found) != 1 || found[0] != *user {
+			t.Errorf("got %+v, wanted the rolled back changes undone", found)
+		}
+		noerr(t, repo.Delete(ctx, user.Id))
+		noerr(t, repo.Delete(ctx, user.Id))
+		found, err = repo.Find(ctx, "")
+		noerr(t, err)
+		if len(found) != 0 {
+			t.Errorf("got %+v, wanted no rows", found)
+		}
+	})
+}
+
+type keyedRepoTestStruct struct {
+	Key  string `sqly:"pkey"`
+	Name string
+}
+
+type hashedRepoTestStruct struct {
+	Hash [4]byte `sqly:"pkey"`
+	Name string
+}
+
+func TestRepoPkeyArgs(t *testing.T) {
+	withDB(t, func(db *DB) {
+		repo, err := NewRepo[repoTestStruct](db)
+		noerr(t, err)
+		noerr(t, repo.EnsureTable(ctx))
+		user := &repoTestStruct{Name: "a"}
+		noerr(t, repo.Create(ctx, user))
+		if _, err := repo.Get(ctx, int(user.Id)); err != nil {
+			t.Errorf("got %v, wanted an int to widen to the int64 pkey", err)
+		}
+		_, err = repo.Get(ctx, float64(user.Id)+0.5)
+		yeserr(t, err)
+		_, err = repo.Get(ctx, uint(user.Id))
+		yeserr(t, err)
+
+		keyed, err := NewRepo[keyedRepoTestStruct](db)
+		noerr(t, err)
+		noerr(t, keyed.EnsureTable(ctx))
+		noerr(t, keyed.Create(ctx, &keyedRepoTestStruct{Key: "A"}))
+		_, err = keyed.Get(ctx, 65)
+		yeserr(t, err)
+		_, err = keyed.Get(ctx, "A")
+		noerr(t, err)
+
+		hashed, err := NewRepo[hashedRepoTestStruct](db)
+		noerr(t, err)
+		noerr(t, hashed.EnsureTable(ctx))
+		noerr(t, hashed.Create(ctx, &hashedRepoTestStruct{Hash: [4]byte{1, 2, 3, 4}}))
+		_, err = hashed.Get(ctx, []byte{1, 2, 3})
+		yeserr(t, err)
+		_, err = hashed.Get(ctx, []byte{1, 2, 3, 4})
+		noerr(t, err)
+		_, err = hashed.Get(ctx, [4]byte{1, 2, 3, 4})
+		noerr(t, err)
+	})
+}
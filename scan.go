@@ -0,0 +1,112 @@
+package sqly
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// scanDest returns the destination to scan the column of field into, which is a sql.Scanner for types database/sql can't scan into.
+func (field *fieldMeta) scanDest(fieldVal reflect.Value) any {
+	switch {
+	case isByteArray(field.typ):
+		return byteArrayScanner{field: fieldVal}
+	case isBigNum(field.typ):
+		return bigNumScanner{field: fieldVal}
+	case isBinary(field.typ):
+		return binaryScanner{field: fieldVal}
+	case field.nullScanned():
+		return nullScanner{field: fieldVal}
+	}
+	return fieldVal.Addr().Interface()
+}
+
+func (field *fieldMeta) customScanned() bool {
+	// sqlx finds the fields of cols using the NameMapper, which doesn't know about col tags.
+	return field.renamed || isByteArray(field.typ) || isBigNum(field.typ) || isBinary(field.typ) || field.nullScanned()
+}
+
+func (meta *tableMeta) customScanned() bool {
+	for _, field := range meta.fields {
+		if field.customScanned() {
+			return true
+		}
+	}
+	return false
+}
+
+// scanRow scans the current row of rows into val, using the scanDest of each field.
+func (meta *tableMeta) scanRow(rows *sqlx.Rows, val reflect.Value) error {
+	cols, err := rows.Columns()
+	if err != nil {
+		return withStack(err)
+	}
+	fieldsByCol := map[string]*fieldMeta{}
+	for _, field := range meta.fields {
+		fieldsByCol[field.col] = field
+	}
+	dests := make([]any, len(cols))
+	for colIndex, col := range cols {
+		field, found := fieldsByCol[col]
+		if !found {
+			return errors.Errorf("missing destination name %s in %v", col, meta.typ)
+		}
+		dests[colIndex] = field.scanDest(val.Field(field.index))
+	}
+	return withStack(rows.Scan(dests...))
+}
+
+// scanRowMeta returns the meta of T if T is a struct with fields sqlx can't scan, which need to be scanned by scanRow instead.
+func scanRowMeta[T any](querier any) *tableMeta {
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	meta, err := metasFor(querier).get(typ)
+	if err != nil || !meta.customScanned() {
+		return nil
+	}
+	return meta
+}
+
+// selectRows runs query and scans all rows into Ts using meta.scanRow.
+func selectRows[T any](ctx context.Context, q sqlx.QueryerContext, meta *tableMeta, query string, args ...any) ([]T, error) {
+	rows, err := q.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, withStack(err)
+	}
+	defer rows.Close()
+	result := []T{}
+	for rows.Next() {
+		var element T
+		if err := meta.scanRow(rows, reflect.ValueOf(&element).Elem()); err != nil {
+			return nil, err
+		}
+		result = append(result, element)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, withStack(err)
+	}
+	return result, nil
+}
+
+// getStruct runs query and scans the first resulting row into val, or returns ErrNotFound.
+func getStruct(ctx context.Context, q sqlx.QueryerContext, meta *tableMeta, val reflect.Value, query string, args ...any) error {
+	rows, err := q.QueryxContext(ctx, rebind(q, query), args...)
+	if err != nil {
+		return withStack(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return withStack(err)
+		}
+		return errors.WithStack(ErrNotFound)
+	}
+	if err := meta.scanRow(rows, val); err != nil {
+		return err
+	}
+	return meta.afterScan(val)
+}
@@ -0,0 +1,62 @@
+package sqly
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SchemaSQL works like the package level SchemaSQL for the driver of db, but uses the NameMapper and NamingStrategy of db.
+func (db *DB) SchemaSQL(prototypes ...any) (string, error) {
+	return schemaSQL(db, db.DriverName(), prototypes...)
+}
+
+// SchemaSQL renders the statements CreateTableIfNotExists would run for the prototypes on a fresh database of the driver named dialect,
+// using the default NameMapper and NamingStrategy, as a script with one statement per line.
+// The tables are ordered by name, and each is followed by its indices, triggers and extra DDL in the order they are declared.
+func SchemaSQL(dialect string, prototypes ...any) (string, error) {
+	return schemaSQL(nil, dialect, prototypes...)
+}
+
+func schemaSQL(x any, dialect string, prototypes ...any) (string, error) {
+	if !isSQLiteDriver(dialect) {
+		return "", errors.Errorf("dialect %q is not supported", dialect)
+	}
+	metasByTable := map[string]*tableMeta{}
+	for _, prototype := range prototypes {
+		meta, err := metaOf(x, prototype)
+		if err != nil {
+			return "", err
+		}
+		if other, found := metasByTable[meta.table]; found && other.typ != meta.typ {
+			return "", errors.Errorf("%v and %v both map to table %q", other.typ, meta.typ, meta.table)
+		}
+		metasByTable[meta.table] = meta
+	}
+	tables := make([]string, 0, len(metasByTable))
+	for table := range metasByTable {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	builder := &strings.Builder{}
+	for _, table := range tables {
+		meta := metasByTable[table]
+		statements := []string{meta.createTableSQL()}
+		for _, index := range meta.indices {
+			if err := index.validate(); err != nil {
+				return "", err
+			}
+			statements = append(statements, index.createSQL())
+		}
+		for _, trigger := range meta.triggers {
+			statements = append(statements, meta.createTriggerSQL(trigger))
+		}
+		statements = append(statements, meta.extraDDL(dialect)...)
+		for _, statement := range statements {
+			builder.WriteString(strings.TrimSuffix(strings.TrimSpace(statement), ";"))
+			builder.WriteString(";\n")
+		}
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,43 @@
+package sqly
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestSchemaSQL(t *testing.T) {
+	script, err := SchemaSQL("sqlite", testStruct{}, indexedTestStruct{}, testStruct{})
+	noerr(t, err)
+	golden := filepath.Join("testdata", "schema.sql")
+	if *updateGolden {
+		noerr(t, os.WriteFile(golden, []byte(script), 0644))
+	}
+	want, err := os.ReadFile(golden)
+	noerr(t, err)
+	if script != string(want) {
+		t.Errorf("got\n%s\nwanted\n%s\nrun go test -run TestSchemaSQL -update if the change is intended", script, want)
+	}
+	withDB(t, func(db *DB) {
+		for _, statement := range strings.Split(strings.TrimSpace(script), "\n") {
+			_, err := db.Exec(statement)
+			noerr(t, err)
+		}
+		diff, err := SchemaDiff(ctx, db, testStruct{}, indexedTestStruct{})
+		noerr(t, err)
+		if !diff.Empty() {
+			t.Errorf("got %+v, wanted the script to create the declared schema", diff)
+		}
+		dbScript, err := db.SchemaSQL(testStruct{}, indexedTestStruct{})
+		noerr(t, err)
+		if dbScript != script {
+			t.Errorf("got %q, wanted %q", dbScript, script)
+		}
+	})
+	_, err = SchemaSQL("postgres", testStruct{})
+	yeserr(t, err)
+}
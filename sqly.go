@@ -3,9 +3,13 @@ package sqly
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"hash"
+	"log/slog"
 	"reflect"
 	"regexp"
+	"runtime/debug"
 	"strings"
 	"sync"
 	"time"
@@ -22,6 +26,7 @@ func withStack(err error) error {
 	if err == nil {
 		return nil
 	}
+	err = classify(err)
 	if _, ok := err.(stackTracer); !ok {
 		return errors.WithStack(err)
 	}
@@ -30,7 +35,47 @@ func withStack(err error) error {
 
 type DB struct {
 	sqlx.DB
-	mutex sync.RWMutex
+	locker         locker
+	fifoLocking    bool
+	locking        *bool
+	nameMapper     NameMapper
+	namingStrategy NamingStrategy
+	tablePrefix    string
+	strict         bool
+	strictTags     bool
+	panicErrors    bool
+
+	strictIdentifiers bool
+
+	encryptionKey string
+	rekey         string
+
+	fingerprints bool
+	contentHash  func() hash.Hash
+	clock        func() time.Time
+
+	transformers map[string]Transformer
+	collations   map[string]bool
+	metas        *metaCache
+
+	writeQueueing  bool
+	writeQueueSize int
+	writeQueue     *writeQueue
+	batching       *coalescer
+	checkpointing  *periodic
+	optimizing     *periodic
+	middlewares    []Middleware
+	sqlComments    bool
+	errorMapper    ErrorMapper
+	handler        StatementHandler
+
+	longTxThreshold time.Duration
+	longTxCallback  func(LongTx)
+	logger          *slog.Logger
+
+	incrementalAutoVacuum bool
+
+	stats txStats
 }
 
 type SQLTime int64
@@ -43,32 +88,115 @@ func ToSQLTime(t time.Time) SQLTime {
 	return SQLTime(t.UnixNano())
 }
 
-func (db *DB) Write(ctx context.Context, f func(*Tx) error) error {
-	db.mutex.Lock()
-	defer db.mutex.Unlock()
-	tx, err := db.Beginy(ctx)
-	if err != nil {
-		return withStack(err)
+// SQLTimeText is a time.Time stored as RFC3339Nano TEXT. Zero times are stored as NULL, and NULL scans back to a zero time.
+type SQLTimeText time.Time
+
+func (d SQLTimeText) Time() time.Time {
+	return time.Time(d)
+}
+
+func ToSQLTimeText(t time.Time) SQLTimeText {
+	return SQLTimeText(t)
+}
+
+func (d SQLTimeText) Value() (driver.Value, error) {
+	if time.Time(d).IsZero() {
+		return nil, nil
 	}
-	if err := f(tx); err != nil {
-		if err := tx.Rollback(); err != nil {
+	return time.Time(d).Format(time.RFC3339Nano), nil
+}
+
+func (d *SQLTimeText) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*d = SQLTimeText{}
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
 			return withStack(err)
 		}
-		return withStack(err)
-	}
-	if err := tx.Commit(); err != nil {
-		return withStack(err)
+		*d = SQLTimeText(t)
+	case []byte:
+		return d.Scan(string(v))
+	case time.Time:
+		*d = SQLTimeText(v)
+	default:
+		return errors.Errorf("can't scan %T into SQLTimeText", src)
 	}
 	return nil
 }
 
-func (db *DB) Read(ctx context.Context, f func(*Tx) error) error {
-	db.mutex.RLock()
-	defer db.mutex.RUnlock()
-	tx, err := db.BeginTxy(ctx, &sql.TxOptions{ReadOnly: true})
+var (
+	sqlTimeTextType = reflect.TypeOf(SQLTimeText{})
+)
+
+func (db *DB) metaCache() *metaCache {
+	if db.metas == nil {
+		return defaultMetas
+	}
+	return db.metas
+}
+
+// PanicError is returned from Write and Read if the closure panicked and the DB was opened WithPanicErrors.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic in transaction: %v", p.Value)
+}
+
+// LongTx describes a transaction that has been running longer than the threshold given to WithLongTxThreshold.
+type LongTx struct {
+	ReadOnly bool
+	Duration time.Duration
+	Stack    []byte
+}
+
+func (db *DB) watchLongTx(opts *sql.TxOptions) func() {
+	if db.longTxThreshold <= 0 {
+		return func() {}
+	}
+	stack := debug.Stack()
+	start := time.Now()
+	timer := time.AfterFunc(db.longTxThreshold, func() {
+		db.longTxCallback(LongTx{
+			ReadOnly: opts != nil && opts.ReadOnly,
+			Duration: time.Since(start),
+			Stack:    stack,
+		})
+	})
+	return func() {
+		timer.Stop()
+	}
+}
+
+func (db *DB) inTx(ctx context.Context, opts *sql.TxOptions, f func(*Tx) error) (err error) {
+	defer func() {
+		err = db.mapError(err)
+	}()
+	defer db.watchLongTx(opts)()
+	start := time.Now()
+	tx, err := db.BeginTxy(ctx, opts)
 	if err != nil {
 		return withStack(err)
 	}
+	tx.readOnly = opts != nil && opts.ReadOnly
+	tx.ctx = NewContext(ctx, tx)
+	committed := false
+	defer func() {
+		db.stats.record(opts != nil && opts.ReadOnly, time.Since(start), !committed)
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			if !db.panicErrors {
+				panic(r)
+			}
+			err = withStack(&PanicError{Value: r, Stack: debug.Stack()})
+		}
+	}()
 	if err := f(tx); err != nil {
 		if err := tx.Rollback(); err != nil {
 			return withStack(err)
@@ -78,29 +206,165 @@ func (db *DB) Read(ctx context.Context, f func(*Tx) error) error {
 	if err := tx.Commit(); err != nil {
 		return withStack(err)
 	}
+	committed = true
 	return nil
 }
 
+// Write runs f in a transaction holding the write lock.
+// If ctx is done before the lock is acquired, an error matching ErrLockTimeout is returned.
+func (db *DB) Write(ctx context.Context, f func(*Tx) error) error {
+	if db.writeQueue != nil {
+		return db.mapError(db.writeQueue.submit(ctx, f))
+	}
+	if err := db.lockContext(ctx); err != nil {
+		return db.mapError(err)
+	}
+	defer db.locker.Unlock()
+	return db.inTx(ctx, nil, f)
+}
+
+// WriteAffected works like Write, but also returns the rows affected by the committed transaction, see Tx.Affected.
+func (db *DB) WriteAffected(ctx context.Context, f func(*Tx) error) (int64, error) {
+	affected := int64(0)
+	if err := db.Write(ctx, func(tx *Tx) error {
+		if err := f(tx); err != nil {
+			return err
+		}
+		affected = tx.Affected()
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+// Read runs f in a read only transaction holding the read lock, and gives up like Write if ctx is done first.
+func (db *DB) Read(ctx context.Context, f func(*Tx) error) error {
+	if err := db.rlockContext(ctx); err != nil {
+		return db.mapError(err)
+	}
+	defer db.locker.RUnlock()
+	return db.inTx(ctx, &sql.TxOptions{ReadOnly: true}, f)
+}
+
+// ExecLocked runs query holding the write lock, like Write, to serialize statements sqly can't express, like custom DDL, with the other writes.
+// The statement doesn't run in a transaction; to run it atomically with other statements, use tx.ExecContext inside Write instead,
+// since calling ExecLocked inside Write would wait for the lock held by the Write itself.
+// Unlike the embedded sqlx Exec, which ignores the lock, it gives up like Write if ctx is done first, and like GetSQL it rebinds ? placeholders.
+func (db *DB) ExecLocked(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if err := db.lockContext(ctx); err != nil {
+		return nil, db.mapError(err)
+	}
+	defer db.locker.Unlock()
+	result, err := db.ExecContext(labeled(ctx, db, "exec", ""), rebind(db, query), args...)
+	if err != nil {
+		return nil, db.mapError(withStack(err))
+	}
+	return result, nil
+}
+
 func (db *DB) Upsert(ctx context.Context, structPointer any, overwrite bool) error {
-	return Upsert(ctx, db, structPointer, overwrite)
+	return db.mapError(Upsert(ctx, db, structPointer, overwrite))
 }
 
 func (db *DB) CreateTableIfNotExists(ctx context.Context, prototype any) error {
 	return CreateTableIfNotExists(ctx, db, prototype)
 }
 
+func (db *DB) CreateTableIfNotExistsVerbose(ctx context.Context, prototype any) ([]string, error) {
+	return CreateTableIfNotExistsVerbose(ctx, db, prototype)
+}
+
+// BeginTxy begins a transaction on a connection of its own.
+// With the modernc driver, the statements running on the connection are interrupted when ctx is done, so that they stop promptly and release the transaction.
 func (db *DB) BeginTxy(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
-	tx, err := db.BeginTxx(ctx, opts)
+	conn, err := db.Connx(ctx)
 	if err != nil {
 		return nil, withStack(err)
 	}
-	return &Tx{*tx}, nil
+	var interrupt func()
+	if err := conn.Raw(func(driverConn any) error {
+		interrupt = connInterrupter(driverConn)
+		return nil
+	}); err != nil {
+		conn.Close()
+		return nil, withStack(err)
+	}
+	tx, err := conn.BeginTxx(ctx, opts)
+	if err != nil {
+		conn.Close()
+		return nil, withStack(err)
+	}
+	result := &Tx{Tx: *tx, db: db, conn: conn, stopInterrupts: interruptOnDone(ctx, interrupt)}
+	if len(db.middlewares) > 0 {
+		result.handler = chain(StatementHandlerFuncs{
+			Exec: func(ctx context.Context, label string, query string, args ...any) (sql.Result, error) {
+				return result.Tx.ExecContext(ctx, query, args...)
+			},
+			Query: func(ctx context.Context, label string, query string, args ...any) (*sqlx.Rows, error) {
+				return result.Tx.QueryxContext(ctx, query, args...)
+			},
+		}, db.middlewares)
+	}
+	return result, nil
 }
 
 func (db *DB) Beginy(ctx context.Context) (*Tx, error) {
 	return db.BeginTxy(ctx, nil)
 }
 
+func (db *DB) Pingy(ctx context.Context) error {
+	return withStack(db.PingContext(ctx))
+}
+
+type HealthCheckStage string
+
+const (
+	HealthCheckPing  HealthCheckStage = "ping"
+	HealthCheckLock  HealthCheckStage = "lock"
+	HealthCheckBegin HealthCheckStage = "begin"
+	HealthCheckQuery HealthCheckStage = "query"
+)
+
+type HealthCheckError struct {
+	Stage HealthCheckStage
+	Err   error
+}
+
+func (h *HealthCheckError) Error() string {
+	return fmt.Sprintf("health check failed at %s stage: %v", h.Stage, h.Err)
+}
+
+func (h *HealthCheckError) Unwrap() error {
+	return h.Err
+}
+
+// HealthCheck pings the database, and then runs a trivial query in a read transaction, all bounded by ctx.
+// Failures are returned as a *HealthCheckError identifying the failing stage.
+func (db *DB) HealthCheck(ctx context.Context) error {
+	return db.mapError(db.healthCheck(ctx))
+}
+
+func (db *DB) healthCheck(ctx context.Context) error {
+	if err := db.Pingy(ctx); err != nil {
+		return withStack(&HealthCheckError{Stage: HealthCheckPing, Err: err})
+	}
+	if err := db.rlockContext(ctx); err != nil {
+		return withStack(&HealthCheckError{Stage: HealthCheckLock, Err: err})
+	}
+	defer db.locker.RUnlock()
+	tx, err := db.BeginTxy(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return withStack(&HealthCheckError{Stage: HealthCheckBegin, Err: err})
+	}
+	defer tx.Rollback()
+	one := 0
+	if err := tx.GetContext(ctx, &one, "SELECT 1"); err != nil {
+		return withStack(&HealthCheckError{Stage: HealthCheckQuery, Err: err})
+	}
+	return nil
+}
+
 type isTxer interface {
 	isTx()
 }
@@ -110,221 +374,701 @@ func IsTx(db sqlx.ExtContext) bool {
 	return is
 }
 
+// Tx is a transaction of a DB.
+// Reads through the Tx, like tx.GetContext or passing it as the querier of GetSQL, SelectSQL, SelectByExample or Refresh, see the uncommitted
+// writes of the transaction. Reads through the DB, like db.GetContext, use another connection and don't, and when the DB uses locking,
+// sqly helpers given the DB inside a Write wait for a Read that can't start until the Write is done.
 type Tx struct {
 	sqlx.Tx
+	db *DB
+
+	stmtsLock sync.Mutex
+	stmts     map[txStmtKey]*sqlx.Stmt
+	affected  int64
+	handler   StatementHandler
+
+	conn           *sqlx.Conn
+	stopInterrupts func()
+
+	ctx      context.Context
+	readOnly bool
+	finished bool
+	joins    int
+}
+
+type txStmtKey struct {
+	typ reflect.Type
+	insertKey
+}
+
+// insertStmt returns a statement for the INSERT of meta prepared on the transaction, which is reused until it is committed or rolled back.
+func (tx *Tx) insertStmt(ctx context.Context, meta *tableMeta, insert insertKey) (*sqlx.Stmt, error) {
+	key := txStmtKey{typ: meta.typ, insertKey: insert}
+	tx.stmtsLock.Lock()
+	defer tx.stmtsLock.Unlock()
+	if stmt, found := tx.stmts[key]; found {
+		return stmt, nil
+	}
+	stmt, err := tx.PreparexContext(ctx, meta.insertSQL(insert))
+	if err != nil {
+		return nil, withStack(err)
+	}
+	if tx.stmts == nil {
+		tx.stmts = map[txStmtKey]*sqlx.Stmt{}
+	}
+	tx.stmts[key] = stmt
+	return stmt, nil
+}
+
+func (tx *Tx) closeStmts() {
+	tx.stmtsLock.Lock()
+	defer tx.stmtsLock.Unlock()
+	for _, stmt := range tx.stmts {
+		stmt.Close()
+	}
+	tx.stmts = nil
+}
+
+// tally adds the rows affected by res to the transaction.
+func (tx *Tx) tally(res sql.Result) {
+	if affected, err := res.RowsAffected(); err == nil {
+		tx.affected += affected
+	}
+}
+
+// dmlRegexp matches statements that can change rows, which start with INSERT, REPLACE, UPDATE or DELETE, possibly after comments and a WITH clause.
+var dmlRegexp = regexp.MustCompile(`(?is)^(\s|--[^\n]*\n|/\*.*?\*/)*(INSERT|REPLACE|UPDATE|DELETE|WITH\b.*\b(INSERT|REPLACE|UPDATE|DELETE))\b`)
+
+// ExecContext runs query in the transaction through the middlewares, and adds the rows it affected by DML to Affected.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var res sql.Result
+	var err error
+	if tx.handler == nil {
+		res, err = tx.Tx.ExecContext(ctx, query, args...)
+	} else {
+		res, err = tx.handler.ExecContext(ctx, labelOf(ctx), query, args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// SQLite reports the changes of the previous DML as the rows affected by other statements, like SAVEPOINT or CREATE TABLE.
+	if dmlRegexp.MatchString(query) {
+		tx.tally(res)
+	}
+	return res, nil
+}
+
+// Affected returns the total rows affected by the DML statements run through ExecContext and the sqly helpers of the transaction,
+// including those later rolled back to a savepoint. Other statements, like DDL and savepoints, don't count.
+func (tx *Tx) Affected() int64 {
+	return tx.affected
+}
+
+// Commit closes the statements prepared by the transaction, and commits it.
+func (tx *Tx) Commit() error {
+	tx.finished = true
+	tx.closeStmts()
+	defer tx.release()
+	return tx.Tx.Commit()
+}
+
+// Rollback closes the statements prepared by the transaction, and rolls it back.
+func (tx *Tx) Rollback() error {
+	tx.finished = true
+	tx.closeStmts()
+	defer tx.release()
+	return tx.Tx.Rollback()
 }
 
 func (tx *Tx) isTx() {
 }
 
+func (tx *Tx) metaCache() *metaCache {
+	if tx.db == nil {
+		return defaultMetas
+	}
+	return tx.db.metaCache()
+}
+
 func (tx *Tx) Upsert(ctx context.Context, structPointer any, overwrite bool) error {
-	return Upsert(ctx, tx, structPointer, overwrite)
+	return tx.db.mapError(Upsert(ctx, tx, structPointer, overwrite))
 }
 
 func (tx *Tx) CreateTableIfNotExists(ctx context.Context, prototype any) error {
-	return CreateTableIfNotExists(ctx, tx, prototype)
+	return tx.db.mapError(CreateTableIfNotExists(ctx, tx, prototype))
+}
+
+func (tx *Tx) CreateTableIfNotExistsVerbose(ctx context.Context, prototype any) ([]string, error) {
+	executed, err := CreateTableIfNotExistsVerbose(ctx, tx, prototype)
+	return executed, tx.db.mapError(err)
+}
+
+// SavepointExec runs f inside a SAVEPOINT called name.
+// If f fails the changes it made are rolled back, while the rest of the transaction is unaffected, and the error of f is returned.
+// Savepoints can be nested by calling SavepointExec with different names inside f.
+func (tx *Tx) SavepointExec(ctx context.Context, name string, f func(*Tx) error) error {
+	return tx.db.mapError(tx.savepointExec(ctx, name, f))
+}
+
+func (tx *Tx) savepointExec(ctx context.Context, name string, f func(*Tx) error) error {
+	if err := validIdentifier(name); err != nil {
+		return err
+	}
+	ctx = labeled(ctx, tx, "savepoint", name)
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SAVEPOINT `%s`", name)); err != nil {
+		return withStack(err)
+	}
+	if err := f(tx); err != nil {
+		if _, rollbackErr := tx.ExecContext(ctx, fmt.Sprintf("ROLLBACK TO `%s`", name)); rollbackErr != nil {
+			return withStack(rollbackErr)
+		}
+		if _, releaseErr := tx.ExecContext(ctx, fmt.Sprintf("RELEASE `%s`", name)); releaseErr != nil {
+			return withStack(releaseErr)
+		}
+		return withStack(err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("RELEASE `%s`", name)); err != nil {
+		return withStack(err)
+	}
+	return nil
 }
 
 type StackTracer interface {
 	StackTrace() errors.StackTrace
 }
 
-func Open(driverName string, dataSourceName string) (*DB, error) {
+type Option func(*DB) error
+
+func WithNameMapper(mapper NameMapper) Option {
+	return func(db *DB) error {
+		db.nameMapper = mapper
+		return nil
+	}
+}
+
+// WithTablePrefix prepends prefix to every table and index name sqly generates.
+// Raw SQL is left untouched.
+func WithTablePrefix(prefix string) Option {
+	return func(db *DB) error {
+		if err := validIdentifier(prefix); err != nil {
+			return err
+		}
+		db.tablePrefix = prefix
+		return nil
+	}
+}
+
+// WithStrictTables makes CreateTableIfNotExists create all new tables as STRICT tables, unless the struct opts out by implementing StrictTabler.
+// STRICT tables require SQLite 3.37 or later.
+func WithStrictTables() Option {
+	return func(db *DB) error {
+		db.strict = true
+		return nil
+	}
+}
+
+// WithStrictTags makes planning a struct type fail if any of its fields have sqly tags that aren't recognized, instead of ignoring them.
+func WithStrictTags() Option {
+	return func(db *DB) error {
+		db.strictTags = true
+		return nil
+	}
+}
+
+// WithPanicErrors makes Write and Read return a *PanicError when their closure panics, instead of rolling back and re-panicking.
+func WithPanicErrors() Option {
+	return func(db *DB) error {
+		db.panicErrors = true
+		return nil
+	}
+}
+
+// WithLongTxThreshold makes Write and Read call callback, once, as soon as a transaction has been running longer than threshold.
+// The stack of the caller starting the transaction is included to be able to find hung transactions.
+func WithLongTxThreshold(threshold time.Duration, callback func(LongTx)) Option {
+	return func(db *DB) error {
+		db.longTxThreshold = threshold
+		db.longTxCallback = callback
+		return nil
+	}
+}
+
+// WithLogger makes the DB log its maintenance work, like Vacuum, to logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(db *DB) error {
+		db.logger = logger
+		return nil
+	}
+}
+
+func (db *DB) log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if db.logger != nil {
+		db.logger.Log(ctx, level, msg, args...)
+	}
+}
+
+// WithFIFOLocking makes Write and Read acquire the lock in arrival order, with consecutive Reads sharing it, to avoid starving writers under heavy read load.
+func WithFIFOLocking() Option {
+	return func(db *DB) error {
+		db.fifoLocking = true
+		return nil
+	}
+}
+
+// WithLocking overrides whether Write and Read serialize through the internal lock.
+// By default the lock is only used for SQLite drivers, since other backends handle concurrent transactions themselves.
+func WithLocking(locking bool) Option {
+	return func(db *DB) error {
+		db.locking = &locking
+		return nil
+	}
+}
+
+// WithCollation allows fields tagged `sqly:"collate=name"` to use a collation registered with the driver, e.g. using sqlite.RegisterCollationUtf8.
+// BINARY, NOCASE and RTRIM are always allowed.
+func WithCollation(name string) Option {
+	return func(db *DB) error {
+		if err := validIdentifier(name); err != nil {
+			return errors.Wrap(err, "invalid collation name")
+		}
+		if db.collations == nil {
+			db.collations = map[string]bool{}
+		}
+		db.collations[strings.ToUpper(name)] = true
+		return nil
+	}
+}
+
+func Open(driverName string, dataSourceName string, opts ...Option) (*DB, error) {
 	db, err := sqlx.Open(driverName, dataSourceName)
 	if err != nil {
 		return nil, err
 	}
-	db.MapperFunc(func(s string) string { return s })
-	return &DB{DB: *db}, nil
+	result := &DB{DB: *db}
+	for _, opt := range opts {
+		if err := opt(result); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if err := result.setupEncryption(context.Background(), dataSourceName); err != nil {
+		result.DB.Close()
+		return nil, err
+	}
+	locking := isSQLiteDriver(driverName)
+	if result.locking != nil {
+		locking = *result.locking
+	}
+	switch {
+	case !locking:
+		result.locker = noopLocker{}
+	case result.fifoLocking:
+		result.locker = &fifoLocker{}
+	default:
+		result.locker = &sync.RWMutex{}
+	}
+	mapper := result.nameMapper
+	if mapper == nil {
+		mapper = identityMapper{}
+	}
+	naming := result.namingStrategy
+	if naming == nil {
+		naming = defaultNaming{}
+	}
+	result.metas = &metaCache{
+		mapper:            mapper,
+		naming:            naming,
+		prefix:            result.tablePrefix,
+		strictTables:      result.strict,
+		strictTags:        result.strictTags,
+		strictIdentifiers: result.strictIdentifiers,
+		fingerprints:      result.fingerprints,
+		contentHash:       result.contentHash,
+		clock:             result.clock,
+		transformers:      result.transformers,
+		collations:        result.collations,
+	}
+	result.MapperFunc(result.metaCache().mapper.ColumnName)
+	if result.sqlComments {
+		result.middlewares = append([]Middleware{commentMiddleware}, result.middlewares...)
+	}
+	if len(result.middlewares) > 0 {
+		result.handler = chain(StatementHandlerFuncs{
+			Exec: func(ctx context.Context, label string, query string, args ...any) (sql.Result, error) {
+				return result.DB.ExecContext(ctx, query, args...)
+			},
+			Query: func(ctx context.Context, label string, query string, args ...any) (*sqlx.Rows, error) {
+				return result.DB.QueryxContext(ctx, query, args...)
+			},
+		}, result.middlewares)
+	}
+	// The setup runs before the write queue, batching and periodics start, so that a failure only has the database to close.
+	if result.incrementalAutoVacuum {
+		if err := result.setupIncrementalAutoVacuum(context.Background()); err != nil {
+			result.DB.Close()
+			return nil, err
+		}
+	}
+	if result.writeQueueing {
+		result.writeQueue = newWriteQueue(result, result.writeQueueSize)
+	}
+	if result.batching != nil {
+		result.batching.start(result)
+	}
+	if result.checkpointing != nil {
+		result.checkpointing.start()
+	}
+	if result.optimizing != nil {
+		result.optimizing.start()
+	}
+	return result, nil
+}
+
+// RetryPolicy controls how OpenWait retries.
+// Zero MaxAttempts means retrying until the context expires.
+// Nil Retryable means retrying every error, except for SQLite drivers where only busy and locked errors are retried.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Retryable      func(error) bool
 }
 
+func isSQLiteDriver(driverName string) bool {
+	return driverName == "sqlite" || driverName == "sqlite3"
+}
+
+// OpenWait opens the database and pings it, retrying according to policy until it succeeds or ctx expires.
+func OpenWait(ctx context.Context, driverName string, dataSourceName string, policy RetryPolicy, opts ...Option) (*DB, error) {
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = func(error) bool { return true }
+		if isSQLiteDriver(driverName) {
+			retryable = isSQLiteBusyOrLocked
+		}
+	}
+	backoff := policy.InitialBackoff
+	if backoff <= 0 {
+		backoff = 100 * time.Millisecond
+	}
+	maxBackoff := policy.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 10 * time.Second
+	}
+	for attempt := 1; ; attempt++ {
+		db, err := Open(driverName, dataSourceName, opts...)
+		if err == nil {
+			if err = db.Pingy(ctx); err == nil {
+				return db, nil
+			}
+			db.Close()
+		}
+		if !retryable(err) || (policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts) {
+			return nil, errors.Wrapf(err, "opening %q failed after %d attempts", driverName, attempt)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrapf(err, "opening %q failed after %d attempts: %v", driverName, attempt, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff = min(maxBackoff, backoff*2)
+	}
+}
+
+// Upsert inserts structPointer, replacing any conflicting row if overwrite is true.
+// The column list of the generated INSERT always follows the declaration order of the struct fields, so the SQL is stable across calls and versions.
 func Upsert(ctx context.Context, execer sqlx.ExecerContext, structPointer any, overwrite bool) error {
+	val, meta, err := structPointerMeta(execer, structPointer)
+	if err != nil {
+		return err
+	}
+	_, err = insertStruct(labeled(ctx, execer, "upsert", meta.typ.Name()), execer, meta, val, meta.conflictClause(overwrite))
+	return err
+}
+
+func structPointerMeta(x any, structPointer any) (reflect.Value, *tableMeta, error) {
 	val := reflect.ValueOf(structPointer)
 	if val.Kind() != reflect.Ptr {
-		return errors.Errorf("%v is not a reflect.Ptr", structPointer)
+		return reflect.Value{}, nil, errors.Errorf("%v is not a reflect.Ptr", structPointer)
 	}
 	val = val.Elem()
 	if val.Kind() != reflect.Struct {
-		return errors.Errorf("%v is not a pointer to a reflect.Struct", structPointer)
+		return reflect.Value{}, nil, errors.Errorf("%v is not a pointer to a reflect.Struct", structPointer)
+	}
+	meta, err := metasFor(x).get(val.Type())
+	if err != nil {
+		return reflect.Value{}, nil, err
+	}
+	return val, meta, nil
+}
+
+// onConflictDoNothing is a conflict clause skipping rows that violate a uniqueness constraint, unlike OR IGNORE which also skips
+// rows violating NOT NULL and CHECK constraints. It goes after the VALUES instead of before the INTO.
+const onConflictDoNothing = "ON CONFLICT DO NOTHING"
+
+type insertKey struct {
+	conflict       string
+	omitPrimaryKey bool
+	// omitted has the omitBits of the omitempty fields left out of the INSERT.
+	omitted uint64
+}
+
+// omits returns whether the INSERT of key leaves field out.
+func (key insertKey) omits(field *fieldMeta) bool {
+	return (key.omitPrimaryKey && field.pkey) || key.omitted&field.omitBit != 0
+}
+
+// insertSQL returns the INSERT statement for the fields of meta, which is rendered once per combination of conflict clause and omitted fields.
+func (meta *tableMeta) insertSQL(key insertKey) string {
+	meta.insertSQLsLock.RLock()
+	query, found := meta.insertSQLs[key]
+	meta.insertSQLsLock.RUnlock()
+	if found {
+		return query
 	}
-	typ := val.Type()
 	cols := []string{}
 	qmarks := []string{}
-	params := []any{}
-	var primaryKeyFieldToSet *reflect.Value
-	for fieldIndex := 0; fieldIndex < typ.NumField(); fieldIndex++ {
-		field := typ.Field(fieldIndex)
-		skip := false
-		if field.IsExported() {
-			for _, tag := range strings.Split(field.Tag.Get("sqly"), ",") {
-				fieldVal := val.Field(fieldIndex)
-				if tag == "pkey" && fieldVal.CanInt() && fieldVal.Int() == 0 {
-					primaryKeyFieldToSet = &fieldVal
-					skip = true
-				}
-			}
-			if !skip {
-				cols = append(cols, fmt.Sprintf("`%s`", field.Name))
-				qmarks = append(qmarks, "?")
-				params = append(params, val.Field(fieldIndex).Interface())
-			}
+	for _, field := range meta.fields {
+		if key.omits(field) {
+			continue
 		}
+		cols = append(cols, fmt.Sprintf("`%s`", field.col))
+		qmarks = append(qmarks, "?")
+	}
+	prefix, suffix := key.conflict, ""
+	if key.conflict == onConflictDoNothing {
+		prefix, suffix = "", " "+onConflictDoNothing
+	}
+	query = fmt.Sprintf("INSERT %sINTO %s (%s) VALUES (%s)%s", prefix, quoteTable(meta.table), strings.Join(cols, ","), strings.Join(qmarks, ","), suffix)
+	meta.insertSQLsLock.Lock()
+	defer meta.insertSQLsLock.Unlock()
+	if meta.insertSQLs == nil {
+		meta.insertSQLs = map[insertKey]string{}
+	}
+	meta.insertSQLs[key] = query
+	return query
+}
+
+var (
+	paramsPool = sync.Pool{
+		New: func() any {
+			return &[]any{}
+		},
+	}
+)
+
+// insertStruct inserts val using INSERT [conflict]INTO, and returns whether a row was inserted.
+// The pkey of val is set from the inserted row if val needed one, and the updated field is touched unless no row was inserted.
+func insertStruct(ctx context.Context, execer sqlx.ExecerContext, meta *tableMeta, val reflect.Value, conflict string) (bool, error) {
+	untouch := meta.touchUpdated(val)
+	inserted, err := insertRow(ctx, execer, meta, val, conflict, meta.needsPrimaryKey(val))
+	if err != nil || !inserted {
+		untouch()
 	}
-	replace := ""
-	if overwrite {
-		replace = "OR REPLACE "
+	return inserted, err
+}
+
+// insertRow works like insertStruct, but only leaves the pkey to be generated if setPrimaryKey is true.
+func insertRow(ctx context.Context, execer sqlx.ExecerContext, meta *tableMeta, val reflect.Value, conflict string, setPrimaryKey bool) (bool, error) {
+	if err := meta.checkPrimaryKey(val); err != nil {
+		return false, err
 	}
-	res, err := execer.ExecContext(ctx, fmt.Sprintf("INSERT %sINTO `%s` (%s) VALUES (%s)", replace, typ.Name(), strings.Join(cols, ","), strings.Join(qmarks, ",")), params...)
+	if err := meta.setContentHash(val); err != nil {
+		return false, err
+	}
+	insert := insertKey{conflict: conflict, omitPrimaryKey: setPrimaryKey, omitted: meta.omittedFields(val)}
+	pooled := paramsPool.Get().(*[]any)
+	defer func() {
+		clear(*pooled)
+		*pooled = (*pooled)[:0]
+		paramsPool.Put(pooled)
+	}()
+	for _, field := range meta.fields {
+		if insert.omits(field) {
+			continue
+		}
+		param, err := field.encode(val.Field(field.index))
+		if err != nil {
+			return false, err
+		}
+		*pooled = append(*pooled, param)
+	}
+	var res sql.Result
+	if tx, ok := execer.(*Tx); ok && tx.handler == nil {
+		stmt, err := tx.insertStmt(ctx, meta, insert)
+		if err != nil {
+			return false, viewError(ctx, execer, meta, err)
+		}
+		res, err = stmt.ExecContext(ctx, *pooled...)
+		if err != nil {
+			return false, viewError(ctx, execer, meta, withStack(err))
+		}
+		tx.tally(res)
+	} else {
+		var err error
+		if res, err = execer.ExecContext(ctx, meta.insertSQL(insert), *pooled...); err != nil {
+			return false, viewError(ctx, execer, meta, withStack(err))
+		}
+	}
+	affected, err := res.RowsAffected()
 	if err != nil {
-		return withStack(err)
+		return false, withStack(err)
 	}
-	if primaryKeyFieldToSet != nil {
+	if affected == 0 {
+		return false, nil
+	}
+	if setPrimaryKey {
 		lastID, err := res.LastInsertId()
 		if err != nil {
-			return withStack(err)
+			return false, withStack(err)
 		}
-		primaryKeyFieldToSet.SetInt(lastID)
+		val.Field(meta.pkey.index).SetInt(lastID)
 	}
-	return nil
+	return true, nil
 }
 
-type index struct {
-	cols   []string
-	unique bool
+func CreateTableIfNotExists(ctx context.Context, execer sqlx.ExecerContext, prototype any) error {
+	_, err := CreateTableIfNotExistsVerbose(ctx, execer, prototype)
+	return err
 }
 
-var (
-	uniqueWithRegexp = regexp.MustCompile(`uniqueWith\((.*)\)`)
-	indexWithRegexp  = regexp.MustCompile(`indexWith\((.*)\)`)
-)
-
-func CreateTableIfNotExists(ctx context.Context, execer sqlx.ExecerContext, prototype any) error {
-	val := reflect.ValueOf(prototype)
-	if val.Kind() != reflect.Struct {
-		return errors.Errorf("%v is not a reflect.Struct", prototype)
+// CreateTableIfNotExistsVerbose works like CreateTableIfNotExists, but returns the statements that were executed.
+// If execer can't be used to query the existing columns of the table, ALTER TABLE statements for columns that already existed are attempted but not included.
+// If execer is a *DB, everything runs in a single Write, so concurrent initializers don't interleave.
+// Indices and triggers that an existing table already has are skipped, so an up to date table only costs queries, except for the
+// statements of an ExtraDDLer, which always run.
+// The statements maintaining the fingerprints of WithSchemaFingerprints, and those run by AfterCreate, aren't included.
+func CreateTableIfNotExistsVerbose(ctx context.Context, execer sqlx.ExecerContext, prototype any) ([]string, error) {
+	if db, ok := execer.(*DB); ok {
+		var executed []string
+		err := db.Write(ctx, func(tx *Tx) error {
+			var err error
+			executed, err = CreateTableIfNotExistsVerbose(ctx, tx, prototype)
+			return err
+		})
+		return executed, err
 	}
-	typ := val.Type()
-	primaryKeyCol := ""
-	primaryKeySQLType := ""
-	pkeyAutoInc := ""
-	cols := []string{}
-	sqlTypes := []string{}
-	indices := []index{}
-	for fieldIndex := 0; fieldIndex < typ.NumField(); fieldIndex++ {
-		field := typ.Field(fieldIndex)
-		if field.IsExported() {
-			sqlType := ""
-			switch field.Type.Kind() {
-			case reflect.String:
-				sqlType = "TEXT"
-			case reflect.Uint:
-				fallthrough
-			case reflect.Uint8:
-				fallthrough
-			case reflect.Uint16:
-				fallthrough
-			case reflect.Uint32:
-				fallthrough
-			case reflect.Uint64:
-				fallthrough
-			case reflect.Int:
-				fallthrough
-			case reflect.Int8:
-				fallthrough
-			case reflect.Int16:
-				fallthrough
-			case reflect.Int32:
-				fallthrough
-			case reflect.Int64:
-				sqlType = "INTEGER"
-			case reflect.Float32:
-				sqlType = "REAL"
-			case reflect.Float64:
-				sqlType = "REAL"
-			case reflect.Bool:
-				sqlType = "INTEGER"
-			case reflect.Slice:
-				if field.Type.Elem().Kind() == reflect.Uint8 {
-					sqlType = "BLOB"
-				} else {
-					return errors.Errorf("%v isn't of a supported slice type", field.Type.Elem())
-				}
-			default:
-				return errors.Errorf("%v isn't of a supported type", field)
+	meta, err := metaOf(execer, prototype)
+	if err != nil {
+		return nil, err
+	}
+	if err := meta.requirePrimaryKey(); err != nil {
+		return nil, err
+	}
+	ctx = labeled(ctx, execer, "createTable", meta.typ.Name())
+	metas := metasFor(execer)
+	fingerprint := ""
+	if queryer, ok := execer.(sqlx.QueryerContext); ok && metas.fingerprints {
+		fingerprint = meta.fingerprint(driverNameOf(execer))
+		stored, err := metas.storedFingerprint(ctx, queryer, driverNameOf(execer), meta.table)
+		if err != nil {
+			return nil, err
+		}
+		if stored == fingerprint {
+			return []string{}, nil
+		}
+	}
+	executed := []string{}
+	exec := func(stmt string) error {
+		if _, err := execer.ExecContext(ctx, stmt); err != nil {
+			return withStack(err)
+		}
+		executed = append(executed, stmt)
+		return nil
+	}
+	// Without a queryer there's no telling whether the table existed, so it's considered created.
+	created := true
+	queryer, ok := execer.(sqlx.QueryerContext)
+	if !ok {
+		// Without a way to introspect the table, rely on CREATE TABLE IF NOT EXISTS and ignoring duplicate column errors.
+		if err := exec(meta.createSkeletonSQL()); err != nil {
+			return executed, err
+		}
+		for _, field := range meta.fields {
+			if field.pkey {
+				continue
+			}
+			if err := exec(meta.addColumnSQL(field)); err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+				return executed, err
 			}
-			isPkey := false
-			autoIncrement := false
-			for _, tag := range strings.Split(field.Tag.Get("sqly"), ",") {
-				switch tag {
-				case "unique":
-					indices = append(indices, index{
-						cols:   []string{field.Name},
-						unique: true,
-					})
-				case "index":
-					indices = append(indices, index{
-						cols:   []string{field.Name},
-						unique: false,
-					})
-				case "pkey":
-					isPkey = true
-					primaryKeyCol = field.Name
-					primaryKeySQLType = sqlType
-				case "autoinc":
-					autoIncrement = true
-				default:
-					if match := uniqueWithRegexp.FindStringSubmatch(tag); match != nil {
-						indices = append(indices, index{
-							cols:   append([]string{field.Name}, strings.Split(match[1], ";")...),
-							unique: true,
-						})
-					} else if match = indexWithRegexp.FindStringSubmatch(tag); match != nil {
-						indices = append(indices, index{
-							cols:   append([]string{field.Name}, strings.Split(match[1], ";")...),
-							unique: false,
-						})
-					}
+		}
+	} else {
+		existing, err := existingColumns(ctx, queryer, driverNameOf(execer), meta.table)
+		if err != nil {
+			return executed, err
+		}
+		created = len(existing) == 0
+		if created {
+			if err := exec(meta.createTableSQL()); err != nil {
+				return executed, err
+			}
+		} else {
+			missing, err := meta.missingChecks(ctx, queryer, driverNameOf(execer))
+			if err != nil {
+				return executed, err
+			}
+			if len(missing) > 0 {
+				return executed, errors.Errorf("existing table %q lacks the table checks %q of %v, which can't be added to existing tables", meta.table, missing, meta.typ)
+			}
+			for _, field := range meta.fields {
+				if _, found := existing[field.col]; found || field.pkey {
+					continue
 				}
-				if isPkey {
-					if autoIncrement {
-						if sqlType != "INTEGER" {
-							return errors.Errorf("col %q can't be autoinc pkey if it's not an INTEGER type", field.Name)
-						}
-						pkeyAutoInc = " AUTOINCREMENT"
-					}
-				} else {
-					if autoIncrement {
-						return errors.Errorf("col %q can't be autoinc if it's not also pkey", field.Name)
-					}
-					cols = append(cols, field.Name)
-					sqlTypes = append(sqlTypes, sqlType)
+				if err := exec(meta.addColumnSQL(field)); err != nil {
+					return executed, err
 				}
 			}
 		}
 	}
-	if primaryKeyCol == "" {
-		return errors.Errorf("%v doesn't have a PRIMARY KEY (field tagged `sqly:\"pkey\"`)", prototype)
+	// The indices and triggers an existing table already has are skipped, so that an up to date table costs no writes.
+	existingIndexNames, existingTriggerNames := map[string]bool{}, map[string]bool{}
+	if ok && !created {
+		names, err := existingIndices(ctx, queryer, driverNameOf(execer), meta.table)
+		if err != nil {
+			return executed, err
+		}
+		for _, name := range names {
+			existingIndexNames[name] = true
+		}
+		if len(meta.triggers) > 0 {
+			if existingTriggerNames, err = existingTriggers(ctx, queryer, driverNameOf(execer), meta.table); err != nil {
+				return executed, err
+			}
+		}
 	}
-	if _, err := execer.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (`%s` %s PRIMARY KEY%s)", typ.Name(), primaryKeyCol, primaryKeySQLType, pkeyAutoInc)); err != nil {
-		return withStack(err)
+	for _, index := range meta.indices {
+		if existingIndexNames[index.IndexName()] {
+			continue
+		}
+		stmt, err := ensureIndex(ctx, execer, index)
+		if err != nil {
+			return executed, err
+		}
+		executed = append(executed, stmt)
 	}
-	for colIndex, col := range cols {
-		if _, err := execer.ExecContext(ctx, fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s", typ.Name(), col, sqlTypes[colIndex])); err != nil && !strings.Contains(err.Error(), "duplicate column name") {
-			return withStack(err)
+	_, table := splitTable(meta.table)
+	for _, trigger := range meta.triggers {
+		if existingTriggerNames[table+"."+trigger.Name] {
+			continue
+		}
+		if err := exec(meta.createTriggerSQL(trigger)); err != nil {
+			return executed, err
 		}
 	}
-	for _, index := range indices {
-		unique := ""
-		if index.unique {
-			unique = "UNIQUE "
+	for _, stmt := range meta.extraDDL(driverNameOf(execer)) {
+		if err := exec(stmt); err != nil {
+			return executed, err
 		}
-		escapedCols := make([]string, len(index.cols))
-		for colIndex, col := range index.cols {
-			escapedCols[colIndex] = fmt.Sprintf("`%s`", col)
+	}
+	if afterCreater, ok := reflect.New(meta.typ).Interface().(AfterCreater); ok && created {
+		if err := afterCreater.AfterCreate(ctx, execer); err != nil {
+			return executed, err
 		}
-		if _, err := execer.ExecContext(ctx, fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS `%s.%s` ON `%s` (%s)", unique, typ.Name(), strings.Join(index.cols, ","), typ.Name(), strings.Join(escapedCols, ","))); err != nil {
-			return withStack(err)
+	}
+	if fingerprint != "" {
+		if err := metas.storeFingerprint(ctx, execer, driverNameOf(execer), meta.table, fingerprint); err != nil {
+			return executed, err
 		}
 	}
-	return nil
+	return executed, nil
 }
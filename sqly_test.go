@@ -2,19 +2,30 @@ package sqly
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode"
 
-	_ "modernc.org/sqlite"
+	"github.com/jmoiron/sqlx"
+	"modernc.org/sqlite"
 )
 
 var (
 	ctx = context.Background()
 )
 
-func withDB(t *testing.T, f func(db *DB)) {
+func withDB(t *testing.T, f func(db *DB), opts ...Option) {
 	t.Helper()
-	db, err := Open("sqlite", ":memory:")
+	db, err := Open("sqlite", ":memory:", opts...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -22,6 +33,16 @@ func withDB(t *testing.T, f func(db *DB)) {
 
 }
 
+func withFileDB(t *testing.T, f func(db *DB), opts ...Option) {
+	t.Helper()
+	db, err := Open("sqlite", filepath.Join(t.TempDir(), "test.db"), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	f(db)
+}
+
 type testStruct struct {
 	unexported int
 	Uint       uint
@@ -158,3 +179,1262 @@ func TextIndex(t *testing.T) {
 		}, false))
 	})
 }
+
+type timeTextTestStruct struct {
+	Id      int `sqly:"pkey"`
+	Created SQLTimeText
+	Deleted SQLTimeText
+}
+
+func TestSQLTimeText(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, timeTextTestStruct{}))
+		created := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+		wantStr := &timeTextTestStruct{
+			Id:      1,
+			Created: ToSQLTimeText(created),
+		}
+		noerr(t, db.Upsert(ctx, wantStr, false))
+		var raw string
+		noerr(t, db.Get(&raw, "SELECT Created FROM timeTextTestStruct WHERE Id = ?", 1))
+		if raw != created.Format(time.RFC3339Nano) {
+			t.Errorf("got %q, wanted %q", raw, created.Format(time.RFC3339Nano))
+		}
+		var deletedIsNull bool
+		noerr(t, db.Get(&deletedIsNull, "SELECT Deleted IS NULL FROM timeTextTestStruct WHERE Id = ?", 1))
+		if !deletedIsNull {
+			t.Errorf("got non NULL Deleted, wanted NULL for zero time")
+		}
+		gotStr := &timeTextTestStruct{}
+		noerr(t, db.Get(gotStr, "SELECT * FROM timeTextTestStruct WHERE Id = ?", 1))
+		if !gotStr.Created.Time().Equal(created) {
+			t.Errorf("got %v, wanted %v", gotStr.Created.Time(), created)
+		}
+		if !gotStr.Deleted.Time().IsZero() {
+			t.Errorf("got %v, wanted zero time", gotStr.Deleted.Time())
+		}
+	})
+}
+
+type snakeCaseMapper struct{}
+
+func (snakeCaseMapper) snakeCase(s string) string {
+	result := &strings.Builder{}
+	for index, r := range s {
+		if unicode.IsUpper(r) {
+			if index > 0 {
+				result.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		result.WriteRune(r)
+	}
+	return result.String()
+}
+
+func (m snakeCaseMapper) TableName(typ reflect.Type) string {
+	return m.snakeCase(typ.Name())
+}
+
+func (m snakeCaseMapper) ColumnName(fieldName string) string {
+	return m.snakeCase(fieldName)
+}
+
+func TestNameMapper(t *testing.T) {
+	db, err := Open("sqlite", ":memory:", WithNameMapper(snakeCaseMapper{}))
+	noerr(t, err)
+	defer db.Close()
+	noerr(t, db.CreateTableIfNotExists(ctx, testStruct{}))
+	wantStr := &testStruct{
+		Uint:    2,
+		Int64:   11,
+		String:  "12",
+		Bool:    true,
+		Float64: 14.0,
+		Blob:    []byte("15"),
+	}
+	noerr(t, db.Upsert(ctx, wantStr, false))
+	gotStr := &testStruct{}
+	noerr(t, db.Get(gotStr, "SELECT * FROM test_struct WHERE `int` = ?", wantStr.Int))
+	if !reflect.DeepEqual(gotStr, wantStr) {
+		t.Errorf("got %+v, wanted %+v", gotStr, wantStr)
+	}
+	wantStr.String = "13"
+	noerr(t, db.Upsert(ctx, wantStr, true))
+	noerr(t, db.Get(gotStr, "SELECT * FROM test_struct WHERE `int` = ?", wantStr.Int))
+	if !reflect.DeepEqual(gotStr, wantStr) {
+		t.Errorf("got %+v, wanted %+v", gotStr, wantStr)
+	}
+	cols := []string{}
+	noerr(t, db.Select(&cols, "SELECT name FROM pragma_table_info('test_struct')"))
+	wantCols := []string{"int", "uint", "uint8", "uint16", "uint32", "uint64", "int8", "int16", "int32", "int64", "string", "bool", "float32", "float64", "blob"}
+	if !reflect.DeepEqual(cols, wantCols) {
+		t.Errorf("got %+v, wanted %+v", cols, wantCols)
+	}
+
+	noerr(t, db.CreateTableIfNotExists(ctx, indexedTestStruct{}))
+	indices := []string{}
+	noerr(t, db.Select(&indices, "SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'indexed_test_struct' AND sql IS NOT NULL ORDER BY name"))
+	wantIndices := []string{
+		"indexed_test_struct.indexed",
+		"indexed_test_struct.three_indexed3,three_indexed1,three_indexed2",
+		"indexed_test_struct.three_unique3,three_unique1,three_unique2",
+		"indexed_test_struct.unique",
+	}
+	if !reflect.DeepEqual(indices, wantIndices) {
+		t.Errorf("got %+v, wanted %+v", indices, wantIndices)
+	}
+	noerr(t, db.Upsert(ctx, &indexedTestStruct{Id: 1, Unique: 1, ThreeUnique1: 1, ThreeUnique2: 2, ThreeUnique3: 3}, false))
+	yeserr(t, db.Upsert(ctx, &indexedTestStruct{Id: 2, Unique: 2, ThreeUnique1: 1, ThreeUnique2: 2, ThreeUnique3: 3}, false))
+}
+
+type sharedTestStruct struct {
+	Id   int `sqly:"pkey"`
+	Name string
+}
+
+type globalTestStruct struct {
+	Id int `sqly:"pkey"`
+}
+
+func (globalTestStruct) TableName() string {
+	return "global"
+}
+
+func (globalTestStruct) SkipTablePrefix() bool {
+	return true
+}
+
+type renamedTestStruct struct {
+	Id int `sqly:"pkey"`
+}
+
+func (renamedTestStruct) TableName() string {
+	return "renamed"
+}
+
+type archivedTestStruct struct {
+	Id   int    `sqly:"pkey"`
+	Name string `sqly:"index"`
+}
+
+func (archivedTestStruct) TableName() string {
+	return "archive.Events"
+}
+
+type overQualifiedTestStruct struct {
+	Id int `sqly:"pkey"`
+}
+
+func (overQualifiedTestStruct) TableName() string {
+	return "archive.old.Events"
+}
+
+type badlyQualifiedTestStruct struct {
+	Id int `sqly:"pkey"`
+}
+
+func (badlyQualifiedTestStruct) TableName() string {
+	return "archive.bad-name"
+}
+
+func TestAttachedTable(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open("sqlite", filepath.Join(dir, "main.db"), WithTablePrefix("app_"))
+	noerr(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.ExecContext(ctx, "ATTACH DATABASE ? AS archive", filepath.Join(dir, "archive.db"))
+	noerr(t, err)
+	for i := 0; i < 2; i++ {
+		noerr(t, db.CreateTableIfNotExists(ctx, archivedTestStruct{}))
+	}
+	noerr(t, db.Upsert(ctx, &archivedTestStruct{Id: 1, Name: "a"}, false))
+	noerr(t, db.Upsert(ctx, &archivedTestStruct{Id: 1, Name: "b"}, true))
+	name := ""
+	noerr(t, db.GetContext(ctx, &name, "SELECT `Name` FROM `archive`.`app_Events` WHERE `Id` = 1"))
+	if name != "b" {
+		t.Errorf("got %q, wanted b", name)
+	}
+	found := archivedTestStruct{Id: 1}
+	noerr(t, db.Refresh(ctx, &found))
+	if found.Name != "b" {
+		t.Errorf("got %+v, wanted Name b", found)
+	}
+	indices := []string{}
+	noerr(t, db.SelectContext(ctx, &indices, "SELECT name FROM archive.sqlite_master WHERE type = 'index' AND sql IS NOT NULL"))
+	if want := []string{"app_Events.Name"}; !reflect.DeepEqual(indices, want) {
+		t.Errorf("got indices %q in archive, wanted %q", indices, want)
+	}
+	count := 0
+	noerr(t, db.GetContext(ctx, &count, "SELECT COUNT(*) FROM main.sqlite_master WHERE name LIKE '%Events%'"))
+	if count != 0 {
+		t.Errorf("got %v Events objects in main, wanted 0", count)
+	}
+	noerr(t, db.DropIndex(ctx, "archive.app_Events.Name"))
+	noerr(t, db.SelectContext(ctx, &indices, "SELECT name FROM archive.sqlite_master WHERE type = 'index' AND sql IS NOT NULL"))
+	if len(indices) != 0 {
+		t.Errorf("got indices %q in archive after dropping, wanted none", indices)
+	}
+	yeserr(t, db.CreateTableIfNotExists(ctx, overQualifiedTestStruct{}))
+	yeserr(t, db.CreateTableIfNotExists(ctx, badlyQualifiedTestStruct{}))
+}
+
+func TestTablePrefix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.db")
+	db1, err := Open("sqlite", path, WithTablePrefix("app1_"))
+	noerr(t, err)
+	defer db1.Close()
+	db2, err := Open("sqlite", path, WithTablePrefix("app2_"))
+	noerr(t, err)
+	defer db2.Close()
+	for _, db := range []*DB{db1, db2} {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		noerr(t, db.CreateTableIfNotExists(ctx, globalTestStruct{}))
+		noerr(t, db.CreateTableIfNotExists(ctx, renamedTestStruct{}))
+		noerr(t, db.CreateTableIfNotExists(ctx, namedTestStruct{}))
+	}
+	noerr(t, db1.Upsert(ctx, &sharedTestStruct{Id: 1, Name: "one"}, false))
+	noerr(t, db2.Upsert(ctx, &sharedTestStruct{Id: 1, Name: "two"}, false))
+	for db, want := range map[*DB]string{db1: "one", db2: "two"} {
+		found, err := SelectByExample(ctx, db, sharedTestStruct{Id: 1})
+		noerr(t, err)
+		if len(found) != 1 || found[0].Name != want {
+			t.Errorf("got %+v, wanted only %q", found, want)
+		}
+	}
+	name := ""
+	noerr(t, db1.Get(&name, "SELECT Name FROM app1_sharedTestStruct WHERE Id = 1"))
+	if name != "one" {
+		t.Errorf("got %q, wanted \"one\"", name)
+	}
+	noerr(t, db1.Get(&name, "SELECT Name FROM app2_sharedTestStruct WHERE Id = 1"))
+	if name != "two" {
+		t.Errorf("got %q, wanted \"two\"", name)
+	}
+	tables := []string{}
+	noerr(t, db1.Select(&tables, "SELECT name FROM sqlite_master WHERE type = 'table' ORDER BY name"))
+	wantTables := []string{"app1_namedTestStruct", "app1_renamed", "app1_sharedTestStruct", "app2_namedTestStruct", "app2_renamed", "app2_sharedTestStruct", "global"}
+	if !reflect.DeepEqual(tables, wantTables) {
+		t.Errorf("got %+v, wanted %+v", tables, wantTables)
+	}
+	indices := []string{}
+	noerr(t, db1.Select(&indices, "SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name LIKE '%namedTestStruct' AND sql IS NOT NULL ORDER BY name"))
+	for _, index := range indices {
+		if !strings.HasPrefix(index, "app1_namedTestStruct.") && !strings.HasPrefix(index, "app2_namedTestStruct.") {
+			t.Errorf("got index %q, wanted it to be named by a prefixed table", index)
+		}
+	}
+	if len(indices) != 4 {
+		t.Errorf("got %q, wanted two indices per prefix", indices)
+	}
+
+	_, err = Open("sqlite", path, WithTablePrefix("bad prefix"))
+	yeserr(t, err)
+}
+
+func TestHealthCheck(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.Pingy(ctx))
+		noerr(t, db.HealthCheck(ctx))
+		inWrite := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- db.Write(ctx, func(tx *Tx) error {
+				close(inWrite)
+				<-release
+				return nil
+			})
+		}()
+		<-inWrite
+		shortCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		err := db.HealthCheck(shortCtx)
+		healthErr := &HealthCheckError{}
+		if !errors.As(err, &healthErr) {
+			t.Fatalf("got %v, wanted a *HealthCheckError", err)
+		}
+		if healthErr.Stage != HealthCheckLock {
+			t.Errorf("got stage %q, wanted %q", healthErr.Stage, HealthCheckLock)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got %v, wanted context.DeadlineExceeded", err)
+		}
+		close(release)
+		noerr(t, <-done)
+		noerr(t, db.HealthCheck(ctx))
+	})
+}
+
+func TestWritePanic(t *testing.T) {
+	withFileDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		func() {
+			defer func() {
+				if r := recover(); r != "boom" {
+					t.Errorf("got %v, wanted boom", r)
+				}
+			}()
+			db.Write(ctx, func(tx *Tx) error {
+				if err := tx.Upsert(ctx, &sharedTestStruct{Id: 1}, false); err != nil {
+					return err
+				}
+				panic("boom")
+			})
+		}()
+		if got := countRows(t, db, "sharedTestStruct"); got != 0 {
+			t.Errorf("got %v rows, wanted 0 after rolled back panic", got)
+		}
+		noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 2}, false))
+	})
+	withFileDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		err := db.Write(ctx, func(tx *Tx) error {
+			if err := tx.Upsert(ctx, &sharedTestStruct{Id: 1}, false); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+		panicErr := &PanicError{}
+		if !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+			t.Errorf("got %v, wanted a *PanicError with boom", err)
+		}
+		if got := countRows(t, db, "sharedTestStruct"); got != 0 {
+			t.Errorf("got %v rows, wanted 0 after rolled back panic", got)
+		}
+		noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 2}, false))
+		err = db.Read(ctx, func(tx *Tx) error {
+			panic("boom")
+		})
+		if !errors.As(err, &panicErr) {
+			t.Errorf("got %v, wanted a *PanicError", err)
+		}
+	}, WithPanicErrors())
+}
+
+func TestOpenWait(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "later")
+	path := filepath.Join(dir, "test.db")
+	_, err := OpenWait(ctx, "sqlite", path, RetryPolicy{InitialBackoff: time.Millisecond})
+	yeserr(t, err)
+	if !strings.Contains(err.Error(), "after 1 attempts") {
+		t.Errorf("got %v, wanted a single attempt", err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.Mkdir(dir, 0700)
+	}()
+	retryingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	db, err := OpenWait(retryingCtx, "sqlite", path, RetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		Retryable:      func(error) bool { return true },
+	})
+	noerr(t, err)
+	defer db.Close()
+	noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+
+	shortCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = OpenWait(shortCtx, "sqlite", filepath.Join(t.TempDir(), "never", "test.db"), RetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		Retryable:      func(error) bool { return true },
+	})
+	yeserr(t, err)
+	_, err = OpenWait(ctx, "sqlite", filepath.Join(t.TempDir(), "never", "test.db"), RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Retryable:      func(error) bool { return true },
+	})
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("got %v, wanted failure after 3 attempts", err)
+	}
+}
+
+type evolvedTestStruct struct {
+	Id    int `sqly:"pkey"`
+	Name  string
+	Added int `sqly:"index"`
+}
+
+func TestCreateTableIfNotExistsVerbose(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		_, err := db.Exec("ALTER TABLE sharedTestStruct RENAME TO evolvedTestStruct")
+		noerr(t, err)
+		executed, err := db.CreateTableIfNotExistsVerbose(ctx, evolvedTestStruct{})
+		noerr(t, err)
+		want := []string{
+			"ALTER TABLE `evolvedTestStruct` ADD COLUMN `Added` INTEGER",
+			"CREATE INDEX IF NOT EXISTS `evolvedTestStruct.Added` ON `evolvedTestStruct` (`Added`)",
+		}
+		if !reflect.DeepEqual(executed, want) {
+			t.Errorf("got %q, wanted %q", executed, want)
+		}
+	})
+}
+
+func TestLongTxThreshold(t *testing.T) {
+	longTxs := make(chan LongTx, 10)
+	withDB(t, func(db *DB) {
+		goroutines := runtime.NumGoroutine()
+		for i := 0; i < 100; i++ {
+			noerr(t, db.Read(ctx, func(tx *Tx) error { return nil }))
+		}
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if got := runtime.NumGoroutine(); got > goroutines {
+			t.Errorf("got %v goroutines, wanted at most %v", got, goroutines)
+		}
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			select {
+			case longTx := <-longTxs:
+				if longTx.ReadOnly {
+					t.Errorf("got read only long tx, wanted write")
+				}
+				if longTx.Duration < 20*time.Millisecond {
+					t.Errorf("got duration %v, wanted at least 20ms", longTx.Duration)
+				}
+				if !strings.Contains(string(longTx.Stack), "TestLongTxThreshold") {
+					t.Errorf("got stack %s, wanted it to contain the test", longTx.Stack)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("got no long tx callback while the transaction was still running")
+			}
+			return nil
+		}))
+		time.Sleep(50 * time.Millisecond)
+		if len(longTxs) != 0 {
+			t.Errorf("got %v extra long tx callbacks, wanted 0", len(longTxs))
+		}
+	}, WithLongTxThreshold(20*time.Millisecond, func(longTx LongTx) {
+		longTxs <- longTx
+	}))
+}
+
+type exprIndexedTestStruct struct {
+	Id    int `sqly:"pkey"`
+	Name  string
+	Email string `sqly:"index"`
+}
+
+func (exprIndexedTestStruct) SQLYIndices() []ExpressionIndex {
+	return []ExpressionIndex{
+		{Name: "lowerName", Expr: "lower(`Name`)", Unique: true},
+	}
+}
+
+type collidingExprIndexedTestStruct struct {
+	Id    int    `sqly:"pkey"`
+	Email string `sqly:"index"`
+}
+
+func (collidingExprIndexedTestStruct) SQLYIndices() []ExpressionIndex {
+	return []ExpressionIndex{
+		{Name: "Email", Expr: "lower(`Email`)"},
+	}
+}
+
+func TestExpressionIndex(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, exprIndexedTestStruct{}))
+		noerr(t, db.Upsert(ctx, &exprIndexedTestStruct{Id: 1, Name: "Foo"}, false))
+		yeserr(t, db.Upsert(ctx, &exprIndexedTestStruct{Id: 2, Name: "fOO"}, false))
+		noerr(t, db.Upsert(ctx, &exprIndexedTestStruct{Id: 2, Name: "bar"}, false))
+		yeserr(t, db.CreateTableIfNotExists(ctx, collidingExprIndexedTestStruct{}))
+	})
+}
+
+type partialIndexedTestStruct struct {
+	Id      int `sqly:"pkey"`
+	Owner   string
+	Name    string
+	Deleted bool
+	Email   string `sqly:"index"`
+}
+
+func (partialIndexedTestStruct) SQLYIndices() []Index {
+	return []Index{
+		{Name: "liveName", Columns: []string{"Owner", "Name"}, Unique: true, Where: "NOT `Deleted`"},
+		{Columns: []string{"Email"}},
+	}
+}
+
+type conflictingIndexedTestStruct struct {
+	Id    int    `sqly:"pkey"`
+	Email string `sqly:"index"`
+}
+
+func (conflictingIndexedTestStruct) SQLYIndices() []Index {
+	return []Index{
+		{Columns: []string{"Email"}, Unique: true},
+	}
+}
+
+type invalidIndexedTestStruct struct {
+	Id int `sqly:"pkey"`
+}
+
+func (invalidIndexedTestStruct) SQLYIndices() []Index {
+	return []Index{
+		{Columns: []string{"Missing"}},
+		{Expr: "lower(`Id`)"},
+		{Name: "both", Columns: []string{"Id"}, Expr: "lower(`Id`)"},
+	}
+}
+
+func TestIndexerIndex(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, partialIndexedTestStruct{}))
+		noerr(t, db.Upsert(ctx, &partialIndexedTestStruct{Id: 1, Owner: "a", Name: "x"}, false))
+		yeserr(t, db.Upsert(ctx, &partialIndexedTestStruct{Id: 2, Owner: "a", Name: "x"}, false))
+		noerr(t, db.Upsert(ctx, &partialIndexedTestStruct{Id: 1, Owner: "a", Name: "x", Deleted: true}, true))
+		noerr(t, db.Upsert(ctx, &partialIndexedTestStruct{Id: 2, Owner: "a", Name: "x"}, false))
+		indices := []string{}
+		noerr(t, db.SelectContext(ctx, &indices, "SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'partialIndexedTestStruct' ORDER BY name"))
+		if want := []string{"partialIndexedTestStruct.Email", "partialIndexedTestStruct.liveName"}; !reflect.DeepEqual(indices, want) {
+			t.Errorf("got indices %q, wanted %q", indices, want)
+		}
+		err := db.CreateTableIfNotExists(ctx, conflictingIndexedTestStruct{})
+		if err == nil || !strings.Contains(err.Error(), "conflicting definitions") {
+			t.Errorf("got %v, wanted an error about conflicting definitions", err)
+		}
+		err = db.CreateTableIfNotExists(ctx, invalidIndexedTestStruct{})
+		for _, want := range []string{"unknown column \"Missing\"", "has no name", "either columns or an expression"} {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("got %v, wanted an error containing %q", err, want)
+			}
+		}
+	})
+}
+
+type recordingExecer struct {
+	sqlx.ExecerContext
+	statements []string
+}
+
+func (r *recordingExecer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	r.statements = append(r.statements, query)
+	return r.ExecerContext.ExecContext(ctx, query, args...)
+}
+
+func TestUpsertSQL(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, testStruct{}))
+		execer := &recordingExecer{ExecerContext: db}
+		noerr(t, Upsert(ctx, execer, &testStruct{}, false))
+		noerr(t, Upsert(ctx, execer, &testStruct{Int: 1}, true))
+		noerr(t, UpsertAll(ctx, execer, []any{&testStruct{Int: 2}, &testStruct{Int: 3}}, false))
+		want := []string{
+			"INSERT INTO `testStruct` (`Uint`,`Uint8`,`Uint16`,`Uint32`,`Uint64`,`Int8`,`Int16`,`Int32`,`Int64`,`String`,`Bool`,`Float32`,`Float64`,`Blob`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?)",
+			"INSERT OR REPLACE INTO `testStruct` (`Uint`,`Uint8`,`Uint16`,`Uint32`,`Uint64`,`Int`,`Int8`,`Int16`,`Int32`,`Int64`,`String`,`Bool`,`Float32`,`Float64`,`Blob`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)",
+			"INSERT INTO `testStruct` (`Uint`,`Uint8`,`Uint16`,`Uint32`,`Uint64`,`Int`,`Int8`,`Int16`,`Int32`,`Int64`,`String`,`Bool`,`Float32`,`Float64`,`Blob`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?),(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)",
+		}
+		if !reflect.DeepEqual(execer.statements, want) {
+			t.Errorf("got\n%s\nwanted\n%s", strings.Join(execer.statements, "\n"), strings.Join(want, "\n"))
+		}
+	})
+}
+
+func TestWriteAffected(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		affected, err := db.WriteAffected(ctx, func(tx *Tx) error {
+			noerr(t, tx.Upsert(ctx, &sharedTestStruct{Id: 1, Name: "a"}, false))
+			noerr(t, tx.Upsert(ctx, &sharedTestStruct{Id: 2, Name: "a"}, false))
+			noerr(t, tx.UpsertAll(ctx, []any{&sharedTestStruct{Id: 3}, &sharedTestStruct{Id: 4}}, false))
+			_, err := tx.ExecContext(ctx, "UPDATE sharedTestStruct SET Name = 'b' WHERE Name = 'a'")
+			return err
+		})
+		noerr(t, err)
+		if affected != 6 {
+			t.Errorf("got %v affected rows, wanted 6", affected)
+		}
+		affected, err = db.WriteAffected(ctx, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "UPDATE sharedTestStruct SET Name = 'b' WHERE Name = 'c'")
+			return err
+		})
+		noerr(t, err)
+		if affected != 0 {
+			t.Errorf("got %v affected rows, wanted 0", affected)
+		}
+		affected, err = db.WriteAffected(ctx, func(tx *Tx) error {
+			noerr(t, tx.Upsert(ctx, &sharedTestStruct{Id: 5}, false))
+			return errors.New("failed")
+		})
+		if err == nil || affected != 0 {
+			t.Errorf("got %v, %v, wanted no affected rows for a rolled back transaction", affected, err)
+		}
+		affected, err = db.WriteAffected(ctx, func(tx *Tx) error {
+			noerr(t, tx.Upsert(ctx, &sharedTestStruct{Id: 5}, false))
+			return tx.SavepointExec(ctx, "empty", func(tx *Tx) error { return nil })
+		})
+		noerr(t, err)
+		if affected != 1 {
+			t.Errorf("got %v affected rows, wanted the savepoint not to count", affected)
+		}
+		affected, err = db.WriteAffected(ctx, func(tx *Tx) error {
+			if _, err := tx.ExecContext(ctx, "/* comment */ WITH ids AS (SELECT 5 AS Id) UPDATE sharedTestStruct SET Name = 'c' WHERE Id IN (SELECT Id FROM ids)"); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "CREATE TABLE affectedDDL (Id INTEGER)")
+			return err
+		})
+		noerr(t, err)
+		if affected != 1 {
+			t.Errorf("got %v affected rows, wanted the DDL not to count", affected)
+		}
+	})
+}
+
+func TestSavepointExec(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			if err := tx.Upsert(ctx, &sharedTestStruct{Id: 1}, false); err != nil {
+				return err
+			}
+			yeserr(t, tx.SavepointExec(ctx, "optional", func(tx *Tx) error {
+				if err := tx.Upsert(ctx, &sharedTestStruct{Id: 2}, false); err != nil {
+					return err
+				}
+				return fmt.Errorf("optional step failed")
+			}))
+			return tx.SavepointExec(ctx, "outer", func(tx *Tx) error {
+				if err := tx.Upsert(ctx, &sharedTestStruct{Id: 3}, false); err != nil {
+					return err
+				}
+				yeserr(t, tx.SavepointExec(ctx, "inner", func(tx *Tx) error {
+					if err := tx.Upsert(ctx, &sharedTestStruct{Id: 4}, false); err != nil {
+						return err
+					}
+					return tx.Upsert(ctx, &sharedTestStruct{Id: 3}, false)
+				}))
+				return nil
+			})
+		}))
+		ids := []int{}
+		noerr(t, db.Select(&ids, "SELECT Id FROM sharedTestStruct ORDER BY Id"))
+		if !reflect.DeepEqual(ids, []int{1, 3}) {
+			t.Errorf("got %v, wanted [1 3]", ids)
+		}
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			yeserr(t, tx.SavepointExec(ctx, "bad name", func(tx *Tx) error { return nil }))
+			return nil
+		}))
+	})
+}
+
+type tableInfo struct {
+	Cid       int
+	Name      string
+	Type      string
+	NotNull   bool
+	DfltValue sql.NullString
+	Pk        int
+}
+
+func getTableInfo(t *testing.T, db *DB, table string) []tableInfo {
+	t.Helper()
+	result := []tableInfo{}
+	noerr(t, db.Select(&result, "SELECT cid AS Cid, name AS Name, type AS Type, `notnull` AS `NotNull`, dflt_value AS DfltValue, pk AS Pk FROM pragma_table_info(?)", table))
+	return result
+}
+
+func TestCreateTableFreshAndEvolved(t *testing.T) {
+	withDB(t, func(db *DB) {
+		executed, err := db.CreateTableIfNotExistsVerbose(ctx, testStruct{})
+		noerr(t, err)
+		wantCreate := "CREATE TABLE IF NOT EXISTS `testStruct` (`Int` INTEGER PRIMARY KEY AUTOINCREMENT, `Uint` INTEGER, `Uint8` INTEGER, `Uint16` INTEGER, `Uint32` INTEGER, `Uint64` INTEGER, `Int8` INTEGER, `Int16` INTEGER, `Int32` INTEGER, `Int64` INTEGER, `String` TEXT, `Bool` INTEGER, `Float32` REAL, `Float64` REAL, `Blob` BLOB)"
+		if !reflect.DeepEqual(executed, []string{wantCreate}) {
+			t.Errorf("got %q, wanted %q", executed, []string{wantCreate})
+		}
+		fresh := getTableInfo(t, db, "testStruct")
+
+		execer := &recordingExecer{ExecerContext: db}
+		noerr(t, CreateTableIfNotExists(ctx, execer, testStruct{}))
+		if len(execer.statements) != 15 {
+			t.Errorf("got %v statements, wanted the skeleton CREATE and 14 ALTERs", len(execer.statements))
+		}
+		_, err = db.Exec("DROP TABLE testStruct")
+		noerr(t, err)
+		noerr(t, CreateTableIfNotExists(ctx, execer, testStruct{}))
+		evolved := getTableInfo(t, db, "testStruct")
+		if !reflect.DeepEqual(fresh, evolved) {
+			t.Errorf("got %+v from fresh CREATE, wanted %+v like the evolution path", fresh, evolved)
+		}
+
+		executed, err = db.CreateTableIfNotExistsVerbose(ctx, indexedTestStruct{})
+		noerr(t, err)
+		if len(executed) != 5 {
+			t.Errorf("got %q, wanted one CREATE and 4 CREATE INDEX", executed)
+		}
+		executed, err = db.CreateTableIfNotExistsVerbose(ctx, indexedTestStruct{})
+		noerr(t, err)
+		if len(executed) != 0 {
+			t.Errorf("got %q, wanted nothing executed for an up to date table", executed)
+		}
+		noerr(t, DropIndex(ctx, db, "indexedTestStruct.Unique"))
+		executed, err = db.CreateTableIfNotExistsVerbose(ctx, indexedTestStruct{})
+		noerr(t, err)
+		if len(executed) != 1 || !strings.Contains(executed[0], "`indexedTestStruct.Unique`") {
+			t.Errorf("got %q, wanted only the dropped index created", executed)
+		}
+	})
+}
+
+type upToDateTestStruct struct {
+	Id      int64  `sqly:"pkey"`
+	Name    string `sqly:"unique"`
+	Created int64  `sqly:"index"`
+}
+
+func (upToDateTestStruct) SQLYTriggers() []Trigger {
+	return []Trigger{{Name: "noop", Timing: "AFTER", Event: "INSERT", Body: "SELECT 1"}}
+}
+
+func TestCreateTableUpToDate(t *testing.T) {
+	writes := []string{}
+	logging := func(next StatementHandler) StatementHandler {
+		return StatementHandlerFuncs{
+			Exec: func(ctx context.Context, label string, query string, args ...any) (sql.Result, error) {
+				writes = append(writes, query)
+				return next.ExecContext(ctx, label, query, args...)
+			},
+			Query: next.QueryxContext,
+		}
+	}
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, upToDateTestStruct{}))
+		if len(writes) != 4 {
+			t.Errorf("got %q, wanted the table, its indices and its trigger created", writes)
+		}
+		writes = nil
+		noerr(t, db.CreateTableIfNotExists(ctx, upToDateTestStruct{}))
+		if len(writes) != 0 {
+			t.Errorf("got %q, wanted no writes for an up to date table", writes)
+		}
+	}, WithMiddleware(logging))
+}
+
+type strictTestStruct struct {
+	Id   int `sqly:"pkey,autoinc"`
+	Name string
+	Size int
+}
+
+func (strictTestStruct) SQLYStrict() bool {
+	return true
+}
+
+func TestStrictTables(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, strictTestStruct{}))
+		noerr(t, db.Upsert(ctx, &strictTestStruct{Name: "a", Size: 1}, false))
+		_, err := db.Exec("INSERT INTO strictTestStruct (Name, Size) VALUES ('b', 'not a number')")
+		yeserr(t, err)
+	})
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		_, err := db.Exec("INSERT INTO sharedTestStruct (Id, Name) VALUES (1, 2)")
+		noerr(t, err)
+		_, err = db.Exec("INSERT INTO sharedTestStruct (Id, Name) VALUES (2, x'00')")
+		yeserr(t, err)
+	}, WithStrictTables())
+}
+
+type withoutRowIDTestStruct struct {
+	Tenant string `sqly:"pkey"`
+	Key    string `sqly:"pkey"`
+	Value  string
+}
+
+func (withoutRowIDTestStruct) SQLYWithoutRowID() bool {
+	return true
+}
+
+type autoincWithoutRowIDTestStruct struct {
+	Id int `sqly:"pkey,autoinc"`
+}
+
+func (autoincWithoutRowIDTestStruct) SQLYWithoutRowID() bool {
+	return true
+}
+
+type noPkeyWithoutRowIDTestStruct struct {
+	Value string
+}
+
+func (noPkeyWithoutRowIDTestStruct) SQLYWithoutRowID() bool {
+	return true
+}
+
+type intWithoutRowIDTestStruct struct {
+	Id    int `sqly:"pkey"`
+	Value string
+}
+
+func (intWithoutRowIDTestStruct) SQLYWithoutRowID() bool {
+	return true
+}
+
+func TestWithoutRowID(t *testing.T) {
+	withDB(t, func(db *DB) {
+		executed, err := db.CreateTableIfNotExistsVerbose(ctx, withoutRowIDTestStruct{})
+		noerr(t, err)
+		if want := "CREATE TABLE IF NOT EXISTS `withoutRowIDTestStruct` (`Tenant` TEXT, `Key` TEXT, `Value` TEXT, PRIMARY KEY (`Tenant`,`Key`)) WITHOUT ROWID"; len(executed) != 1 || executed[0] != want {
+			t.Errorf("got %q, wanted [%q]", executed, want)
+		}
+		noerr(t, db.Upsert(ctx, &withoutRowIDTestStruct{Tenant: "a", Key: "x", Value: "1"}, false))
+		noerr(t, db.Upsert(ctx, &withoutRowIDTestStruct{Tenant: "b", Key: "x", Value: "2"}, false))
+		noerr(t, db.Upsert(ctx, &withoutRowIDTestStruct{Tenant: "a", Key: "x", Value: "3"}, true))
+		yeserr(t, db.Upsert(ctx, &withoutRowIDTestStruct{Tenant: "a", Key: "x", Value: "4"}, false))
+		got, err := GetSQL[withoutRowIDTestStruct](ctx, db, "SELECT * FROM withoutRowIDTestStruct WHERE Tenant = ? AND Key = ?", "a", "x")
+		noerr(t, err)
+		if got.Value != "3" {
+			t.Errorf("got %+v, wanted the overwritten row", got)
+		}
+		if count := countRows(t, db, "withoutRowIDTestStruct"); count != 2 {
+			t.Errorf("got %v rows, wanted 2", count)
+		}
+		created, err := db.GetOrCreate(ctx, &withoutRowIDTestStruct{Tenant: "b", Key: "x"})
+		noerr(t, err)
+		if created {
+			t.Errorf("got a created row, wanted the existing one found by the composite pkey")
+		}
+
+		noerr(t, db.CreateTableIfNotExists(ctx, intWithoutRowIDTestStruct{}))
+		zero := &intWithoutRowIDTestStruct{Value: "zero"}
+		noerr(t, db.Upsert(ctx, zero, false))
+		if zero.Id != 0 {
+			t.Errorf("got pkey %v, wanted no back-fill for a WITHOUT ROWID table", zero.Id)
+		}
+	})
+	if err := Validate(autoincWithoutRowIDTestStruct{}); err == nil || !strings.Contains(err.Error(), "WITHOUT ROWID") {
+		t.Errorf("got %v, wanted an error about autoinc in a WITHOUT ROWID table", err)
+	}
+	if err := Validate(noPkeyWithoutRowIDTestStruct{}); err == nil || !strings.Contains(err.Error(), "doesn't have a PRIMARY KEY") {
+		t.Errorf("got %v, wanted an error about the missing PRIMARY KEY", err)
+	}
+}
+
+type recordingDB struct {
+	*DB
+	statements []string
+}
+
+func (r *recordingDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	r.statements = append(r.statements, query)
+	return r.DB.ExecContext(ctx, query, args...)
+}
+
+func TestCreateTableIntrospection(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, testStruct{}))
+		recorder := &recordingDB{DB: db}
+		noerr(t, CreateTableIfNotExists(ctx, recorder, testStruct{}))
+		for _, stmt := range recorder.statements {
+			if strings.HasPrefix(stmt, "ALTER") {
+				t.Errorf("got %q, wanted no ALTER statements for an up to date table", stmt)
+			}
+		}
+		_, err := db.Exec("ALTER TABLE testStruct DROP COLUMN Blob")
+		noerr(t, err)
+		recorder.statements = nil
+		noerr(t, CreateTableIfNotExists(ctx, recorder, testStruct{}))
+		if !reflect.DeepEqual(recorder.statements, []string{"ALTER TABLE `testStruct` ADD COLUMN `Blob` BLOB"}) {
+			t.Errorf("got %q, wanted only the ALTER adding Blob", recorder.statements)
+		}
+	})
+}
+
+type collatedTestStruct struct {
+	Id       int64  `sqly:"pkey"`
+	Name     string `sqly:"unique,collate=nocase"`
+	Reversed string `sqly:"collate=sqlyReverse"`
+}
+
+type badCollationTestStruct struct {
+	Id   int64  `sqly:"pkey"`
+	Name string `sqly:"collate=sqlyUnknown"`
+}
+
+type collatedIntTestStruct struct {
+	Id    int64 `sqly:"pkey"`
+	Count int   `sqly:"collate=NOCASE"`
+}
+
+var registerReverseCollation = sync.OnceValue(func() error {
+	return sqlite.RegisterCollationUtf8("sqlyReverse", func(left, right string) int {
+		return strings.Compare(right, left)
+	})
+})
+
+func TestCollation(t *testing.T) {
+	noerr(t, registerReverseCollation())
+	withDB(t, func(db *DB) {
+		recorder := &recordingDB{DB: db}
+		noerr(t, CreateTableIfNotExists(ctx, recorder, collatedTestStruct{}))
+		if want := "CREATE TABLE IF NOT EXISTS `collatedTestStruct` (`Id` INTEGER PRIMARY KEY, `Name` TEXT COLLATE NOCASE, `Reversed` TEXT COLLATE SQLYREVERSE)"; recorder.statements[0] != want {
+			t.Errorf("got %q, wanted %q", recorder.statements[0], want)
+		}
+		noerr(t, db.Upsert(ctx, &collatedTestStruct{Id: 1, Name: "Hello", Reversed: "a"}, false))
+		yeserr(t, db.Upsert(ctx, &collatedTestStruct{Id: 2, Name: "hELLO", Reversed: "b"}, false))
+		noerr(t, db.Upsert(ctx, &collatedTestStruct{Id: 3, Name: "World", Reversed: "c"}, false))
+		found := []collatedTestStruct{}
+		noerr(t, db.Select(&found, "SELECT * FROM collatedTestStruct ORDER BY Reversed"))
+		if len(found) != 2 || found[0].Id != 3 || found[1].Id != 1 {
+			t.Errorf("got %+v, wanted ids 3 and 1", found)
+		}
+		yeserr(t, db.CreateTableIfNotExists(ctx, badCollationTestStruct{}))
+		yeserr(t, db.CreateTableIfNotExists(ctx, collatedIntTestStruct{}))
+	}, WithCollation("sqlyReverse"))
+	withDB(t, func(db *DB) {
+		yeserr(t, db.CreateTableIfNotExists(ctx, collatedTestStruct{}))
+	})
+}
+
+func TestCreateTableConcurrently(t *testing.T) {
+	withFileDB(t, func(db *DB) {
+		errs := make(chan error, 8)
+		for i := 0; i < cap(errs); i++ {
+			go func() {
+				errs <- db.CreateTableIfNotExists(ctx, testStruct{})
+			}()
+		}
+		for i := 0; i < cap(errs); i++ {
+			noerr(t, <-errs)
+		}
+		info := getTableInfo(t, db, "testStruct")
+		if len(info) != reflect.TypeOf(testStruct{}).NumField()-1 {
+			t.Errorf("got %+v, wanted one column per field", info)
+		}
+	})
+	path := filepath.Join(t.TempDir(), "test.db")
+	dsn := path + "?_txlock=immediate&_pragma=busy_timeout(10000)"
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			db, err := Open("sqlite", dsn)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer db.Close()
+			errs <- db.CreateTableIfNotExists(ctx, testStruct{})
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		noerr(t, <-errs)
+	}
+}
+
+type auditedTestStruct struct {
+	Id   int64 `sqly:"pkey"`
+	Name string
+}
+
+func (auditedTestStruct) SQLYTriggers() []Trigger {
+	return []Trigger{
+		{Name: "logInsert", Timing: "after", Event: "INSERT", Body: "INSERT INTO auditLog (Entry) VALUES ('insert ' || NEW.Name)"},
+		{Name: "logUpdate", Timing: "AFTER", Event: "UPDATE OF Name", Body: "INSERT INTO auditLog (Entry) VALUES ('update ' || NEW.Name);"},
+	}
+}
+
+type duplicateTriggerTestStruct struct {
+	Id int64 `sqly:"pkey"`
+}
+
+func (duplicateTriggerTestStruct) SQLYTriggers() []Trigger {
+	return []Trigger{
+		{Name: "a", Timing: "AFTER", Event: "INSERT", Body: "SELECT 1"},
+		{Name: "a", Timing: "AFTER", Event: "DELETE", Body: "SELECT 1"},
+		{Name: "b", Timing: "DURING", Event: "INSERT", Body: "SELECT 1"},
+		{Name: "c", Timing: "AFTER", Event: "INSERT; DROP TABLE x", Body: "SELECT 1"},
+	}
+}
+
+func TestTriggers(t *testing.T) {
+	withDB(t, func(db *DB) {
+		_, err := db.Exec("CREATE TABLE auditLog (Entry TEXT)")
+		noerr(t, err)
+		executed, err := db.CreateTableIfNotExistsVerbose(ctx, auditedTestStruct{})
+		noerr(t, err)
+		if want := "CREATE TRIGGER IF NOT EXISTS `auditedTestStruct.logInsert` AFTER INSERT ON `auditedTestStruct` FOR EACH ROW BEGIN INSERT INTO auditLog (Entry) VALUES ('insert ' || NEW.Name); END"; executed[1] != want {
+			t.Errorf("got %q, wanted %q", executed[1], want)
+		}
+		noerr(t, db.CreateTableIfNotExists(ctx, auditedTestStruct{}))
+		noerr(t, db.Upsert(ctx, &auditedTestStruct{Id: 1, Name: "a"}, false))
+		_, err = db.Exec("UPDATE auditedTestStruct SET Name = 'b'")
+		noerr(t, err)
+		entries := []string{}
+		noerr(t, db.Select(&entries, "SELECT Entry FROM auditLog"))
+		if !reflect.DeepEqual(entries, []string{"insert a", "update b"}) {
+			t.Errorf("got %q, wanted the insert and update entries", entries)
+		}
+		err = db.CreateTableIfNotExists(ctx, duplicateTriggerTestStruct{})
+		for _, want := range []string{`trigger "a" of sqly.duplicateTriggerTestStruct is declared multiple times`, `invalid timing "DURING"`, `invalid event "INSERT; DROP TABLE x"`} {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("got %v, wanted it to contain %q", err, want)
+			}
+		}
+	})
+}
+
+type seededTestStruct struct {
+	Id   int64 `sqly:"pkey"`
+	Name string
+}
+
+var (
+	seedErr error
+)
+
+func (seededTestStruct) AfterCreate(ctx context.Context, execer sqlx.ExecerContext) error {
+	if seedErr != nil {
+		return seedErr
+	}
+	_, err := execer.ExecContext(ctx, "INSERT INTO seededTestStruct (Id, Name) VALUES (1, 'seed')")
+	return err
+}
+
+func TestAfterCreate(t *testing.T) {
+	withDB(t, func(db *DB) {
+		seedErr = errors.New("no seeds")
+		defer func() { seedErr = nil }()
+		yeserr(t, db.CreateTableIfNotExists(ctx, seededTestStruct{}))
+		if exists, err := tableExists(ctx, db, db.DriverName(), "seededTestStruct"); err != nil || exists {
+			t.Errorf("got %v, %v, wanted the failed creation to be rolled back", exists, err)
+		}
+		seedErr = nil
+		noerr(t, db.CreateTableIfNotExists(ctx, seededTestStruct{}))
+		noerr(t, db.CreateTableIfNotExists(ctx, seededTestStruct{}))
+		seeds := []seededTestStruct{}
+		noerr(t, db.Select(&seeds, "SELECT * FROM seededTestStruct"))
+		if !reflect.DeepEqual(seeds, []seededTestStruct{{Id: 1, Name: "seed"}}) {
+			t.Errorf("got %+v, wanted one seed", seeds)
+		}
+	})
+}
+
+type touchedTestStruct struct {
+	Id        int64 `sqly:"pkey"`
+	Name      string
+	UpdatedAt int64
+}
+
+func (touchedTestStruct) SQLYExtraDDL(dialect string) []string {
+	return []string{`CREATE TRIGGER IF NOT EXISTS touchedTestStruct_touch AFTER UPDATE OF Name ON touchedTestStruct FOR EACH ROW
+BEGIN UPDATE touchedTestStruct SET UpdatedAt = OLD.UpdatedAt + 1 WHERE Id = NEW.Id; END`}
+}
+
+func TestExtraDDL(t *testing.T) {
+	withDB(t, func(db *DB) {
+		diff, err := SchemaDiff(ctx, db, touchedTestStruct{})
+		noerr(t, err)
+		want := touchedTestStruct{}.SQLYExtraDDL(db.DriverName())
+		if !reflect.DeepEqual(diff.ExtraDDL, []DiffDDL{{Table: "touchedTestStruct", Statement: want[0]}}) {
+			t.Errorf("got %+v, wanted the extra DDL", diff.ExtraDDL)
+		}
+		statements, err := diff.Statements(db.DriverName())
+		noerr(t, err)
+		if statements[len(statements)-1] != want[0] {
+			t.Errorf("got %q, wanted the extra DDL last", statements)
+		}
+		executed, err := db.CreateTableIfNotExistsVerbose(ctx, touchedTestStruct{})
+		noerr(t, err)
+		if executed[len(executed)-1] != want[0] {
+			t.Errorf("got %q, wanted the extra DDL last", executed)
+		}
+		noerr(t, db.CreateTableIfNotExists(ctx, touchedTestStruct{}))
+		noerr(t, db.Upsert(ctx, &touchedTestStruct{Id: 1, Name: "a"}, false))
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "UPDATE touchedTestStruct SET Name = 'b' WHERE Id = 1")
+			return err
+		}))
+		touched, err := GetSQL[touchedTestStruct](ctx, db, "SELECT * FROM touchedTestStruct")
+		noerr(t, err)
+		if touched.UpdatedAt != 1 {
+			t.Errorf("got %+v, wanted the trigger to have bumped UpdatedAt", touched)
+		}
+	})
+}
+
+func TestReadYourWrites(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		yeserr(t, db.Write(ctx, func(tx *Tx) error {
+			noerr(t, tx.Upsert(ctx, &sharedTestStruct{Id: 1, Name: "uncommitted"}, false))
+			got, err := GetSQL[sharedTestStruct](ctx, tx, "SELECT * FROM sharedTestStruct WHERE Id = ?", 1)
+			noerr(t, err)
+			if got.Name != "uncommitted" {
+				t.Errorf("got %+v, wanted the uncommitted row", got)
+			}
+			found, err := SelectByExample(ctx, tx, sharedTestStruct{Name: "uncommitted"})
+			noerr(t, err)
+			if len(found) != 1 {
+				t.Errorf("got %+v, wanted the uncommitted row", found)
+			}
+			refreshed := &sharedTestStruct{Id: 1}
+			noerr(t, tx.Refresh(ctx, refreshed))
+			if refreshed.Name != "uncommitted" {
+				t.Errorf("got %+v, wanted the uncommitted row", refreshed)
+			}
+			noerr(t, tx.SetUserVersion(ctx, 2))
+			version, err := tx.UserVersion(ctx)
+			noerr(t, err)
+			if version != 2 {
+				t.Errorf("got %v, wanted 2", version)
+			}
+			schema, err := tx.DumpSchema(ctx)
+			noerr(t, err)
+			if !strings.Contains(schema, "sharedTestStruct") {
+				t.Errorf("got %q, wanted it to contain the table", schema)
+			}
+			return errors.New("rollback")
+		}))
+		if _, err := GetSQL[sharedTestStruct](ctx, db, "SELECT * FROM sharedTestStruct WHERE Id = ?", 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got %v, wanted ErrNotFound after the rollback", err)
+		}
+		version, err := db.UserVersion(ctx)
+		noerr(t, err)
+		if version != 0 {
+			t.Errorf("got %v, wanted the version to be rolled back", version)
+		}
+	})
+}
+
+type tableCheckedTestStruct struct {
+	Id      int64 `sqly:"pkey"`
+	StartAt int64
+	EndAt   int64
+	Label   string
+}
+
+func (tableCheckedTestStruct) SQLYTableChecks() []string {
+	return []string{"StartAt < EndAt", "Label != 'StartAt'"}
+}
+
+func TestTableChecks(t *testing.T) {
+	withDB(t, func(db *DB) {
+		meta, err := metaOf(db, tableCheckedTestStruct{})
+		noerr(t, err)
+		if want := []string{"`StartAt` < `EndAt`", "`Label` != 'StartAt'"}; !reflect.DeepEqual(meta.checks, want) {
+			t.Errorf("got %q, wanted %q", meta.checks, want)
+		}
+		noerr(t, db.CreateTableIfNotExists(ctx, tableCheckedTestStruct{}))
+		noerr(t, db.CreateTableIfNotExists(ctx, tableCheckedTestStruct{}))
+		noerr(t, db.Upsert(ctx, &tableCheckedTestStruct{Id: 1, StartAt: 1, EndAt: 2}, false))
+		yeserr(t, db.Upsert(ctx, &tableCheckedTestStruct{Id: 2, StartAt: 2, EndAt: 1}, false))
+		noerr(t, ValidateSchema(ctx, db, tableCheckedTestStruct{}))
+	})
+	withDB(t, func(db *DB) {
+		_, err := db.Exec("CREATE TABLE tableCheckedTestStruct (`Id` INTEGER PRIMARY KEY, `StartAt` INTEGER, `EndAt` INTEGER, `Label` TEXT)")
+		noerr(t, err)
+		err = db.CreateTableIfNotExists(ctx, tableCheckedTestStruct{})
+		if err == nil || !strings.Contains(err.Error(), "can't be added to existing tables") {
+			t.Errorf("got %v, wanted the missing table checks reported", err)
+		}
+		diff, err := SchemaDiff(ctx, db, tableCheckedTestStruct{})
+		noerr(t, err)
+		if want := []DiffCheck{{Table: "tableCheckedTestStruct", Check: "`StartAt` < `EndAt`"}, {Table: "tableCheckedTestStruct", Check: "`Label` != 'StartAt'"}}; !reflect.DeepEqual(diff.MissingChecks, want) {
+			t.Errorf("got %+v, wanted %+v", diff.MissingChecks, want)
+		}
+		yeserr(t, ValidateSchema(ctx, db, tableCheckedTestStruct{}))
+	})
+}
+
+func TestTxStatementCache(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		var cached *Tx
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			cached = tx
+			for i := 1; i <= 3; i++ {
+				noerr(t, tx.Upsert(ctx, &sharedTestStruct{Id: i, Name: "a"}, false))
+			}
+			noerr(t, tx.Upsert(ctx, &sharedTestStruct{Id: 1, Name: "b"}, true))
+			noerr(t, tx.Upsert(ctx, &sharedTestStruct{Name: "c"}, false))
+			if len(tx.stmts) != 3 {
+				t.Errorf("got %v cached statements, wanted 3", len(tx.stmts))
+			}
+			return nil
+		}))
+		if cached.stmts != nil {
+			t.Errorf("got %v cached statements after commit, wanted none", len(cached.stmts))
+		}
+		yeserr(t, db.Write(ctx, func(tx *Tx) error {
+			cached = tx
+			noerr(t, tx.Upsert(ctx, &sharedTestStruct{Id: 10, Name: "d"}, false))
+			return fmt.Errorf("rolling back")
+		}))
+		if cached.stmts != nil {
+			t.Errorf("got %v cached statements after rollback, wanted none", len(cached.stmts))
+		}
+		if count := countRows(t, db, "sharedTestStruct"); count != 4 {
+			t.Errorf("got %v rows, wanted 4", count)
+		}
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			wg := sync.WaitGroup{}
+			errs := make(chan error, 10)
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					errs <- tx.Upsert(ctx, &sharedTestStruct{Id: 20 + i, Name: "e"}, i%2 == 0)
+				}(i)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				noerr(t, err)
+			}
+			return nil
+		}))
+		if count := countRows(t, db, "sharedTestStruct"); count != 14 {
+			t.Errorf("got %v rows, wanted 14", count)
+		}
+	})
+}
+
+func TestExecLocked(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		locked := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- db.Write(ctx, func(tx *Tx) error {
+				close(locked)
+				<-release
+				return nil
+			})
+		}()
+		<-locked
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := db.ExecLocked(timeoutCtx, "CREATE VIEW sharedTestView AS SELECT Name FROM sharedTestStruct"); !errors.Is(err, ErrLockTimeout) {
+			t.Errorf("got %v, wanted ErrLockTimeout while the Write holds the lock", err)
+		}
+		close(release)
+		noerr(t, <-done)
+		// Views can't have parameters.
+		_, err := db.ExecLocked(ctx, "CREATE VIEW sharedTestView AS SELECT Name FROM sharedTestStruct WHERE Id > ?", 0)
+		yeserr(t, err)
+		_, err = db.ExecLocked(ctx, "CREATE VIEW sharedTestView AS SELECT Name FROM sharedTestStruct")
+		noerr(t, err)
+		result, err := db.ExecLocked(ctx, "INSERT INTO sharedTestStruct (Id, Name) VALUES (?, ?)", 1, "a")
+		noerr(t, err)
+		if affected, err := result.RowsAffected(); err != nil || affected != 1 {
+			t.Errorf("got %v, %v, wanted 1 row affected", affected, err)
+		}
+		names := []string{}
+		noerr(t, db.Select(&names, "SELECT Name FROM sharedTestView"))
+		if !reflect.DeepEqual(names, []string{"a"}) {
+			t.Errorf("got %v, wanted [a]", names)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package sqly
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	txDurationBuckets = 32
+)
+
+// TxStats is a snapshot of the transaction counters of a DB.
+// Percentiles are estimated using power of two microsecond buckets, and are reported as the upper bound of the bucket.
+type TxStats struct {
+	Reads           int64
+	Writes          int64
+	Rollbacks       int64
+	AverageDuration time.Duration
+	P50Duration     time.Duration
+	P90Duration     time.Duration
+	P99Duration     time.Duration
+}
+
+type txStats struct {
+	reads      atomic.Int64
+	writes     atomic.Int64
+	rollbacks  atomic.Int64
+	totalNanos atomic.Int64
+	buckets    [txDurationBuckets]atomic.Int64
+}
+
+func txDurationBucket(d time.Duration) int {
+	micros := d.Microseconds()
+	bucket := 0
+	for micros > 0 && bucket < txDurationBuckets-1 {
+		micros >>= 1
+		bucket++
+	}
+	return bucket
+}
+
+func (s *txStats) record(readOnly bool, d time.Duration, rolledBack bool) {
+	if readOnly {
+		s.reads.Add(1)
+	} else {
+		s.writes.Add(1)
+	}
+	if rolledBack {
+		s.rollbacks.Add(1)
+	}
+	s.totalNanos.Add(int64(d))
+	s.buckets[txDurationBucket(d)].Add(1)
+}
+
+func (s *txStats) snapshot() TxStats {
+	result := TxStats{
+		Reads:     s.reads.Load(),
+		Writes:    s.writes.Load(),
+		Rollbacks: s.rollbacks.Load(),
+	}
+	counts := [txDurationBuckets]int64{}
+	total := int64(0)
+	for bucket := range s.buckets {
+		counts[bucket] = s.buckets[bucket].Load()
+		total += counts[bucket]
+	}
+	if total == 0 {
+		return result
+	}
+	result.AverageDuration = time.Duration(s.totalNanos.Load() / total)
+	percentile := func(p int64) time.Duration {
+		seen := int64(0)
+		for bucket, count := range counts {
+			seen += count
+			if seen*100 >= total*p {
+				return time.Duration(int64(1)<<bucket) * time.Microsecond
+			}
+		}
+		return time.Duration(int64(1)<<(txDurationBuckets-1)) * time.Microsecond
+	}
+	result.P50Duration = percentile(50)
+	result.P90Duration = percentile(90)
+	result.P99Duration = percentile(99)
+	return result
+}
+
+// TxStats returns the transaction counters of the Write and Read calls of this DB.
+func (db *DB) TxStats() TxStats {
+	return db.stats.snapshot()
+}
@@ -0,0 +1,36 @@
+package sqly
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTxStats(t *testing.T) {
+	withDB(t, func(db *DB) {
+		if stats := db.TxStats(); stats != (TxStats{}) {
+			t.Errorf("got %+v, wanted zero stats", stats)
+		}
+		for i := 0; i < 3; i++ {
+			noerr(t, db.Read(ctx, func(tx *Tx) error { return nil }))
+		}
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}))
+		yeserr(t, db.Write(ctx, func(tx *Tx) error { return fmt.Errorf("fail") }))
+		stats := db.TxStats()
+		if stats.Reads != 3 || stats.Writes != 2 || stats.Rollbacks != 1 {
+			t.Errorf("got %+v, wanted 3 reads, 2 writes and 1 rollback", stats)
+		}
+		if stats.AverageDuration < 2*time.Millisecond {
+			t.Errorf("got average %v, wanted at least 2ms", stats.AverageDuration)
+		}
+		if stats.P99Duration < 10*time.Millisecond {
+			t.Errorf("got p99 %v, wanted at least 10ms", stats.P99Duration)
+		}
+		if stats.P50Duration > stats.P90Duration || stats.P90Duration > stats.P99Duration {
+			t.Errorf("got unordered percentiles %+v", stats)
+		}
+	})
+}
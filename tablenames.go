@@ -0,0 +1,62 @@
+package sqly
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// TableNames returns the tables of prototypes, in the same order, as CreateTableIfNotExists would name them, without touching a database.
+func TableNames(prototypes ...any) ([]string, error) {
+	return tableNames(defaultMetas, prototypes)
+}
+
+// TableNames works like the package level TableNames, but uses the name mapper and table prefix configured for db.
+func (db *DB) TableNames(prototypes ...any) ([]string, error) {
+	return tableNames(db.metaCache(), prototypes)
+}
+
+func (tx *Tx) TableNames(prototypes ...any) ([]string, error) {
+	return tx.db.TableNames(prototypes...)
+}
+
+func tableNames(metas *metaCache, prototypes []any) ([]string, error) {
+	result := make([]string, len(prototypes))
+	for index, prototype := range prototypes {
+		val := reflect.ValueOf(prototype)
+		if val.Kind() != reflect.Struct {
+			return nil, errors.Errorf("%v is not a reflect.Struct", prototype)
+		}
+		meta, err := metas.get(val.Type())
+		if err != nil {
+			return nil, err
+		}
+		result[index] = meta.table
+	}
+	return result, nil
+}
+
+// TableExists returns whether table, like one of the names returned by TableNames, exists.
+// It looks in sqlite_master for SQLite and in information_schema.tables for other drivers, and if querier is a *DB it runs in a Read transaction.
+func TableExists(ctx context.Context, querier sqlx.QueryerContext, table string) (bool, error) {
+	if err := validTableName(table); err != nil {
+		return false, err
+	}
+	exists := false
+	err := readIn(ctx, querier, func(q sqlx.QueryerContext) error {
+		var err error
+		exists, err = tableExists(labeled(ctx, q, "tableExists", table), q, driverNameOf(q), table)
+		return err
+	})
+	return exists, err
+}
+
+func (db *DB) TableExists(ctx context.Context, table string) (bool, error) {
+	return TableExists(ctx, db, table)
+}
+
+func (tx *Tx) TableExists(ctx context.Context, table string) (bool, error) {
+	return TableExists(ctx, tx, table)
+}
@@ -0,0 +1,56 @@
+package sqly
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTableNames(t *testing.T) {
+	names, err := TableNames(sharedTestStruct{}, renamedTestStruct{}, archivedTestStruct{})
+	noerr(t, err)
+	if want := []string{"sharedTestStruct", "renamed", "archive.Events"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %q, wanted %q", names, want)
+	}
+	withDB(t, func(db *DB) {
+		names, err := db.TableNames(sharedTestStruct{}, globalTestStruct{}, archivedTestStruct{})
+		noerr(t, err)
+		if want := []string{"app_sharedTestStruct", "global", "archive.app_Events"}; !reflect.DeepEqual(names, want) {
+			t.Errorf("got %q, wanted %q", names, want)
+		}
+		_, err = db.TableNames(&sharedTestStruct{})
+		yeserr(t, err)
+		_, err = db.TableNames(overQualifiedTestStruct{})
+		yeserr(t, err)
+	}, WithTablePrefix("app_"))
+}
+
+func TestTableExists(t *testing.T) {
+	withDB(t, func(db *DB) {
+		names, err := db.TableNames(sharedTestStruct{})
+		noerr(t, err)
+		exists, err := db.TableExists(ctx, names[0])
+		noerr(t, err)
+		if exists {
+			t.Errorf("wanted %q to not exist", names[0])
+		}
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		exists, err = TableExists(ctx, db, names[0])
+		noerr(t, err)
+		if !exists {
+			t.Errorf("wanted %q to exist", names[0])
+		}
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			if _, err := tx.ExecContext(ctx, "DROP TABLE `app_sharedTestStruct`"); err != nil {
+				return err
+			}
+			exists, err := tx.TableExists(ctx, names[0])
+			noerr(t, err)
+			if exists {
+				t.Errorf("wanted %q to not exist after dropping it", names[0])
+			}
+			return nil
+		}))
+		_, err = db.TableExists(ctx, "a.b.c")
+		yeserr(t, err)
+	}, WithTablePrefix("app_"))
+}
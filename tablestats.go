@@ -0,0 +1,127 @@
+package sqly
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// IndexStat describes an index of a table.
+type IndexStat struct {
+	Name string
+	// Columns are the indexed columns, with "(expression)" for the expressions of expression indices.
+	Columns []string
+	Unique  bool
+	// Bytes is the approximate size of the index, only known if the TableStat is Sized.
+	Bytes int64
+}
+
+// TableStat describes the content of the table of a struct.
+type TableStat struct {
+	Table string
+	Rows  int64
+	// Indices are only listed for SQLite.
+	Indices []IndexStat
+	// Sized is whether the sizes of the table and its indices are known, which requires SQLite with the dbstat virtual table.
+	Sized bool
+	// Bytes is the approximate size of the table, without its indices.
+	Bytes int64
+}
+
+// TableStats returns the row counts, indices and sizes of the tables of prototypes, in the same order, all read in one Read transaction.
+func (db *DB) TableStats(ctx context.Context, prototypes ...any) ([]TableStat, error) {
+	metas := make([]*tableMeta, len(prototypes))
+	for index, prototype := range prototypes {
+		meta, err := metaOf(db, prototype)
+		if err != nil {
+			return nil, err
+		}
+		metas[index] = meta
+	}
+	result := make([]TableStat, len(metas))
+	if err := db.Read(ctx, func(tx *Tx) error {
+		sized := isSQLiteDriver(db.DriverName())
+		for index, meta := range metas {
+			stat, err := tableStat(labeled(ctx, tx, "tableStats", meta.typ.Name()), tx, meta, sized)
+			if err != nil {
+				return err
+			}
+			sized = stat.Sized
+			result[index] = stat
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// tableStat returns the stat of meta, trying to size it using dbstat if sized is set.
+func tableStat(ctx context.Context, tx *Tx, meta *tableMeta, sized bool) (TableStat, error) {
+	stat := TableStat{Table: meta.table}
+	if err := getContext(ctx, tx, &stat.Rows, fmt.Sprintf("SELECT COUNT(*) FROM %s", quoteTable(meta.table))); err != nil {
+		return TableStat{}, withStack(err)
+	}
+	if !isSQLiteDriver(tx.db.DriverName()) {
+		return stat, nil
+	}
+	schema, table := splitTable(meta.table)
+	if schema == "" {
+		schema = "main"
+	}
+	indices := []struct {
+		Name   string `db:"name"`
+		Unique bool   `db:"unique"`
+	}{}
+	if err := sqlx.SelectContext(ctx, tx, &indices, "SELECT name, `unique` FROM pragma_index_list(?, ?) ORDER BY name", table, schema); err != nil {
+		return TableStat{}, withStack(err)
+	}
+	for _, index := range indices {
+		cols := []sql.NullString{}
+		if err := sqlx.SelectContext(ctx, tx, &cols, "SELECT name FROM pragma_index_info(?, ?) ORDER BY seqno", index.Name, schema); err != nil {
+			return TableStat{}, withStack(err)
+		}
+		indexStat := IndexStat{Name: index.Name, Unique: index.Unique, Columns: make([]string, len(cols))}
+		for colIndex, col := range cols {
+			indexStat.Columns[colIndex] = col.String
+			if !col.Valid {
+				indexStat.Columns[colIndex] = "(expression)"
+			}
+		}
+		stat.Indices = append(stat.Indices, indexStat)
+	}
+	if !sized {
+		return stat, nil
+	}
+	var err error
+	if stat.Bytes, err = objectBytes(ctx, tx, schema, table); err != nil {
+		if isMissingDBStat(err) {
+			return stat, nil
+		}
+		return TableStat{}, err
+	}
+	for index := range stat.Indices {
+		if stat.Indices[index].Bytes, err = objectBytes(ctx, tx, schema, stat.Indices[index].Name); err != nil {
+			return TableStat{}, err
+		}
+	}
+	stat.Sized = true
+	return stat, nil
+}
+
+// objectBytes returns the bytes used by the pages of the table or index name according to dbstat.
+func objectBytes(ctx context.Context, tx *Tx, schema string, name string) (int64, error) {
+	result := int64(0)
+	if err := getContext(ctx, tx, &result, "SELECT COALESCE(SUM(pgsize), 0) FROM dbstat(?) WHERE name = ?", schema, name); err != nil {
+		return 0, withStack(err)
+	}
+	return result, nil
+}
+
+// isMissingDBStat returns whether err is caused by SQLite being built without the dbstat virtual table.
+func isMissingDBStat(err error) bool {
+	return strings.Contains(err.Error(), "no such table: dbstat")
+}
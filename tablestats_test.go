@@ -0,0 +1,69 @@
+package sqly
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+func TestTableStats(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, namedTestStruct{}))
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		for i := 0; i < 10; i++ {
+			noerr(t, db.Upsert(ctx, &namedTestStruct{Id: i + 1, Email: strings.Repeat("a", i+1)}, false))
+		}
+		noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 1}, false))
+		stats, err := db.TableStats(ctx, sharedTestStruct{}, namedTestStruct{})
+		noerr(t, err)
+		if len(stats) != 2 || stats[0].Table != "sharedTestStruct" || stats[0].Rows != 1 || stats[1].Table != "namedTestStruct" || stats[1].Rows != 10 {
+			t.Fatalf("got %+v, wanted 1 row in sharedTestStruct and 10 in namedTestStruct", stats)
+		}
+		indices := []IndexStat{}
+		for _, index := range stats[1].Indices {
+			if !stats[1].Sized || index.Bytes == 0 {
+				t.Errorf("got %+v, wanted a sized index", index)
+			}
+			index.Bytes = 0
+			indices = append(indices, index)
+		}
+		if want := []IndexStat{
+			{Name: "namedTestStruct.Email", Columns: []string{"Email"}, Unique: true},
+			{Name: "namedTestStruct.First,Last", Columns: []string{"First", "Last"}},
+		}; !reflect.DeepEqual(indices, want) {
+			t.Errorf("got indices %+v, wanted %+v", indices, want)
+		}
+		if stats[1].Bytes == 0 {
+			t.Errorf("got %+v, wanted a sized table", stats[1])
+		}
+	})
+}
+
+func TestTableStatsWithoutDBStat(t *testing.T) {
+	noDBStat := func(next StatementHandler) StatementHandler {
+		return StatementHandlerFuncs{
+			Exec: next.ExecContext,
+			Query: func(ctx context.Context, label string, query string, args ...any) (*sqlx.Rows, error) {
+				if strings.Contains(query, "dbstat") {
+					return nil, errors.New("no such table: dbstat")
+				}
+				return next.QueryxContext(ctx, label, query, args...)
+			},
+		}
+	}
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		noerr(t, db.Upsert(ctx, &sharedTestStruct{Id: 1}, false))
+		stats, err := db.TableStats(ctx, sharedTestStruct{}, sharedTestStruct{})
+		noerr(t, err)
+		for _, stat := range stats {
+			if stat.Rows != 1 || stat.Sized || stat.Bytes != 0 {
+				t.Errorf("got %+v, wanted 1 row and no size", stat)
+			}
+		}
+	}, WithMiddleware(noDBStat))
+}
@@ -0,0 +1,173 @@
+package sqly
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// tag is a single parsed sqly tag, like `pkey`, `transform=gzip` or `uniqueWith(A;B)`.
+type tag struct {
+	text    string
+	name    string
+	value   string
+	hasArgs bool
+	args    []string
+}
+
+type tagParser struct {
+	text []rune
+	pos  int
+}
+
+// parseTags parses a sqly struct tag.
+// Tags are separated by commas, and are either plain names, `name=value` or `name(arg;arg)`, where the arguments may also be separated by commas.
+// Whitespace around names, values and arguments is ignored, and a backslash makes the following character part of a value or argument.
+func parseTags(text string) ([]tag, error) {
+	p := &tagParser{text: []rune(text)}
+	result := []tag{}
+	p.skipSpace()
+	for !p.done() {
+		start := p.pos
+		parsed, err := p.parseTag()
+		if err != nil {
+			if raw := p.rawTag(start); raw != "" {
+				return nil, errors.Errorf("%v in %q", err, raw)
+			}
+			return nil, errors.Errorf("%v at offset %d", err, start)
+		}
+		parsed.text = strings.TrimSpace(string(p.text[start:p.pos]))
+		result = append(result, parsed)
+		p.skipSpace()
+		if p.done() {
+			break
+		}
+		if p.peek() != ',' {
+			return nil, errors.Errorf("unexpected %q after %q", p.peek(), parsed.text)
+		}
+		p.pos++
+		p.skipSpace()
+	}
+	return result, nil
+}
+
+// rawTag returns the text of the tag starting at start, up to the next separating comma, for error messages.
+func (p *tagParser) rawTag(start int) string {
+	depth := 0
+	pos := start
+	for ; pos < len(p.text); pos++ {
+		switch p.text[pos] {
+		case '\\':
+			pos++
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth <= 0 {
+				return strings.TrimSpace(string(p.text[start:pos]))
+			}
+		}
+	}
+	return strings.TrimSpace(string(p.text[start:min(pos, len(p.text))]))
+}
+
+func (p *tagParser) done() bool {
+	return p.pos >= len(p.text)
+}
+
+func (p *tagParser) peek() rune {
+	return p.text[p.pos]
+}
+
+func (p *tagParser) skipSpace() {
+	for !p.done() && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+func (p *tagParser) parseTag() (tag, error) {
+	result := tag{}
+	start := p.pos
+	for !p.done() && !strings.ContainsRune("=(),;\\", p.peek()) && !unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+	result.name = string(p.text[start:p.pos])
+	if result.name == "" {
+		if p.done() || p.peek() == ',' {
+			return result, fmt.Errorf("empty tag")
+		}
+		return result, fmt.Errorf("missing tag name before %q", p.peek())
+	}
+	p.skipSpace()
+	if p.done() || p.peek() == ',' {
+		return result, nil
+	}
+	switch p.peek() {
+	case '=':
+		p.pos++
+		p.skipSpace()
+		value, err := p.parseValue(",")
+		if err != nil {
+			return result, err
+		}
+		if value == "" {
+			return result, fmt.Errorf("missing value")
+		}
+		result.value = value
+	case '(':
+		p.pos++
+		result.hasArgs = true
+		for {
+			p.skipSpace()
+			arg, err := p.parseValue(";,)(")
+			if err != nil {
+				return result, err
+			}
+			if p.done() {
+				return result, fmt.Errorf("unbalanced parenthesis")
+			}
+			if p.peek() == '(' {
+				return result, fmt.Errorf("nested parenthesis")
+			}
+			if arg == "" {
+				return result, fmt.Errorf("empty argument")
+			}
+			result.args = append(result.args, arg)
+			if p.peek() == ')' {
+				p.pos++
+				break
+			}
+			p.pos++
+		}
+	default:
+		return result, fmt.Errorf("unexpected %q after tag name", p.peek())
+	}
+	return result, nil
+}
+
+// parseValue reads until one of the unescaped stop runes, or the end of the text, and returns the unescaped value without trailing unescaped whitespace.
+func (p *tagParser) parseValue(stop string) (string, error) {
+	value := []rune{}
+	significant := 0
+	for !p.done() && !strings.ContainsRune(stop, p.peek()) {
+		r := p.peek()
+		p.pos++
+		if r == '\\' {
+			if p.done() {
+				return "", fmt.Errorf("dangling escape")
+			}
+			value = append(value, p.peek())
+			p.pos++
+			significant = len(value)
+			continue
+		}
+		value = append(value, r)
+		if !unicode.IsSpace(r) {
+			significant = len(value)
+		}
+	}
+	return string(value[:significant]), nil
+}
@@ -0,0 +1,227 @@
+package sqly
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want []tag
+	}{
+		{"", []tag{}},
+		{"pkey", []tag{{text: "pkey", name: "pkey"}}},
+		{" pkey , autoinc ,", []tag{{text: "pkey", name: "pkey"}, {text: "autoinc", name: "autoinc"}}},
+		{"transform = gzip", []tag{{text: "transform = gzip", name: "transform", value: "gzip"}}},
+		{"uniqueWith(A, B),index", []tag{{text: "uniqueWith(A, B)", name: "uniqueWith", hasArgs: true, args: []string{"A", "B"}}, {text: "index", name: "index"}}},
+		{"indexWith( A ; B ),unique", []tag{{text: "indexWith( A ; B )", name: "indexWith", hasArgs: true, args: []string{"A", "B"}}, {text: "unique", name: "unique"}}},
+		{`x=a\,b\ `, []tag{{text: `x=a\,b\`, name: "x", value: "a,b "}}},
+		{`x(a\;b;c\))`, []tag{{text: `x(a\;b;c\))`, name: "x", hasArgs: true, args: []string{"a;b", "c)"}}}},
+	} {
+		got, err := parseTags(tc.text)
+		if err != nil {
+			t.Errorf("parsing %q: %v", tc.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parsing %q: got %+v, wanted %+v", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestParseTagsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want string
+	}{
+		{"uniqueWith(A", `unbalanced parenthesis in "uniqueWith(A"`},
+		{"uniqueWith(A))", `unexpected ')' after "uniqueWith(A)"`},
+		{"uniqueWith(A;)", `empty argument in "uniqueWith(A;)"`},
+		{"uniqueWith()", `empty argument in "uniqueWith()"`},
+		{"uniqueWith(A(B))", `nested parenthesis in "uniqueWith(A(B))"`},
+		{"pkey,,unique", `empty tag at offset 5`},
+		{"transform=", `missing value in "transform="`},
+		{`transform=a\`, `dangling escape in "transform=a\\"`},
+		{"=gzip", `missing tag name before '=' in "=gzip"`},
+		{"pkey autoinc", `unexpected 'a' after tag name in "pkey autoinc"`},
+	} {
+		_, err := parseTags(tc.text)
+		if err == nil {
+			t.Errorf("parsing %q: got no error, wanted %q", tc.text, tc.want)
+			continue
+		}
+		if err.Error() != tc.want {
+			t.Errorf("parsing %q: got %q, wanted %q", tc.text, err.Error(), tc.want)
+		}
+	}
+}
+
+type badTagTestStruct struct {
+	Id   int64  `sqly:"pkey"`
+	Name string `sqly:"uniqueWith(Id"`
+}
+
+type badTagArgsTestStruct struct {
+	Id   int64 `sqly:"pkey(Id)"`
+	Name string
+}
+
+type spacedTagTestStruct struct {
+	Id    int64  `sqly:"pkey"`
+	Name  string `sqly:"uniqueWith(Id, Other)"`
+	Other string `sqly:"pkeys"`
+}
+
+func TestPlanTags(t *testing.T) {
+	_, err := defaultMetas.get(reflect.TypeOf(badTagTestStruct{}))
+	if err == nil || !strings.Contains(err.Error(), `invalid sqly tag "uniqueWith(Id" on sqly.badTagTestStruct.Name: unbalanced parenthesis`) {
+		t.Errorf("got %v, wanted an error naming the struct, field and tag", err)
+	}
+	_, err = defaultMetas.get(reflect.TypeOf(badTagArgsTestStruct{}))
+	if err == nil || !strings.Contains(err.Error(), `invalid sqly tag "pkey(Id)" on sqly.badTagArgsTestStruct.Id: "pkey" takes no arguments`) {
+		t.Errorf("got %v, wanted an error naming the struct, field and tag", err)
+	}
+	meta, err := defaultMetas.get(reflect.TypeOf(spacedTagTestStruct{}))
+	noerr(t, err)
+	if !reflect.DeepEqual(meta.indices[0].Columns, []string{"Name", "Id", "Other"}) {
+		t.Errorf("got %q, wanted [Name Id Other]", meta.indices[0].Columns)
+	}
+	if !reflect.DeepEqual(meta.unknownTags, []string{`Other: "pkeys"`}) {
+		t.Errorf("got %q, wanted the unknown pkeys tag", meta.unknownTags)
+	}
+}
+
+type misspelledPkeyTestStruct struct {
+	Id   int64 `sqly:"pkeys"`
+	Name string
+}
+
+type misspelledUniqueWithTestStruct struct {
+	Id   int64  `sqly:"pkey"`
+	Name string `sqly:"index,uniqueWit(Id)"`
+}
+
+type validTagsTestStruct struct {
+	Id      int64  `sqly:"pkey,autoinc"`
+	Name    string `sqly:"unique,collate=NOCASE,indexWith(Payload)"`
+	Payload []byte `sqly:"transform=gzip,index,uniqueWith(Name; Id)"`
+}
+
+func TestStrictTags(t *testing.T) {
+	withDB(t, func(db *DB) {
+		err := db.CreateTableIfNotExists(ctx, misspelledPkeyTestStruct{})
+		if err == nil || !strings.Contains(err.Error(), `sqly.misspelledPkeyTestStruct has unknown sqly tags: Id: "pkeys"`) {
+			t.Errorf("got %v, wanted an error listing the unknown pkeys tag", err)
+		}
+		err = db.Upsert(ctx, &misspelledPkeyTestStruct{Id: 1}, false)
+		if err == nil || !strings.Contains(err.Error(), `Id: "pkeys"`) {
+			t.Errorf("got %v, wanted an error listing the unknown pkeys tag", err)
+		}
+		err = db.CreateTableIfNotExists(ctx, misspelledUniqueWithTestStruct{})
+		if err == nil || !strings.Contains(err.Error(), `Name: "uniqueWit(Id)"`) {
+			t.Errorf("got %v, wanted an error listing the unknown uniqueWit tag", err)
+		}
+		noerr(t, db.CreateTableIfNotExists(ctx, validTagsTestStruct{}))
+		noerr(t, db.Upsert(ctx, &validTagsTestStruct{Name: "a"}, false))
+	}, WithStrictTags())
+	withDB(t, func(db *DB) {
+		yeserr(t, db.CreateTableIfNotExists(ctx, misspelledPkeyTestStruct{}))
+		noerr(t, db.CreateTableIfNotExists(ctx, misspelledUniqueWithTestStruct{}))
+	})
+}
+
+type typoedWithTestStruct struct {
+	Id           int64 `sqly:"pkey"`
+	ThreeUnique1 int   `sqly:"uniqueWith(ThreeUniqe2)"`
+	ThreeUnique2 int
+	unexported   int
+	Indexed      int `sqly:"indexWith(unexported)"`
+}
+
+type repeatedWithTestStruct struct {
+	Id   int64  `sqly:"pkey"`
+	Name string `sqly:"uniqueWith(Id;Id)"`
+	Self string `sqly:"indexWith(Name;Self)"`
+}
+
+func TestWithTagFields(t *testing.T) {
+	_, err := metaOf(nil, typoedWithTestStruct{})
+	for _, want := range []string{
+		`invalid sqly tag "uniqueWith(ThreeUniqe2)" on sqly.typoedWithTestStruct.ThreeUnique1: "ThreeUniqe2" isn't a persisted field`,
+		`invalid sqly tag "indexWith(unexported)" on sqly.typoedWithTestStruct.Indexed: "unexported" isn't a persisted field`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got %v, wanted it to contain %q", err, want)
+		}
+	}
+	_, err = metaOf(nil, repeatedWithTestStruct{})
+	for _, want := range []string{`"uniqueWith" names "Id" more than once`, `"indexWith" can't name its own field "Self"`} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got %v, wanted it to contain %q", err, want)
+		}
+	}
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, validTagsTestStruct{}))
+		yeserr(t, db.CreateTableIfNotExists(ctx, typoedWithTestStruct{}))
+		if exists, err := db.TableExists(ctx, "typoedWithTestStruct"); err != nil || exists {
+			t.Errorf("got %v, %v, wanted no DDL attempted", exists, err)
+		}
+	})
+}
+
+type colTagTestStruct struct {
+	Name string `sqly:"uniqueWith(ID)"`
+	ID   int    `sqly:"pkey,col=id"`
+	Note string `sqly:"col=note_text"`
+}
+
+type clashingColTestStruct struct {
+	A string `sqly:"col=B"`
+	B string
+}
+
+func TestColTag(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, colTagTestStruct{}))
+		cols := []string{}
+		noerr(t, db.Select(&cols, "SELECT name FROM pragma_table_info('colTagTestStruct') ORDER BY cid"))
+		if want := []string{"id", "Name", "note_text"}; !reflect.DeepEqual(cols, want) {
+			t.Errorf("got %v, wanted %v", cols, want)
+		}
+		indexed := []string{}
+		noerr(t, db.Select(&indexed, "SELECT name FROM pragma_index_info('colTagTestStruct.Name,id') ORDER BY seqno"))
+		if want := []string{"Name", "id"}; !reflect.DeepEqual(indexed, want) {
+			t.Errorf("got %v, wanted %v", indexed, want)
+		}
+		noerr(t, db.Upsert(ctx, &colTagTestStruct{ID: 1, Name: "a", Note: "first"}, false))
+		refreshed := &colTagTestStruct{ID: 1}
+		noerr(t, db.Refresh(ctx, refreshed))
+		if refreshed.Name != "a" || refreshed.Note != "first" {
+			t.Errorf("got %+v, wanted the stored row", refreshed)
+		}
+		repo, err := NewRepo[colTagTestStruct](db)
+		noerr(t, err)
+		refreshed.Note = "second"
+		noerr(t, repo.Update(ctx, refreshed, "note_text"))
+		got, err := repo.Get(ctx, 1)
+		noerr(t, err)
+		if got.Note != "second" {
+			t.Errorf("got %+v, wanted the updated row", got)
+		}
+		found, err := SelectSQL[colTagTestStruct](ctx, db, "SELECT * FROM colTagTestStruct WHERE id = ?", 1)
+		noerr(t, err)
+		if len(found) != 1 || found[0] != got {
+			t.Errorf("got %+v, wanted %+v", found, got)
+		}
+		noerr(t, db.Delete(ctx, refreshed))
+		if count := countRows(t, db, "colTagTestStruct"); count != 0 {
+			t.Errorf("got %v rows, wanted 0", count)
+		}
+	})
+	_, err := metaOf(nil, clashingColTestStruct{})
+	if err == nil || !strings.Contains(err.Error(), `both map to col "B"`) {
+		t.Errorf("got %v, wanted the clashing cols to be rejected", err)
+	}
+}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS `indexedTestStruct` (`Id` INTEGER PRIMARY KEY, `Indexed` INTEGER, `Unique` INTEGER, `ThreeIndexed1` INTEGER, `ThreeIndexed2` INTEGER, `ThreeIndexed3` INTEGER, `ThreeUnique1` INTEGER, `ThreeUnique2` INTEGER, `ThreeUnique3` INTEGER);
+CREATE INDEX IF NOT EXISTS `indexedTestStruct.Indexed` ON `indexedTestStruct` (`Indexed`);
+CREATE UNIQUE INDEX IF NOT EXISTS `indexedTestStruct.Unique` ON `indexedTestStruct` (`Unique`);
+CREATE INDEX IF NOT EXISTS `indexedTestStruct.ThreeIndexed3,ThreeIndexed1,ThreeIndexed2` ON `indexedTestStruct` (`ThreeIndexed3`,`ThreeIndexed1`,`ThreeIndexed2`);
+CREATE UNIQUE INDEX IF NOT EXISTS `indexedTestStruct.ThreeUnique3,ThreeUnique1,ThreeUnique2` ON `indexedTestStruct` (`ThreeUnique3`,`ThreeUnique1`,`ThreeUnique2`);
+CREATE TABLE IF NOT EXISTS `testStruct` (`Int` INTEGER PRIMARY KEY AUTOINCREMENT, `Uint` INTEGER, `Uint8` INTEGER, `Uint16` INTEGER, `Uint32` INTEGER, `Uint64` INTEGER, `Int8` INTEGER, `Int16` INTEGER, `Int32` INTEGER, `Int64` INTEGER, `String` TEXT, `Bool` INTEGER, `Float32` REAL, `Float64` REAL, `Blob` BLOB);
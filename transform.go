@@ -0,0 +1,154 @@
+package sqly
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// Transformer encodes field values before they are stored, and decodes them after they are read.
+// Fields tagged `sqly:"transform=name"` are stored as BLOB, passed through the Transformer registered under name.
+// Empty and NULL values bypass the Transformer.
+type Transformer interface {
+	Encode([]byte) ([]byte, error)
+	Decode([]byte) ([]byte, error)
+}
+
+type identityTransformer struct{}
+
+func (identityTransformer) Encode(b []byte) ([]byte, error) {
+	return b, nil
+}
+
+func (identityTransformer) Decode(b []byte) ([]byte, error) {
+	return b, nil
+}
+
+type gzipTransformer struct{}
+
+func (gzipTransformer) Encode(b []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, withStack(err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, withStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipTransformer) Decode(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, withStack(err)
+	}
+	defer r.Close()
+	result, err := io.ReadAll(r)
+	if err != nil {
+		return nil, withStack(err)
+	}
+	return result, nil
+}
+
+var (
+	defaultTransformers = map[string]Transformer{
+		"identity": identityTransformer{},
+		"gzip":     gzipTransformer{},
+	}
+)
+
+// WithTransformer registers a Transformer usable by fields tagged `sqly:"transform=name"`.
+func WithTransformer(name string, transformer Transformer) Option {
+	return func(db *DB) error {
+		if db.transformers == nil {
+			db.transformers = map[string]Transformer{}
+			for defaultName, defaultTransformer := range defaultTransformers {
+				db.transformers[defaultName] = defaultTransformer
+			}
+		}
+		db.transformers[name] = transformer
+		return nil
+	}
+}
+
+func (m *metaCache) transformer(name string) (Transformer, error) {
+	transformers := m.transformers
+	if transformers == nil {
+		transformers = defaultTransformers
+	}
+	transformer, found := transformers[name]
+	if !found {
+		return nil, errors.Errorf("no transformer %q registered", name)
+	}
+	return transformer, nil
+}
+
+func transformable(typ reflect.Type) bool {
+	return typ.Kind() == reflect.String || (typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8)
+}
+
+func (field *fieldMeta) encode(fieldVal reflect.Value) (any, error) {
+	if field.lower {
+		fieldVal = normalized(fieldVal)
+	}
+	if isByteArray(field.typ) {
+		return byteArrayParam(fieldVal), nil
+	}
+	if isBigNum(field.typ) {
+		return bigNumParam(fieldVal), nil
+	}
+	if isBinary(field.typ) {
+		return binaryParam(fieldVal)
+	}
+	if field.transformer == nil {
+		return fieldVal.Interface(), nil
+	}
+	if fieldVal.Kind() == reflect.Slice && fieldVal.IsNil() {
+		return nil, nil
+	}
+	if fieldVal.Len() == 0 {
+		return []byte{}, nil
+	}
+	raw := []byte{}
+	if fieldVal.Kind() == reflect.String {
+		raw = []byte(fieldVal.String())
+	} else {
+		raw = fieldVal.Bytes()
+	}
+	encoded, err := field.transformer.Encode(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "encoding %q", field.name)
+	}
+	return encoded, nil
+}
+
+// afterScan decodes the transformed fields of val, which was just scanned from the database.
+func (meta *tableMeta) afterScan(val reflect.Value) error {
+	for _, field := range meta.fields {
+		if field.transformer == nil {
+			continue
+		}
+		fieldVal := val.Field(field.index)
+		if fieldVal.Len() == 0 {
+			continue
+		}
+		if fieldVal.Kind() == reflect.String {
+			decoded, err := field.transformer.Decode([]byte(fieldVal.String()))
+			if err != nil {
+				return errors.Wrapf(err, "decoding %q", field.name)
+			}
+			fieldVal.SetString(string(decoded))
+		} else {
+			decoded, err := field.transformer.Decode(fieldVal.Bytes())
+			if err != nil {
+				return errors.Wrapf(err, "decoding %q", field.name)
+			}
+			fieldVal.SetBytes(decoded)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package sqly
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type reverseTransformer struct{}
+
+func (reverseTransformer) reverse(b []byte) []byte {
+	result := make([]byte, len(b))
+	for index := range b {
+		result[len(b)-1-index] = b[index]
+	}
+	return result
+}
+
+func (r reverseTransformer) Encode(b []byte) ([]byte, error) {
+	return r.reverse(b), nil
+}
+
+func (r reverseTransformer) Decode(b []byte) ([]byte, error) {
+	return r.reverse(b), nil
+}
+
+type transformedTestStruct struct {
+	Id       int    `sqly:"pkey"`
+	Document string `sqly:"transform=gzip"`
+	Payload  []byte `sqly:"transform=reverse"`
+}
+
+type badTransformedTestStruct struct {
+	Id    int `sqly:"pkey"`
+	Count int `sqly:"transform=gzip"`
+}
+
+func TestTransformers(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, transformedTestStruct{}))
+		document := strings.Repeat("compressible ", 1000)
+		noerr(t, db.UpsertAll(ctx, []*transformedTestStruct{
+			{Id: 1, Document: document, Payload: []byte("abc")},
+			{Id: 2},
+		}, false))
+		storedSize := 0
+		noerr(t, db.Get(&storedSize, "SELECT length(Document) FROM transformedTestStruct WHERE Id = 1"))
+		if storedSize >= len(document) {
+			t.Errorf("got stored size %v, wanted less than %v", storedSize, len(document))
+		}
+		storedPayload := []byte{}
+		noerr(t, db.Get(&storedPayload, "SELECT Payload FROM transformedTestStruct WHERE Id = 1"))
+		if !bytes.Equal(storedPayload, []byte("cba")) {
+			t.Errorf("got stored payload %q, wanted \"cba\"", storedPayload)
+		}
+		got, err := GetSQL[transformedTestStruct](ctx, db, "SELECT * FROM transformedTestStruct WHERE Id = 1")
+		noerr(t, err)
+		if got.Document != document || !bytes.Equal(got.Payload, []byte("abc")) {
+			t.Errorf("got %q/%q, wanted the original values", got.Document[:20], got.Payload)
+		}
+		isNull := false
+		noerr(t, db.Get(&isNull, "SELECT Payload IS NULL FROM transformedTestStruct WHERE Id = 2"))
+		if !isNull {
+			t.Errorf("got non NULL payload, wanted nil to bypass the transformer")
+		}
+		got, err = GetSQL[transformedTestStruct](ctx, db, "SELECT * FROM transformedTestStruct WHERE Id = 2")
+		noerr(t, err)
+		if got.Document != "" || got.Payload != nil {
+			t.Errorf("got %+v, wanted empty values", got)
+		}
+		yeserr(t, db.CreateTableIfNotExists(ctx, badTransformedTestStruct{}))
+	}, WithTransformer("reverse", reverseTransformer{}))
+	withDB(t, func(db *DB) {
+		yeserr(t, db.CreateTableIfNotExists(ctx, transformedTestStruct{}))
+		_, err := db.Exec("CREATE TABLE transformedTestStruct (Id INTEGER PRIMARY KEY, Document BLOB, Payload BLOB)")
+		noerr(t, err)
+		_, err = db.Exec("INSERT INTO transformedTestStruct VALUES (1, x'61', x'0102')")
+		noerr(t, err)
+		_, err = GetSQL[transformedTestStruct](ctx, db, "SELECT * FROM transformedTestStruct")
+		yeserr(t, err)
+		_, err = SelectSQL[transformedTestStruct](ctx, db, "SELECT * FROM transformedTestStruct")
+		yeserr(t, err)
+	})
+}
@@ -0,0 +1,69 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+type txKey struct{}
+
+// NewContext returns a copy of ctx carrying tx, for WriteJoining and TxFrom to find.
+func NewContext(ctx context.Context, tx *Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFrom returns the Tx carried by ctx, if any.
+func TxFrom(ctx context.Context) (*Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*Tx)
+	return tx, ok
+}
+
+// Context returns the context the transaction was started with, carrying the transaction itself, for passing to code that may want to join it.
+func (tx *Tx) Context() context.Context {
+	if tx.ctx == nil {
+		return NewContext(context.Background(), tx)
+	}
+	return tx.ctx
+}
+
+type joinOptions struct {
+	savepoint bool
+}
+
+// JoinOption configures WriteJoining.
+type JoinOption func(*joinOptions)
+
+// JoinInSavepoint makes WriteJoining run f inside a savepoint when it joins a transaction, so a failing f only rolls back its own changes.
+func JoinInSavepoint() JoinOption {
+	return func(o *joinOptions) {
+		o.savepoint = true
+	}
+}
+
+// WriteJoining runs f in the unfinished Tx of the DB carried by ctx, and otherwise works like Write.
+// Errors of a joined f are returned without rolling back the joined transaction, which is left to its owner,
+// and the changes of a failing f are only rolled back if JoinInSavepoint is given.
+// Returns an error if the carried Tx is a Read transaction.
+func (db *DB) WriteJoining(ctx context.Context, f func(*Tx) error, opts ...JoinOption) error {
+	tx, found := TxFrom(ctx)
+	if !found || tx.db != db || tx.finished {
+		return db.Write(ctx, f)
+	}
+	if tx.readOnly {
+		return errors.Errorf("can't join a Read transaction for writing")
+	}
+	options := joinOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if !options.savepoint {
+		return db.mapError(withStack(f(tx)))
+	}
+	tx.joins++
+	defer func() {
+		tx.joins--
+	}()
+	return tx.SavepointExec(ctx, fmt.Sprintf("sqly_join_%d", tx.joins), f)
+}
@@ -0,0 +1,83 @@
+package sqly
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// saveOwner is a repository function that joins any transaction carried by ctx.
+func saveOwner(ctx context.Context, db *DB, owner *manyTestOwner, opts ...JoinOption) error {
+	return db.WriteJoining(ctx, func(tx *Tx) error {
+		if joined, found := TxFrom(ctx); found && !joined.finished {
+			if joined != tx {
+				return errors.Errorf("got %p, wanted the joined %p", tx, joined)
+			}
+		}
+		if owner.Name == "" {
+			if err := tx.Upsert(ctx, owner, false); err != nil {
+				return err
+			}
+			return errors.New("name missing")
+		}
+		return tx.Upsert(ctx, owner, false)
+	}, opts...)
+}
+
+func TestWriteJoining(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, manyTestOwner{}))
+		if _, found := TxFrom(ctx); found {
+			t.Errorf("found a Tx in a plain context")
+		}
+		noerr(t, saveOwner(ctx, db, &manyTestOwner{Id: 1, Name: "a"}))
+		if count := countRows(t, db, "manyTestOwner"); count != 1 {
+			t.Errorf("got %v rows, wanted the unjoined write committed", count)
+		}
+
+		err := db.Write(ctx, func(tx *Tx) error {
+			if found, _ := TxFrom(tx.Context()); found != tx {
+				t.Errorf("got %v, wanted the Tx in its context", found)
+			}
+			noerr(t, saveOwner(tx.Context(), db, &manyTestOwner{Id: 2, Name: "b"}))
+			return errors.New("outer failed")
+		})
+		if err == nil {
+			t.Errorf("wanted the outer error")
+		}
+		if count := countRows(t, db, "manyTestOwner"); count != 1 {
+			t.Errorf("got %v rows, wanted the joined write rolled back with the outer transaction", count)
+		}
+
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			noerr(t, saveOwner(tx.Context(), db, &manyTestOwner{Id: 3, Name: "c"}))
+			if err := saveOwner(tx.Context(), db, &manyTestOwner{Id: 4}, JoinInSavepoint()); err == nil || err.Error() != "name missing" {
+				t.Errorf("got %v, wanted the joined error", err)
+			}
+			return nil
+		}))
+		owners, err := SelectByExample(ctx, db, manyTestOwner{})
+		noerr(t, err)
+		if len(owners) != 2 || owners[1].Id != 3 {
+			t.Errorf("got %+v, wanted the failing joined write rolled back to its savepoint", owners)
+		}
+
+		var finished context.Context
+		noerr(t, db.Write(ctx, func(tx *Tx) error {
+			finished = tx.Context()
+			return nil
+		}))
+		noerr(t, saveOwner(finished, db, &manyTestOwner{Id: 5, Name: "e"}))
+		if count := countRows(t, db, "manyTestOwner"); count != 3 {
+			t.Errorf("got %v rows, wanted a finished Tx not joined", count)
+		}
+
+		noerr(t, db.Read(ctx, func(tx *Tx) error {
+			if err := saveOwner(tx.Context(), db, &manyTestOwner{Id: 6, Name: "f"}); err == nil {
+				t.Errorf("wanted an error joining a Read transaction")
+			}
+			return nil
+		}))
+	})
+}
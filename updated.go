@@ -0,0 +1,49 @@
+package sqly
+
+import (
+	"reflect"
+	"time"
+)
+
+// WithClock makes the DB use now instead of time.Now for the fields tagged `sqly:"updated"`.
+func WithClock(now func() time.Time) Option {
+	return func(db *DB) error {
+		db.clock = now
+		return nil
+	}
+}
+
+// isUpdatedType returns whether typ can be tagged `sqly:"updated"`.
+func isUpdatedType(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ == sqlTimeType || typ == sqlTimeTextType
+}
+
+// touchUpdated sets the field of val tagged `sqly:"updated"`, if any, to the current time, and returns a func restoring its previous value.
+// Every row stored by Upsert, UpsertAll, Repo.Update and the helpers built on them touches the field, whether the row is new or overwritten,
+// and the previous value is restored if the row wasn't stored, so the struct matches the stored row. Copied rows keep their values.
+// Unlike EnsureUpdatedAtTrigger it doesn't notice rows modified by raw SQL, but has the full precision of the clock.
+func (meta *tableMeta) touchUpdated(val reflect.Value) func() {
+	if meta.updated == nil {
+		return func() {}
+	}
+	fieldVal := val.Field(meta.updated.index)
+	previous := reflect.New(fieldVal.Type()).Elem()
+	previous.Set(fieldVal)
+	now := meta.now()
+	touched := fieldVal
+	if touched.Kind() == reflect.Ptr {
+		touched.Set(reflect.New(touched.Type().Elem()))
+		touched = touched.Elem()
+	}
+	if touched.Type() == sqlTimeType {
+		touched.Set(reflect.ValueOf(ToSQLTime(now)))
+	} else {
+		touched.Set(reflect.ValueOf(ToSQLTimeText(now)))
+	}
+	return func() {
+		fieldVal.Set(previous)
+	}
+}
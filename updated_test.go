@@ -0,0 +1,100 @@
+package sqly
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type updatedTestStruct struct {
+	Id      int `sqly:"pkey"`
+	Name    string
+	Updated SQLTime `sqly:"updated"`
+}
+
+type updatedTextTestStruct struct {
+	Id      int          `sqly:"pkey,autoinc"`
+	Updated *SQLTimeText `sqly:"updated"`
+}
+
+type badUpdatedTestStruct struct {
+	Id      int     `sqly:"pkey"`
+	Updated int     `sqly:"updated"`
+	Other   SQLTime `sqly:"updated"`
+	Another SQLTime `sqly:"updated"`
+}
+
+func TestUpdatedTag(t *testing.T) {
+	now := time.Unix(1000, 0)
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, updatedTestStruct{}))
+		val := &updatedTestStruct{Id: 1, Name: "a"}
+		noerr(t, db.Upsert(ctx, val, false))
+		stored := &updatedTestStruct{Id: 1}
+		noerr(t, db.Refresh(ctx, stored))
+		if !val.Updated.Time().Equal(now) || stored.Updated != val.Updated {
+			t.Errorf("got %v and %v, wanted %v", val.Updated.Time(), stored.Updated.Time(), now)
+		}
+
+		now = now.Add(time.Second)
+		val.Name = "b"
+		noerr(t, db.Upsert(ctx, val, true))
+		noerr(t, db.Refresh(ctx, stored))
+		if !val.Updated.Time().Equal(now) || stored.Updated != val.Updated || stored.Name != "b" {
+			t.Errorf("got %+v and %+v, wanted the overwrite at %v", val, stored, now)
+		}
+
+		now = now.Add(time.Second)
+		noerr(t, db.UpsertAll(ctx, []any{&updatedTestStruct{Id: 2}, val}, true))
+		noerr(t, db.Refresh(ctx, stored))
+		if !stored.Updated.Time().Equal(now) {
+			t.Errorf("got %v, wanted %v", stored.Updated.Time(), now)
+		}
+
+		before := val.Updated
+		now = now.Add(time.Second)
+		yeserr(t, db.Upsert(ctx, val, false))
+		if val.Updated != before {
+			t.Errorf("got %v, wanted the failed Upsert to leave %v", val.Updated.Time(), before.Time())
+		}
+		result, err := db.UpsertAllWithResult(ctx, []any{val, &updatedTestStruct{Id: 3}}, ConflictIgnore)
+		noerr(t, err)
+		if result.Inserted[0] || val.Updated != before {
+			t.Errorf("got %v, wanted the ignored duplicate to leave %v", val.Updated.Time(), before.Time())
+		}
+
+		repo, err := NewRepo[updatedTestStruct](db)
+		noerr(t, err)
+		now = now.Add(time.Second)
+		val.Name = "c"
+		noerr(t, repo.Update(ctx, val, "Name"))
+		noerr(t, db.Refresh(ctx, stored))
+		if !val.Updated.Time().Equal(now) || stored.Updated != val.Updated {
+			t.Errorf("got %v and %v, wanted the update at %v", val.Updated.Time(), stored.Updated.Time(), now)
+		}
+		missing := &updatedTestStruct{Id: 100}
+		if err := repo.Update(ctx, missing); !errors.Is(err, ErrNotFound) || missing.Updated != 0 {
+			t.Errorf("got %v and %v, wanted ErrNotFound and the field left unset", err, missing.Updated.Time())
+		}
+
+		noerr(t, db.CreateTableIfNotExists(ctx, updatedTextTestStruct{}))
+		text := &updatedTextTestStruct{}
+		noerr(t, db.Upsert(ctx, text, false))
+		if text.Updated == nil || !text.Updated.Time().Equal(now) {
+			t.Errorf("got %+v, wanted it updated at %v", text, now)
+		}
+		fresh := &updatedTestStruct{Id: 4}
+		yeserr(t, db.UpsertAll(ctx, []any{fresh, &updatedTextTestStruct{Id: text.Id}}, false))
+		if fresh.Updated != 0 {
+			t.Errorf("got %v, wanted the rolled back UpsertAll to leave the field unset", fresh.Updated.Time())
+		}
+	}, WithClock(func() time.Time { return now }))
+
+	_, err := metaOf(nil, badUpdatedTestStruct{})
+	for _, want := range []string{`col "Updated" can't be updated`, `multiple updated fields: "Other" and "Another"`} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got %v, wanted it to contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const (
+	// nowNanosSQL is the current time in nanoseconds since the epoch, with the millisecond precision of julianday.
+	nowNanosSQL = "CAST(ROUND((julianday('now') - 2440587.5) * 86400000) AS INTEGER) * 1000000"
+)
+
+// EnsureUpdatedAtTrigger creates an AFTER UPDATE trigger, named "table.updatedAt_column", on the table of prototype that sets the SQLTime column
+// to the current time whenever any other column of a row changes, so the column stays correct even for rows modified by raw SQL or other tools.
+// Updates only changing column itself don't fire the trigger, which keeps it from triggering itself and lets callers set the column explicitly.
+// The time has millisecond precision, and column is either the name of the field or of its column.
+func EnsureUpdatedAtTrigger(ctx context.Context, execer sqlx.ExecerContext, prototype any, column string) error {
+	if !isSQLiteDriver(driverNameOf(execer)) {
+		return errors.Errorf("updated at triggers aren't supported for driver %q", driverNameOf(execer))
+	}
+	meta, err := metaOf(execer, prototype)
+	if err != nil {
+		return err
+	}
+	if err := meta.requirePrimaryKey(); err != nil {
+		return err
+	}
+	var updatedAt *fieldMeta
+	for _, field := range meta.fields {
+		if field.col == column || field.name == column {
+			updatedAt = field
+		}
+	}
+	if updatedAt == nil {
+		return errors.Errorf("%v has no column %q", meta.typ, column)
+	}
+	if typ := updatedAt.typ; typ != sqlTimeType && (typ.Kind() != reflect.Ptr || typ.Elem() != sqlTimeType) {
+		return errors.Errorf("col %q of %v is a %v, not a SQLTime", updatedAt.col, meta.typ, typ)
+	}
+	changes := []string{}
+	for _, field := range meta.fields {
+		if field != updatedAt {
+			changes = append(changes, fmt.Sprintf("OLD.`%s` IS NOT NEW.`%s`", field.col, field.col))
+		}
+	}
+	if len(changes) == 0 {
+		return errors.Errorf("%v has no columns but %q to watch", meta.typ, updatedAt.col)
+	}
+	conditions := []string{}
+	for _, field := range meta.pkeys {
+		conditions = append(conditions, fmt.Sprintf("`%s` = NEW.`%s`", field.col, field.col))
+	}
+	_, table := splitTable(meta.table)
+	stmt := meta.createTriggerSQL(Trigger{
+		Name:   "updatedAt_" + updatedAt.col,
+		Timing: "AFTER",
+		Event:  "UPDATE",
+		When:   strings.Join(changes, " OR "),
+		Body:   fmt.Sprintf("UPDATE `%s` SET `%s` = %s WHERE %s", table, updatedAt.col, nowNanosSQL, strings.Join(conditions, " AND ")),
+	})
+	if _, err := execer.ExecContext(labeled(ctx, execer, "ensureUpdatedAtTrigger", meta.typ.Name()), stmt); err != nil {
+		return withStack(err)
+	}
+	return nil
+}
+
+func (db *DB) EnsureUpdatedAtTrigger(ctx context.Context, prototype any, column string) error {
+	return db.mapError(EnsureUpdatedAtTrigger(ctx, db, prototype, column))
+}
+
+func (tx *Tx) EnsureUpdatedAtTrigger(ctx context.Context, prototype any, column string) error {
+	return tx.db.mapError(EnsureUpdatedAtTrigger(ctx, tx, prototype, column))
+}
@@ -0,0 +1,43 @@
+package sqly
+
+import (
+	"testing"
+	"time"
+)
+
+type updatedAtTestStruct struct {
+	Id        int64 `sqly:"pkey"`
+	Name      string
+	UpdatedAt SQLTime
+}
+
+func TestEnsureUpdatedAtTrigger(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, updatedAtTestStruct{}))
+		noerr(t, db.EnsureUpdatedAtTrigger(ctx, updatedAtTestStruct{}, "UpdatedAt"))
+		noerr(t, db.EnsureUpdatedAtTrigger(ctx, updatedAtTestStruct{}, "UpdatedAt"))
+		yeserr(t, db.EnsureUpdatedAtTrigger(ctx, updatedAtTestStruct{}, "Name"))
+		yeserr(t, db.EnsureUpdatedAtTrigger(ctx, updatedAtTestStruct{}, "Missing"))
+		noerr(t, db.Upsert(ctx, &updatedAtTestStruct{Id: 1, Name: "a"}, false))
+		before := time.Now().Add(-time.Second)
+		_, err := db.ExecContext(ctx, "UPDATE updatedAtTestStruct SET Name = 'b' WHERE Id = 1")
+		noerr(t, err)
+		updated := &updatedAtTestStruct{Id: 1}
+		noerr(t, db.Refresh(ctx, updated))
+		if got := updated.UpdatedAt.Time(); got.Before(before) || got.After(time.Now().Add(time.Second)) {
+			t.Errorf("got %v, wanted about now", got)
+		}
+		_, err = db.ExecContext(ctx, "UPDATE updatedAtTestStruct SET UpdatedAt = 7 WHERE Id = 1")
+		noerr(t, err)
+		noerr(t, db.Refresh(ctx, updated))
+		if updated.UpdatedAt != 7 {
+			t.Errorf("got %v, wanted updates of only the column to not fire the trigger", updated.UpdatedAt)
+		}
+		_, err = db.ExecContext(ctx, "UPDATE updatedAtTestStruct SET Name = 'b' WHERE Id = 1")
+		noerr(t, err)
+		noerr(t, db.Refresh(ctx, updated))
+		if updated.UpdatedAt != 7 {
+			t.Errorf("got %v, wanted updates not changing anything to not fire the trigger", updated.UpdatedAt)
+		}
+	})
+}
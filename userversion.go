@@ -0,0 +1,111 @@
+package sqly
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const (
+	userVersionTable = "sqly_user_version"
+)
+
+func (m *metaCache) userVersionTable() string {
+	return m.prefix + userVersionTable
+}
+
+// UserVersion returns the application schema version stored in PRAGMA user_version, which is 0 for new databases.
+// Drivers other than SQLite store the version in a sqly_user_version table instead.
+func (db *DB) UserVersion(ctx context.Context) (int32, error) {
+	version := int32(0)
+	err := db.Read(ctx, func(tx *Tx) error {
+		var err error
+		version, err = userVersion(labeled(ctx, tx, "userVersion", ""), tx)
+		return err
+	})
+	return version, err
+}
+
+// SetUserVersion stores version as the application schema version, see UserVersion.
+func (db *DB) SetUserVersion(ctx context.Context, version int32) error {
+	return db.Write(ctx, func(tx *Tx) error {
+		return setUserVersion(labeled(ctx, tx, "setUserVersion", ""), tx, version)
+	})
+}
+
+// BumpUserVersion changes the application schema version from from to to, and returns an error matching ErrUserVersionMismatch if it isn't from.
+// The version is read and written in the same Write, so of several processes migrating the same database from the same version only one succeeds,
+// while the others get either ErrUserVersionMismatch or, if their transactions overlap, ErrBusy.
+func (db *DB) BumpUserVersion(ctx context.Context, from, to int32) error {
+	return db.Write(ctx, func(tx *Tx) error {
+		ctx := labeled(ctx, tx, "bumpUserVersion", "")
+		current, err := userVersion(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if current != from {
+			return errors.Wrapf(ErrUserVersionMismatch, "user version is %d, not %d", current, from)
+		}
+		return setUserVersion(ctx, tx, to)
+	})
+}
+
+// UserVersion works like DB.UserVersion, but includes the uncommitted changes of the transaction.
+func (tx *Tx) UserVersion(ctx context.Context) (int32, error) {
+	version, err := userVersion(labeled(ctx, tx, "userVersion", ""), tx)
+	return version, tx.db.mapError(err)
+}
+
+// SetUserVersion stores version as the application schema version when the transaction commits, so that migrations can change the schema and
+// the version atomically.
+func (tx *Tx) SetUserVersion(ctx context.Context, version int32) error {
+	return tx.db.mapError(setUserVersion(labeled(ctx, tx, "setUserVersion", ""), tx, version))
+}
+
+func userVersion(ctx context.Context, tx *Tx) (int32, error) {
+	version := int32(0)
+	if isSQLiteDriver(tx.DriverName()) {
+		if err := getContext(ctx, tx, &version, "PRAGMA user_version"); err != nil {
+			return 0, withStack(err)
+		}
+		return version, nil
+	}
+	table := metasFor(tx).userVersionTable()
+	exists, err := tableExists(ctx, tx, tx.DriverName(), table)
+	if err != nil || !exists {
+		return 0, err
+	}
+	if err := getContext(ctx, tx, &version, fmt.Sprintf("SELECT `Version` FROM `%s`", table)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, withStack(err)
+	}
+	return version, nil
+}
+
+func setUserVersion(ctx context.Context, tx *Tx, version int32) error {
+	if isSQLiteDriver(tx.DriverName()) {
+		// PRAGMA values can't be bound as parameters.
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+			return withStack(err)
+		}
+		return nil
+	}
+	table := metasFor(tx).userVersionTable()
+	for _, stmt := range []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (`Version` INTEGER NOT NULL)", table),
+		fmt.Sprintf("DELETE FROM `%s`", table),
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return withStack(err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, sqlx.Rebind(sqlx.BindType(tx.DriverName()), fmt.Sprintf("INSERT INTO `%s` (`Version`) VALUES (?)", table)), version); err != nil {
+		return withStack(err)
+	}
+	return nil
+}
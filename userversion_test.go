@@ -0,0 +1,33 @@
+package sqly
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestUserVersion(t *testing.T) {
+	withDB(t, func(db *DB) {
+		version, err := db.UserVersion(ctx)
+		noerr(t, err)
+		if version != 0 {
+			t.Errorf("got %v, wanted 0", version)
+		}
+		noerr(t, db.SetUserVersion(ctx, 3))
+		version, err = db.UserVersion(ctx)
+		noerr(t, err)
+		if version != 3 {
+			t.Errorf("got %v, wanted 3", version)
+		}
+		noerr(t, db.BumpUserVersion(ctx, 3, 4))
+		err = db.BumpUserVersion(ctx, 3, 5)
+		if !errors.Is(err, ErrUserVersionMismatch) {
+			t.Errorf("got %v, wanted ErrUserVersionMismatch", err)
+		}
+		version, err = db.UserVersion(ctx)
+		noerr(t, err)
+		if version != 4 {
+			t.Errorf("got %v, wanted 4", version)
+		}
+	})
+}
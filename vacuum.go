@@ -0,0 +1,137 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// autoVacuumIncremental is the value of PRAGMA auto_vacuum for INCREMENTAL.
+const autoVacuumIncremental = 2
+
+// WithIncrementalAutoVacuum makes new databases use auto_vacuum=INCREMENTAL, which lets IncrementalVacuum shrink the file without rewriting it.
+// Existing databases with tables can only be converted by a full VACUUM, so for those Open logs a warning, and the next Vacuum converts them.
+func WithIncrementalAutoVacuum() Option {
+	return func(db *DB) error {
+		if !isSQLiteDriver(db.DriverName()) {
+			return errors.Errorf("auto_vacuum isn't supported for driver %q", db.DriverName())
+		}
+		db.incrementalAutoVacuum = true
+		return nil
+	}
+}
+
+func (db *DB) pragmaInt(ctx context.Context, pragma string) (int, error) {
+	result := 0
+	if err := getContext(labeled(ctx, db, "pragma", pragma), db, &result, fmt.Sprintf("PRAGMA %s", pragma)); err != nil {
+		return 0, withStack(err)
+	}
+	return result, nil
+}
+
+// setupIncrementalAutoVacuum makes the database use auto_vacuum=INCREMENTAL if it has no tables yet.
+func (db *DB) setupIncrementalAutoVacuum(ctx context.Context) error {
+	mode, err := db.pragmaInt(ctx, "auto_vacuum")
+	if err != nil || mode == autoVacuumIncremental {
+		return err
+	}
+	tables := 0
+	if err := getContext(ctx, db, &tables, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'"); err != nil {
+		return withStack(err)
+	}
+	if tables > 0 {
+		db.log(ctx, slog.LevelWarn, "sqly: auto_vacuum isn't INCREMENTAL, and changing it needs a Vacuum", "autoVacuum", mode)
+		return nil
+	}
+	// The setting only sticks for the connection creating the first table, so VACUUM makes it stick for the database right away.
+	if _, err := db.ExecContext(labeled(ctx, db, "vacuum", ""), "PRAGMA auto_vacuum = INCREMENTAL; VACUUM"); err != nil {
+		return withStack(err)
+	}
+	return nil
+}
+
+// Vacuum rebuilds the database file using VACUUM, which shrinks it to the size of its content.
+// The write lock is held while the file is rewritten, so both Writes and Reads wait for it.
+// With WithIncrementalAutoVacuum, it also converts the database to auto_vacuum=INCREMENTAL.
+func (db *DB) Vacuum(ctx context.Context) error {
+	return db.mapError(db.vacuum(ctx))
+}
+
+func (db *DB) vacuum(ctx context.Context) error {
+	if !isSQLiteDriver(db.DriverName()) {
+		return errors.Errorf("vacuuming isn't supported for driver %q", db.DriverName())
+	}
+	if err := db.lockContext(ctx); err != nil {
+		return err
+	}
+	defer db.locker.Unlock()
+	before, err := db.pragmaInt(ctx, "page_count")
+	if err != nil {
+		return err
+	}
+	query := "VACUUM"
+	if db.incrementalAutoVacuum {
+		query = "PRAGMA auto_vacuum = INCREMENTAL; VACUUM"
+	}
+	start := time.Now()
+	if _, err := db.ExecContext(labeled(ctx, db, "vacuum", ""), query); err != nil {
+		return withStack(err)
+	}
+	after, err := db.pragmaInt(ctx, "page_count")
+	if err != nil {
+		return err
+	}
+	db.log(ctx, slog.LevelInfo, "sqly: vacuumed", "duration", time.Since(start), "pagesBefore", before, "pagesAfter", after)
+	return nil
+}
+
+// IncrementalVacuum removes up to pages free pages from the database file, or all of them if pages isn't positive.
+// It requires auto_vacuum=INCREMENTAL, see WithIncrementalAutoVacuum, and holds the write lock while running.
+func (db *DB) IncrementalVacuum(ctx context.Context, pages int) error {
+	return db.mapError(db.incrementalVacuum(ctx, pages))
+}
+
+func (db *DB) incrementalVacuum(ctx context.Context, pages int) error {
+	if !isSQLiteDriver(db.DriverName()) {
+		return errors.Errorf("vacuuming isn't supported for driver %q", db.DriverName())
+	}
+	if err := db.lockContext(ctx); err != nil {
+		return err
+	}
+	defer db.locker.Unlock()
+	mode, err := db.pragmaInt(ctx, "auto_vacuum")
+	if err != nil {
+		return err
+	}
+	if mode != autoVacuumIncremental {
+		return errors.Errorf("incremental vacuuming needs auto_vacuum=INCREMENTAL, but it is %v, see WithIncrementalAutoVacuum", mode)
+	}
+	before, err := db.pragmaInt(ctx, "freelist_count")
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	// incremental_vacuum frees a page per step, so it's run as a query that is stepped through until done.
+	rows, err := db.QueryxContext(labeled(ctx, db, "vacuum", ""), fmt.Sprintf("PRAGMA incremental_vacuum(%d)", max(pages, 0)))
+	if err != nil {
+		return withStack(err)
+	}
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return withStack(err)
+	}
+	if err := rows.Close(); err != nil {
+		return withStack(err)
+	}
+	after, err := db.pragmaInt(ctx, "freelist_count")
+	if err != nil {
+		return err
+	}
+	db.log(ctx, slog.LevelInfo, "sqly: incrementally vacuumed", "duration", time.Since(start), "freedPages", before-after)
+	return nil
+}
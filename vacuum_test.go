@@ -0,0 +1,105 @@
+package sqly
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type vacuumTestStruct struct {
+	Id   int `sqly:"pkey"`
+	Data []byte
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+// fillAndEmpty inserts and then deletes a few thousand BLOB rows, leaving the file full of free pages.
+func fillAndEmpty(t *testing.T, db *DB) {
+	t.Helper()
+	noerr(t, db.CreateTableIfNotExists(ctx, vacuumTestStruct{}))
+	noerr(t, db.Write(ctx, func(tx *Tx) error {
+		for i := 0; i < 3000; i++ {
+			if err := tx.Upsert(ctx, &vacuumTestStruct{Id: i + 1, Data: bytes.Repeat([]byte{byte(i)}, 1024)}, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	noerr(t, db.Write(ctx, func(tx *Tx) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM vacuumTestStruct")
+		return err
+	}))
+}
+
+func TestVacuum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	logs := &bytes.Buffer{}
+	db, err := Open("sqlite", path, WithLogger(slog.New(slog.NewTextHandler(logs, nil))))
+	noerr(t, err)
+	defer db.Close()
+	fillAndEmpty(t, db)
+	before := fileSize(t, path)
+	yeserr(t, db.IncrementalVacuum(ctx, 0))
+	noerr(t, db.Vacuum(ctx))
+	if after := fileSize(t, path); after >= before/2 {
+		t.Errorf("got file size %v after vacuuming, wanted less than half of %v", after, before)
+	}
+	if !strings.Contains(logs.String(), "sqly: vacuumed") {
+		t.Errorf("got logs %q, wanted a vacuum", logs.String())
+	}
+}
+
+func TestIncrementalVacuum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open("sqlite", path, WithIncrementalAutoVacuum())
+	noerr(t, err)
+	defer db.Close()
+	fillAndEmpty(t, db)
+	before := fileSize(t, path)
+	noerr(t, db.IncrementalVacuum(ctx, 10))
+	partial := fileSize(t, path)
+	if partial >= before {
+		t.Errorf("got file size %v after freeing 10 pages, wanted less than %v", partial, before)
+	}
+	noerr(t, db.IncrementalVacuum(ctx, 0))
+	if after := fileSize(t, path); after >= before/2 {
+		t.Errorf("got file size %v after incrementally vacuuming, wanted less than half of %v", after, before)
+	}
+}
+
+func TestIncrementalAutoVacuumConversion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open("sqlite", path)
+	noerr(t, err)
+	noerr(t, db.CreateTableIfNotExists(ctx, vacuumTestStruct{}))
+	noerr(t, db.Close())
+
+	logs := &bytes.Buffer{}
+	db, err = Open("sqlite", path, WithIncrementalAutoVacuum(), WithLogger(slog.New(slog.NewTextHandler(logs, nil))))
+	noerr(t, err)
+	defer db.Close()
+	if !strings.Contains(logs.String(), "changing it needs a Vacuum") {
+		t.Errorf("got logs %q, wanted a warning about the auto_vacuum mode", logs.String())
+	}
+	yeserr(t, db.IncrementalVacuum(ctx, 0))
+	noerr(t, db.Vacuum(ctx))
+	noerr(t, db.IncrementalVacuum(ctx, 0))
+}
+
+func TestIncrementalAutoVacuumSetupFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	noerr(t, os.WriteFile(path, nil, 0o644))
+	_, err := Open("sqlite", "file:"+path+"?mode=ro", WithIncrementalAutoVacuum(), WithOptimizeInterval(time.Hour), WithCheckpointing(time.Hour, CheckpointPassive, nil))
+	yeserr(t, err)
+}
@@ -0,0 +1,81 @@
+package sqly
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MultiError contains every problem found when planning or validating struct types.
+type MultiError struct {
+	Errors []error
+}
+
+func newMultiError(errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return &MultiError{Errors: errs}
+}
+
+func (m *MultiError) Error() string {
+	msgs := make([]string, len(m.Errors))
+	for index, err := range m.Errors {
+		msgs[index] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (m *MultiError) Unwrap() []error {
+	return m.Errors
+}
+
+func (meta *tableMeta) requirePrimaryKey() error {
+	if meta.pkey == nil {
+		return errors.Errorf("%v doesn't have a PRIMARY KEY (field tagged `sqly:\"pkey\"`)", meta.typ)
+	}
+	return nil
+}
+
+// Validate plans prototype as a table without touching a database, and returns every problem that would make CreateTableIfNotExists or Upsert fail.
+func Validate(prototype any) error {
+	return validateAll(defaultMetas, []any{prototype})
+}
+
+// ValidateAll works like Validate, but returns the problems of all prototypes.
+func ValidateAll(prototypes ...any) error {
+	return validateAll(defaultMetas, prototypes)
+}
+
+// Validate works like the package level Validate, but uses the name mapper, tags, transformers and collations configured for db.
+func (db *DB) Validate(prototype any) error {
+	return validateAll(db.metaCache(), []any{prototype})
+}
+
+// ValidateAll works like the package level ValidateAll, but uses the configuration of db like Validate.
+func (db *DB) ValidateAll(prototypes ...any) error {
+	return validateAll(db.metaCache(), prototypes)
+}
+
+func validateAll(metas *metaCache, prototypes []any) error {
+	problems := []error{}
+	for _, prototype := range prototypes {
+		val := reflect.ValueOf(prototype)
+		if val.Kind() != reflect.Struct {
+			problems = append(problems, errors.Errorf("%v is not a reflect.Struct", prototype))
+			continue
+		}
+		meta, typeProblems := metas.planProblems(val.Type())
+		problems = append(problems, typeProblems...)
+		if meta != nil {
+			if err := meta.requirePrimaryKey(); err != nil {
+				problems = append(problems, err)
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return newMultiError(problems)
+}
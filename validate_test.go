@@ -0,0 +1,83 @@
+package sqly
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type validTestStruct struct {
+	Id   int64  `sqly:"pkey,autoinc"`
+	Name string `sqly:"unique"`
+}
+
+type noPkeyTestStruct struct {
+	Name string
+}
+
+type unsupportedTypeTestStruct struct {
+	Id    int64 `sqly:"pkey"`
+	Map   map[string]string
+	Slice []string
+}
+
+type textAutoincTestStruct struct {
+	Id string `sqly:"pkey,autoinc"`
+}
+
+type duplicateColTestStruct struct {
+	Id        int64 `sqly:"pkey"`
+	UserName  string
+	User_name string
+}
+
+type conflictingTagsTestStruct struct {
+	Id    int64  `sqly:"pkey"`
+	Other int64  `sqly:"pkey"`
+	Count int    `sqly:"autoinc"`
+	Name  string `sqly:"unique,unique"`
+}
+
+func TestValidate(t *testing.T) {
+	noerr(t, Validate(validTestStruct{}))
+	noerr(t, ValidateAll(validTestStruct{}, sharedTestStruct{}))
+	for _, tc := range []struct {
+		prototype any
+		want      []string
+	}{
+		{noPkeyTestStruct{}, []string{"sqly.noPkeyTestStruct doesn't have a PRIMARY KEY"}},
+		{unsupportedTypeTestStruct{}, []string{`col "Map" of sqly.unsupportedTypeTestStruct`, `col "Slice" of sqly.unsupportedTypeTestStruct`}},
+		{textAutoincTestStruct{}, []string{`col "Id" can't be autoinc pkey if it's not an INTEGER type`}},
+		{&validTestStruct{}, []string{"is not a reflect.Struct"}},
+		{conflictingTagsTestStruct{}, []string{
+			`sqly.conflictingTagsTestStruct has multiple PRIMARY KEY fields: "Id" and "Other"`,
+			`col "Count" can't be autoinc if it's not also pkey`,
+			`sqly tag "unique" is repeated on sqly.conflictingTagsTestStruct.Name`,
+		}},
+	} {
+		err := Validate(tc.prototype)
+		if err == nil {
+			t.Errorf("validating %T: got no error, wanted %q", tc.prototype, tc.want)
+			continue
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("validating %T: got %q, wanted it to contain %q", tc.prototype, err.Error(), want)
+			}
+		}
+	}
+	noerr(t, Validate(duplicateColTestStruct{}))
+	withDB(t, func(db *DB) {
+		err := db.Validate(duplicateColTestStruct{})
+		if err == nil || !strings.Contains(err.Error(), `sqly.duplicateColTestStruct.UserName and sqly.duplicateColTestStruct.User_name both map to col "user_name"`) {
+			t.Errorf("got %v, wanted an error about the duplicate column", err)
+		}
+		yeserr(t, db.CreateTableIfNotExists(ctx, duplicateColTestStruct{}))
+	}, WithNameMapper(snakeCaseMapper{}))
+	err := ValidateAll(validTestStruct{}, noPkeyTestStruct{}, textAutoincTestStruct{})
+	multi := &MultiError{}
+	if !errors.As(err, &multi) || len(multi.Errors) != 2 {
+		t.Errorf("got %v, wanted a MultiError with two errors", err)
+	}
+}
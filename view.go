@@ -0,0 +1,95 @@
+package sqly
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// ViewError is returned when writing a struct whose table is a view, like the views created by CreateViewIfNotExists.
+type ViewError struct {
+	View string
+}
+
+func (e *ViewError) Error() string {
+	return fmt.Sprintf("%q is a read only view", e.View)
+}
+
+// viewError returns a *ViewError if err, returned by a write of meta through x, was caused by the table of meta being a view, and err otherwise.
+// The database is only asked after a write failed, and in the transaction of x, so it sees views created or dropped by anyone.
+func viewError(ctx context.Context, x any, meta *tableMeta, err error) error {
+	queryer, ok := x.(sqlx.QueryerContext)
+	if err == nil || !ok || !isSQLiteDriver(driverNameOf(x)) {
+		return err
+	}
+	schema, table := splitTable(meta.table)
+	views := 0
+	if getContext(ctx, queryer, &views, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE type = 'view' AND name = ?", sqliteMaster(schema)), table) != nil || views == 0 {
+		return err
+	}
+	return errors.WithStack(&ViewError{View: meta.table})
+}
+
+// CreateViewIfNotExists creates a view named like the table of prototype, defined by selectSQL, so that the read helpers can scan reporting
+// queries, like joins, into prototype. The output columns of selectSQL must be exactly the columns of prototype, and Upserts and Deletes of
+// prototype fail with a *ViewError after the view is created.
+// If ext is a *DB the view is created in a Write transaction.
+func CreateViewIfNotExists(ctx context.Context, ext sqlx.ExtContext, prototype any, selectSQL string) error {
+	if db, ok := ext.(*DB); ok {
+		return db.Write(ctx, func(tx *Tx) error {
+			return CreateViewIfNotExists(ctx, tx, prototype, selectSQL)
+		})
+	}
+	meta, err := metaOf(ext, prototype)
+	if err != nil {
+		return err
+	}
+	ctx = labeled(ctx, ext, "createView", meta.typ.Name())
+	rows, err := ext.QueryxContext(ctx, fmt.Sprintf("SELECT * FROM (%s) LIMIT 0", selectSQL))
+	if err != nil {
+		return withStack(err)
+	}
+	cols, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return withStack(err)
+	}
+	selected := map[string]bool{}
+	for _, col := range cols {
+		selected[col] = true
+	}
+	problems := []string{}
+	for _, field := range meta.fields {
+		if !selected[field.col] {
+			problems = append(problems, fmt.Sprintf("missing col %q", field.col))
+		}
+		delete(selected, field.col)
+	}
+	extra := []string{}
+	for col := range selected {
+		extra = append(extra, col)
+	}
+	sort.Strings(extra)
+	for _, col := range extra {
+		problems = append(problems, fmt.Sprintf("unknown col %q", col))
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("view of %v doesn't select its columns: %s", meta.typ, strings.Join(problems, ", "))
+	}
+	if _, err := ext.ExecContext(ctx, fmt.Sprintf("CREATE VIEW IF NOT EXISTS %s AS %s", quoteTable(meta.table), selectSQL)); err != nil {
+		return withStack(err)
+	}
+	return nil
+}
+
+func (db *DB) CreateViewIfNotExists(ctx context.Context, prototype any, selectSQL string) error {
+	return CreateViewIfNotExists(ctx, db, prototype, selectSQL)
+}
+
+func (tx *Tx) CreateViewIfNotExists(ctx context.Context, prototype any, selectSQL string) error {
+	return tx.db.mapError(CreateViewIfNotExists(ctx, tx, prototype, selectSQL))
+}
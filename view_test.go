@@ -0,0 +1,82 @@
+package sqly
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type viewAuthor struct {
+	Id   int64 `sqly:"pkey"`
+	Name string
+}
+
+type viewBook struct {
+	Id       int64 `sqly:"pkey"`
+	AuthorId int64
+	Title    string
+}
+
+type viewAuthoredBook struct {
+	Id     int64 `sqly:"pkey"`
+	Title  string
+	Author string
+}
+
+type viewTitle struct {
+	Id    int64 `sqly:"pkey"`
+	Title string
+}
+
+func TestCreateViewIfNotExists(t *testing.T) {
+	withDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, viewAuthor{}))
+		noerr(t, db.CreateTableIfNotExists(ctx, viewBook{}))
+		noerr(t, db.UpsertMany(ctx, false, &viewAuthor{Id: 1, Name: "Tolkien"}, &viewBook{Id: 1, AuthorId: 1, Title: "The Hobbit"}, &viewBook{Id: 2, AuthorId: 1, Title: "The Silmarillion"}))
+		yeserr(t, db.CreateViewIfNotExists(ctx, viewAuthoredBook{}, "SELECT viewBook.Id, viewBook.Title FROM viewBook"))
+		yeserr(t, db.CreateViewIfNotExists(ctx, viewAuthoredBook{}, "SELECT viewBook.Id, viewBook.Title, viewAuthor.Name AS Author, viewBook.AuthorId FROM viewBook JOIN viewAuthor ON viewAuthor.Id = viewBook.AuthorId"))
+		selectSQL := "SELECT viewBook.Id, viewBook.Title, viewAuthor.Name AS Author FROM viewBook JOIN viewAuthor ON viewAuthor.Id = viewBook.AuthorId"
+		noerr(t, db.CreateViewIfNotExists(ctx, viewAuthoredBook{}, selectSQL))
+		noerr(t, db.CreateViewIfNotExists(ctx, viewAuthoredBook{}, selectSQL))
+		found, err := SelectByExample(ctx, db, viewAuthoredBook{Title: "The Hobbit"})
+		noerr(t, err)
+		if want := []viewAuthoredBook{{Id: 1, Title: "The Hobbit", Author: "Tolkien"}}; !reflect.DeepEqual(found, want) {
+			t.Errorf("got %+v, wanted %+v", found, want)
+		}
+		book := &viewAuthoredBook{Id: 2}
+		noerr(t, db.Refresh(ctx, book))
+		if book.Author != "Tolkien" {
+			t.Errorf("got %+v, wanted the author joined in", book)
+		}
+		viewErr := &ViewError{}
+		if err := db.Upsert(ctx, book, true); !errors.As(err, &viewErr) || viewErr.View != "viewAuthoredBook" {
+			t.Errorf("got %v, wanted a *ViewError", err)
+		}
+		if err := db.UpsertAll(ctx, []*viewAuthoredBook{book}, true); !errors.As(err, &viewErr) {
+			t.Errorf("got %v, wanted a *ViewError", err)
+		}
+		if err := db.Delete(ctx, book); !errors.As(err, &viewErr) {
+			t.Errorf("got %v, wanted a *ViewError", err)
+		}
+
+		_, err = db.Exec("DROP VIEW viewAuthoredBook")
+		noerr(t, err)
+		noerr(t, db.CreateTableIfNotExists(ctx, viewAuthoredBook{}))
+		noerr(t, db.Upsert(ctx, book, true))
+		yeserr(t, db.Write(ctx, func(tx *Tx) error {
+			noerr(t, tx.CreateViewIfNotExists(ctx, viewTitle{}, "SELECT Id, Title FROM viewBook"))
+			return errors.New("rollback")
+		}))
+		noerr(t, db.CreateTableIfNotExists(ctx, viewTitle{}))
+		noerr(t, db.Upsert(ctx, &viewTitle{Id: 1, Title: "The Hobbit"}, false))
+	})
+	withDB(t, func(db *DB) {
+		_, err := db.Exec("CREATE VIEW viewAuthoredBook AS SELECT 1 AS Id, 'a' AS Title, 'b' AS Author")
+		noerr(t, err)
+		viewErr := &ViewError{}
+		if err := db.Upsert(ctx, &viewAuthoredBook{Id: 1}, false); !errors.As(err, &viewErr) {
+			t.Errorf("got %v, wanted a *ViewError for a view sqly didn't create", err)
+		}
+	})
+}
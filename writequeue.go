@@ -0,0 +1,207 @@
+package sqly
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	writeJobQueued int32 = iota
+	writeJobRunning
+	writeJobCancelled
+)
+
+type writeJob struct {
+	ctx   context.Context
+	f     func(*Tx) error
+	state atomic.Int32
+	// done receives the result of synchronous Writes.
+	done chan writeResult
+	// callback receives the result of WriteAsync.
+	callback func(error)
+}
+
+type writeResult struct {
+	err        error
+	panicked   bool
+	panicValue any
+	stack      []byte
+}
+
+// writeQueue executes the Writes of a DB in a single dispatcher goroutine, strictly in arrival order.
+type writeQueue struct {
+	jobs     chan *writeJob
+	lock     sync.RWMutex
+	isClosed bool
+	closed   chan struct{}
+}
+
+// WithWriteQueue makes Write submit its transactions to a single dispatcher goroutine that runs them strictly in arrival order,
+// instead of letting the writers compete for the lock. Reads still use the lock, which the dispatcher holds while running each transaction.
+// Writes whose contexts are cancelled while queued are skipped, and panics are propagated to the goroutine calling Write.
+func WithWriteQueue() Option {
+	return func(db *DB) error {
+		db.writeQueueing = true
+		return nil
+	}
+}
+
+// WithAsyncWrites enables WriteAsync, which requires the write queue of WithWriteQueue, with room for queueSize queued Writes.
+func WithAsyncWrites(queueSize int) Option {
+	return func(db *DB) error {
+		if queueSize < 1 {
+			return errors.Errorf("async write queue size %v is less than 1", queueSize)
+		}
+		db.writeQueueing = true
+		db.writeQueueSize = queueSize
+		return nil
+	}
+}
+
+func newWriteQueue(db *DB, size int) *writeQueue {
+	q := &writeQueue{
+		jobs:   make(chan *writeJob, size),
+		closed: make(chan struct{}),
+	}
+	go q.run(db)
+	return q
+}
+
+func (q *writeQueue) run(db *DB) {
+	defer close(q.closed)
+	for job := range q.jobs {
+		if !job.state.CompareAndSwap(writeJobQueued, writeJobRunning) {
+			continue
+		}
+		result := writeResult{err: withStack(job.ctx.Err())}
+		if result.err == nil {
+			result = db.runWriteJob(job)
+		}
+		if job.callback != nil {
+			if result.panicked {
+				result.err = &PanicError{Value: result.panicValue, Stack: result.stack}
+			}
+			job.callback(result.err)
+		} else {
+			job.done <- result
+		}
+	}
+}
+
+func (db *DB) runWriteJob(job *writeJob) (result writeResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = writeResult{panicked: true, panicValue: r, stack: debug.Stack()}
+		}
+	}()
+	if err := db.lockContext(job.ctx); err != nil {
+		return writeResult{err: err}
+	}
+	defer db.locker.Unlock()
+	return writeResult{err: db.inTx(job.ctx, nil, job.f)}
+}
+
+func (q *writeQueue) submit(ctx context.Context, f func(*Tx) error) error {
+	job := &writeJob{ctx: ctx, f: f, done: make(chan writeResult, 1)}
+	if err := func() error {
+		q.lock.RLock()
+		defer q.lock.RUnlock()
+		if q.isClosed {
+			return withStack(ErrClosed)
+		}
+		select {
+		case q.jobs <- job:
+			return nil
+		case <-ctx.Done():
+			return withStack(ctx.Err())
+		}
+	}(); err != nil {
+		return err
+	}
+	select {
+	case result := <-job.done:
+		return result.get()
+	case <-ctx.Done():
+		if job.state.CompareAndSwap(writeJobQueued, writeJobCancelled) {
+			return withStack(ctx.Err())
+		}
+		return (<-job.done).get()
+	}
+}
+
+func (q *writeQueue) submitAsync(ctx context.Context, f func(*Tx) error, callback func(error)) error {
+	if callback == nil {
+		callback = func(error) {}
+	}
+	job := &writeJob{ctx: ctx, f: f, callback: callback}
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	if q.isClosed {
+		return withStack(ErrClosed)
+	}
+	select {
+	case q.jobs <- job:
+		return nil
+	default:
+		return withStack(ErrQueueFull)
+	}
+}
+
+func (r writeResult) get() error {
+	if r.panicked {
+		panic(r.panicValue)
+	}
+	return r.err
+}
+
+// close makes all future Writes fail with ErrClosed, and waits for the dispatcher to run the Writes already queued.
+func (q *writeQueue) close() {
+	q.lock.Lock()
+	if !q.isClosed {
+		q.isClosed = true
+		close(q.jobs)
+	}
+	q.lock.Unlock()
+	<-q.closed
+}
+
+// WriteAsync queues f to run in a Write transaction, and returns without waiting for it.
+// done, if not nil, is called from the dispatcher goroutine with the result after the transaction is committed or rolled back,
+// and with a *PanicError if f panics.
+// Since WriteAsync uses the same queue as Write, a Write started after WriteAsync returns runs after f.
+// Returns ErrQueueFull if the queue configured by WithAsyncWrites is full, and ErrClosed if the DB is closed.
+// When the DB is closed, all queued transactions are run before Close returns.
+func (db *DB) WriteAsync(ctx context.Context, f func(*Tx) error, done func(error)) error {
+	if db.writeQueue == nil || db.writeQueueSize == 0 {
+		return errors.Errorf("async writes aren't enabled, see WithAsyncWrites")
+	}
+	return db.writeQueue.submitAsync(ctx, f, done)
+}
+
+// Close stops the checkpoints and optimizations, flushes the WriteBatched callbacks and stops the write queue, if any,
+// optimizes a last time if WithOptimizeInterval is used, and closes the database.
+func (db *DB) Close() error {
+	if db.checkpointing != nil {
+		db.checkpointing.stop()
+	}
+	if db.optimizing != nil {
+		db.optimizing.stop()
+	}
+	if db.batching != nil {
+		db.batching.close()
+	}
+	if db.writeQueue != nil {
+		db.writeQueue.close()
+	}
+	if db.optimizing != nil {
+		if err := db.Optimize(context.Background()); err != nil {
+			db.log(context.Background(), slog.LevelError, "sqly: optimizing failed", "error", err)
+		}
+	}
+	return withStack(db.DB.Close())
+}
@@ -0,0 +1,267 @@
+package sqly
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestWriteQueue(t *testing.T) {
+	withFileDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		started := make(chan struct{})
+		release := make(chan struct{})
+		firstDone := make(chan error, 1)
+		go func() {
+			firstDone <- db.Write(ctx, func(tx *Tx) error {
+				close(started)
+				<-release
+				return tx.Upsert(ctx, &sharedTestStruct{Id: 1}, false)
+			})
+		}()
+		<-started
+		cancelled, cancel := context.WithCancel(ctx)
+		cancelledDone := make(chan error, 1)
+		go func() {
+			cancelledDone <- db.Write(cancelled, func(tx *Tx) error {
+				t.Errorf("cancelled write was run")
+				return nil
+			})
+		}()
+		order := make(chan int, 5)
+		queuedDone := make(chan error, 5)
+		for i := 0; i < 5; i++ {
+			go func() {
+				queuedDone <- db.Write(ctx, func(tx *Tx) error {
+					order <- i
+					return tx.Upsert(ctx, &sharedTestStruct{Id: i + 2}, false)
+				})
+			}()
+			time.Sleep(5 * time.Millisecond)
+		}
+		cancel()
+		if err := <-cancelledDone; !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, wanted context.Canceled", err)
+		}
+		close(release)
+		noerr(t, <-firstDone)
+		for i := 0; i < 5; i++ {
+			noerr(t, <-queuedDone)
+			if got := <-order; got != i {
+				t.Errorf("got write %v, wanted write %v", got, i)
+			}
+		}
+		if count := countRows(t, db, "sharedTestStruct"); count != 6 {
+			t.Errorf("got %v rows, wanted 6", count)
+		}
+
+		func() {
+			defer func() {
+				if r := recover(); r != "boom" {
+					t.Errorf("got %v, wanted the panic to propagate", r)
+				}
+			}()
+			db.Write(ctx, func(tx *Tx) error {
+				noerr(t, tx.Upsert(ctx, &sharedTestStruct{Id: 100}, false))
+				panic("boom")
+			})
+		}()
+		if count := countRows(t, db, "sharedTestStruct"); count != 6 {
+			t.Errorf("got %v rows, wanted the panicking write rolled back", count)
+		}
+		noerr(t, db.Write(ctx, func(tx *Tx) error { return nil }))
+	}, WithWriteQueue())
+}
+
+func TestWriteQueueClose(t *testing.T) {
+	db, err := Open("sqlite", ":memory:", WithWriteQueue())
+	noerr(t, err)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	firstDone := make(chan error, 1)
+	go func() {
+		firstDone <- db.Write(ctx, func(tx *Tx) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	queuedRun := false
+	queuedDone := make(chan error, 1)
+	go func() {
+		queuedDone <- db.Write(ctx, func(tx *Tx) error {
+			queuedRun = true
+			return nil
+		})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	closed := make(chan error, 1)
+	go func() {
+		closed <- db.Close()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	noerr(t, <-firstDone)
+	noerr(t, <-closed)
+	noerr(t, <-queuedDone)
+	if !queuedRun {
+		t.Errorf("queued write wasn't run before Close returned")
+	}
+	if err := db.Write(ctx, func(tx *Tx) error { return nil }); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, wanted ErrClosed", err)
+	}
+}
+
+func TestWriteAsync(t *testing.T) {
+	withFileDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		started := make(chan struct{})
+		release := make(chan struct{})
+		firstDone := make(chan error, 1)
+		go func() {
+			firstDone <- db.Write(ctx, func(tx *Tx) error {
+				close(started)
+				<-release
+				return nil
+			})
+		}()
+		<-started
+		order := make(chan int, 4)
+		results := make(chan error, 4)
+		for i := 0; i < 3; i++ {
+			noerr(t, db.WriteAsync(ctx, func(tx *Tx) error {
+				order <- i
+				return tx.Upsert(ctx, &sharedTestStruct{Id: i + 1}, false)
+			}, func(err error) {
+				results <- err
+			}))
+		}
+		if err := db.WriteAsync(ctx, func(tx *Tx) error {
+			t.Errorf("rejected write was run")
+			return nil
+		}, nil); !errors.Is(err, ErrQueueFull) {
+			t.Errorf("got %v, wanted ErrQueueFull", err)
+		}
+		syncDone := make(chan error, 1)
+		go func() {
+			syncDone <- db.Write(ctx, func(tx *Tx) error {
+				order <- 3
+				return nil
+			})
+		}()
+		close(release)
+		noerr(t, <-firstDone)
+		noerr(t, <-syncDone)
+		for i := 0; i < 4; i++ {
+			if got := <-order; got != i {
+				t.Errorf("got write %v, wanted write %v", got, i)
+			}
+		}
+		for i := 0; i < 3; i++ {
+			noerr(t, <-results)
+		}
+		if count := countRows(t, db, "sharedTestStruct"); count != 3 {
+			t.Errorf("got %v rows, wanted 3", count)
+		}
+
+		failed := make(chan error, 1)
+		noerr(t, db.WriteAsync(ctx, func(tx *Tx) error {
+			noerr(t, tx.Upsert(ctx, &sharedTestStruct{Id: 100}, false))
+			return errors.New("failed")
+		}, func(err error) {
+			failed <- err
+		}))
+		if err := <-failed; err == nil || err.Error() != "failed" {
+			t.Errorf("got %v, wanted the error of the write", err)
+		}
+		panicked := make(chan error, 1)
+		noerr(t, db.WriteAsync(ctx, func(tx *Tx) error {
+			panic("boom")
+		}, func(err error) {
+			panicked <- err
+		}))
+		panicErr := &PanicError{}
+		if err := <-panicked; !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+			t.Errorf("got %v, wanted a PanicError", err)
+		}
+		if count := countRows(t, db, "sharedTestStruct"); count != 3 {
+			t.Errorf("got %v rows, wanted the failed write rolled back", count)
+		}
+	}, WithAsyncWrites(3))
+}
+
+func TestWriteAsyncClose(t *testing.T) {
+	db, err := Open("sqlite", ":memory:", WithAsyncWrites(10))
+	noerr(t, err)
+	count := 0
+	results := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		noerr(t, db.WriteAsync(ctx, func(tx *Tx) error {
+			count++
+			return nil
+		}, func(err error) {
+			results <- err
+		}))
+	}
+	noerr(t, db.Close())
+	if count != 10 {
+		t.Errorf("got %v writes run before Close returned, wanted 10", count)
+	}
+	for i := 0; i < 10; i++ {
+		noerr(t, <-results)
+	}
+	if err := db.WriteAsync(ctx, func(tx *Tx) error { return nil }, nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, wanted ErrClosed", err)
+	}
+	plain, err := Open("sqlite", ":memory:")
+	noerr(t, err)
+	defer plain.Close()
+	if err := plain.WriteAsync(ctx, func(tx *Tx) error { return nil }, nil); err == nil {
+		t.Errorf("wanted an error without WithAsyncWrites")
+	}
+}
+
+// writerFairness runs competing writers for a while, and returns the p99 and max latency of their Writes.
+func writerFairness(t *testing.T, opts ...Option) (time.Duration, time.Duration) {
+	t.Helper()
+	latencies := []time.Duration{}
+	withFileDB(t, func(db *DB) {
+		noerr(t, db.CreateTableIfNotExists(ctx, sharedTestStruct{}))
+		lock := sync.Mutex{}
+		wg := sync.WaitGroup{}
+		deadline := time.Now().Add(300 * time.Millisecond)
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; time.Now().Before(deadline); j++ {
+					start := time.Now()
+					noerr(t, db.Write(ctx, func(tx *Tx) error {
+						return tx.Upsert(ctx, &sharedTestStruct{Id: i*1000000 + j + 1}, false)
+					}))
+					lock.Lock()
+					latencies = append(latencies, time.Since(start))
+					lock.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+	}, opts...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies[len(latencies)*99/100], latencies[len(latencies)-1]
+}
+
+func TestWriterFairness(t *testing.T) {
+	if testing.Short() {
+		t.Skip("stress test")
+	}
+	p99, worst := writerFairness(t)
+	t.Logf("RWMutex write p99: %v, max: %v", p99, worst)
+	p99, worst = writerFairness(t, WithWriteQueue())
+	t.Logf("write queue p99: %v, max: %v", p99, worst)
+}